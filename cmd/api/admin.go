@@ -0,0 +1,47 @@
+package main
+
+import (
+	"campaign/internal/api/handler"
+	"campaign/internal/domain/auth"
+	"campaign/internal/domain/reach"
+	"campaign/internal/domain/segment"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
+	"campaign/internal/infrastructure/cache"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Admin(router *gin.RouterGroup, adminAuth *handler.AdminAuth, db *sql.DB, memCache *cache.MemoryCache, targetingStores *tenant.Stores[*targeting.Store],
+	segmentStores *tenant.Stores[*segment.Store], requestLog *reach.Log) {
+	campaignHandler := handler.NewCampaignHandler(db, memCache, targetingStores)
+	segmentHandler := handler.NewSegmentHandler(db, memCache, segmentStores)
+	reachHandler := handler.NewReachHandler(requestLog)
+
+	view := adminAuth.Require(auth.PermissionView)
+	edit := adminAuth.Require(auth.PermissionEdit)
+	approve := adminAuth.Require(auth.PermissionApprove)
+
+	// Boolean targeting expressions, evaluated after the campaign's targeting rules
+	router.GET("/campaigns/:campaign_id/targeting-expression", view, campaignHandler.GetTargetingExpression)
+	router.PUT("/campaigns/:campaign_id/targeting-expression", edit, campaignHandler.SetTargetingExpression)
+	router.DELETE("/campaigns/:campaign_id/targeting-expression", edit, campaignHandler.DeleteTargetingExpression)
+
+	// Activating or pausing a campaign changes what is delivered, so it is the approval step
+	router.PUT("/campaigns/:campaign_id/status", approve, campaignHandler.SetCampaignStatus)
+
+	// Audience segments, targeted with rules on dimension "segment"
+	router.POST("/segments", edit, segmentHandler.CreateSegment)
+	router.GET("/segments", view, segmentHandler.GetSegments)
+	router.POST("/segments/:segment_id/members", edit, segmentHandler.UploadMembers)
+
+	// Reach estimates for proposed targeting, from the sampled request log; they change nothing
+	router.POST("/reach-estimate", view, reachHandler.EstimateReach)
+}
+
+// Explain registers delivery explanations next to delivery. They expose every campaign's targeting rules,
+// so router must carry the admin middleware and the route needs the view permission.
+func Explain(router *gin.RouterGroup, adminAuth *handler.AdminAuth, deliveryHandler *handler.DeliveryHandler) {
+	router.GET("/delivery/explain", adminAuth.Require(auth.PermissionView), deliveryHandler.ExplainDelivery)
+}
@@ -2,19 +2,21 @@ package main
 
 import (
 	"campaign/internal/api/handler"
-	"campaign/internal/infrastructure/cache"
-	"database/sql"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Delivery(router *gin.RouterGroup, db *sql.DB, memCache *cache.MemoryCache) {
-	deliveryHandler := handler.NewDeliveryHandler(db, memCache)
-
+func Delivery(router *gin.RouterGroup, deliveryHandler *handler.DeliveryHandler) {
 	// Main delivery endpoint
 	router.GET("/delivery", deliveryHandler.DeliveryHandler)
+	router.POST("/delivery", deliveryHandler.BatchDeliveryHandler)
 
 	// Discovery endpoints for available targeting options
 	router.GET("/dimensions", deliveryHandler.GetAvailableDimensions)
 	router.GET("/dimensions/:dimension/values", deliveryHandler.GetAvailableValues)
 }
+
+// DeliveryV2 registers the enveloped delivery API on the handler shared with v1
+func DeliveryV2(router *gin.RouterGroup, deliveryHandler *handler.DeliveryHandler) {
+	router.GET("/delivery", deliveryHandler.DeliveryHandlerV2)
+}
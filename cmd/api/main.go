@@ -1,22 +1,39 @@
 package main
 
 import (
+	"campaign/internal/api/handler"
+	"campaign/internal/api/rpc"
+	"campaign/internal/domain/auth"
 	"campaign/internal/domain/models"
+	"campaign/internal/domain/reach"
+	"campaign/internal/domain/segment"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
 	"campaign/internal/infrastructure/cache"
 	"campaign/internal/infrastructure/db"
+	"campaign/internal/infrastructure/geo"
+	"campaign/pkg/logging"
+	"campaign/pkg/tracing"
 	"campaign/pkg/utils"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 )
 
 func main() {
@@ -26,31 +43,81 @@ func main() {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
+	// Structured JSON logging at LOG_LEVEL; also the default for slog and the standard log package
+	logger := setupLogger(cfg)
+
+	// Tracing is set up before anything that may create spans
+	shutdownTracing := setupTracing(cfg)
+	defer shutdownTracing()
+
 	// Setup database
 	db, err := setupDatabase(cfg)
 	if err != nil {
-		log.Fatalf("Error setting up database: %v", err)
+		fatal("error setting up database", "error", err)
 	}
 	defer db.Close()
 
 	// Setup cache
 	memCache := setupCache(cfg)
 
-	// Start metrics server
-	metricsServer := startMetricsServer()
+	// Load the tenants, then the targeting snapshot and audience segments of each
+	tenantStore := setupTenants(cfg, logger, db)
+	targetingStores := setupTargeting(cfg, logger, db, tenantStore)
+	segmentStores := setupSegments(cfg, logger, db, tenantStore)
+
+	// Load API keys; nil when authentication is disabled
+	authStore := setupAuth(cfg, logger, db)
+
+	// Load the identity provider's keys for staff tokens; nil when no JWKS is configured. They are left out
+	// of readiness, since delivery does not depend on them.
+	verifier := setupJWT(cfg, logger)
+
+	// Setup request enrichment (GeoIP, User-Agent)
+	enrichers, closeEnrichers := setupEnrichers(cfg)
+	defer closeEnrichers()
+
+	// Register runtime, pool and cache collectors, then start the metrics server
+	utils.InitMetrics(db, memCache)
+	utils.ConfigureDeliveryMetrics(cfg.MetricsTopK, cfg.MetricsDimensionsLabel)
+	metricsServer := startMetricsServer(cfg)
+
+	// Sample delivery requests for reach estimates
+	requestLog, closeRequestLog := setupRequestLog(cfg)
+	defer closeRequestLog()
+
+	// Delivery is shared by the HTTP and gRPC APIs
+	deliveryHandler := handler.NewTenantDeliveryHandler(db, memCache, targetingStores, segmentStores, enrichers...)
+	deliveryHandler.SetRequestLog(requestLog)
+
+	// Readiness covers the database, the cache and the warm-up of tenants, targeting, segments and API keys
+	checks := []handler.HealthCheck{
+		handler.DatabaseCheck(db),
+		handler.CacheCheck(memCache),
+		handler.TenantsCheck(tenantStore),
+		handler.TargetingCheck(targetingStores),
+		handler.SegmentsCheck(segmentStores),
+	}
+	if authStore != nil {
+		checks = append(checks, handler.APIKeysCheck(authStore))
+	}
+	healthHandler := handler.NewHealthHandler(time.Duration(cfg.ReadinessTimeoutSeconds)*time.Second, checks...)
 
-	// Initialize metrics
-	utils.InitMetrics()
+	// Rate limits per route group; nil when disabled
+	rateLimitStore := setupRateLimitStore(cfg)
 
 	// Setup main router
-	router := setupRouter(db, memCache)
+	tenantResolver := tenant.NewResolver(tenantStore, cfg.TenantFallback)
+	router := setupRouter(cfg, logger, db, memCache, targetingStores, segmentStores, authStore, verifier, tenantResolver, rateLimitStore, requestLog, deliveryHandler, healthHandler)
 
 	// Create and start HTTP server
 	server := createHTTPServer(cfg, router)
 	go startServer(server, cfg.AppPort)
 
+	// Start gRPC server
+	grpcServer, grpcHealth := startGRPCServer(cfg, logger, deliveryHandler, authStore, tenantResolver, rateLimitStore)
+
 	// Wait for shutdown signal
-	waitForShutdown(server, metricsServer)
+	waitForShutdown(cfg, healthHandler, server, metricsServer, grpcServer, grpcHealth)
 }
 
 // loadConfiguration loads and validates application configuration
@@ -60,7 +127,7 @@ func loadConfiguration() (*models.AppConfig, error) {
 		return nil, fmt.Errorf("error loading config: %v", err)
 	}
 
-	// Set log level
+	// Gin's own debug output (route table, warnings) only at debug level
 	gin.SetMode(gin.ReleaseMode)
 	if cfg.LogLevel == "debug" {
 		gin.SetMode(gin.DebugMode)
@@ -69,6 +136,41 @@ func loadConfiguration() (*models.AppConfig, error) {
 	return cfg, nil
 }
 
+// setupLogger installs a JSON logger at the configured level as the process-wide default
+func setupLogger(cfg *models.AppConfig) *slog.Logger {
+	logger := logging.New(os.Stdout, cfg.LogLevel)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// setupTracing installs the OpenTelemetry tracer provider for TRACING_EXPORTER. The returned func
+// flushes the spans still buffered.
+func setupTracing(cfg *models.AppConfig) func() {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.TracingExporter,
+		FilePath:    cfg.TracingFile,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		fatal("error setting up tracing", "error", err)
+	}
+	slog.Info("tracing configured", "exporter", cfg.TracingExporter, "sample_ratio", cfg.TracingSampleRatio)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			slog.Error("error flushing traces", "error", err)
+		}
+	}
+}
+
+// fatal logs at error level and exits, for startup failures the service cannot run without
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
 // setupDatabase establishes database connection and configures connection pool
 func setupDatabase(cfg *models.AppConfig) (*sql.DB, error) {
 	dbConnString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
@@ -83,7 +185,7 @@ func setupDatabase(cfg *models.AppConfig) (*sql.DB, error) {
 	d.SetMaxOpenConns(25)
 	d.SetMaxIdleConns(25)
 	d.SetConnMaxLifetime(5 * time.Minute)
-	log.Println("Database connection pool configured successfully")
+	slog.Info("database connection pool configured", "max_open_conns", 25)
 
 	return d, nil
 }
@@ -91,38 +193,328 @@ func setupDatabase(cfg *models.AppConfig) (*sql.DB, error) {
 // setupCache initializes the in-memory cache
 func setupCache(cfg *models.AppConfig) *cache.MemoryCache {
 	memCache := cache.NewMemoryCacheWithSize(cfg.CacheSize)
-	log.Printf("Memory cache initialized with size: %d", cfg.CacheSize)
+	slog.Info("memory cache initialized", "size", cfg.CacheSize)
 	return memCache
 }
 
+// setupTenants loads the tenant directory, which maps request hosts to tenants, and keeps it refreshed
+// in the background
+func setupTenants(cfg *models.AppConfig, logger *slog.Logger, d *sql.DB) *tenant.Store {
+	store := tenant.NewStore(logger, func() ([]models.Tenant, error) {
+		return db.LoadTenants(context.Background(), d)
+	})
+
+	// A failed initial load is retried by requests, at most every refresh.RetryInterval, and on every refresh
+	if err := store.Refresh(); err != nil {
+		logger.Warn("initial tenant load failed", "error", err)
+	}
+
+	store.StartRefresh(time.Duration(cfg.TenantRefreshSeconds) * time.Second)
+	return store
+}
+
+// setupTargeting creates a targeting snapshot store per tenant, each loaded on creation and kept refreshed
+// in the background. The known tenants are warmed up now; tenants added later load on first delivery.
+func setupTargeting(cfg *models.AppConfig, logger *slog.Logger, d *sql.DB, tenants *tenant.Store) *tenant.Stores[*targeting.Store] {
+	stores := tenant.NewStores(tenants, func(tenantID string) *targeting.Store {
+		store := targeting.NewStore(logger, func() ([]models.Campaign, []models.TargetingRule, error) {
+			return db.LoadTargetingData(context.Background(), d, tenantID)
+		})
+
+		// A failed initial load is retried by deliveries, at most every refresh.RetryInterval, and on every refresh
+		if err := store.Refresh(); err != nil {
+			logger.Warn("initial targeting snapshot load failed", "tenant_id", tenantID, "error", err)
+		}
+
+		store.StartRefresh(time.Duration(cfg.TargetingRefreshSeconds) * time.Second)
+		return store
+	})
+
+	for _, tenantID := range knownTenants(tenants) {
+		if _, err := stores.Get(tenantID); err != nil {
+			logger.Warn("error creating tenant store", "tenant_id", tenantID, "error", err)
+		}
+	}
+	logger.Info("targeting snapshot refresh scheduled", "interval_seconds", cfg.TargetingRefreshSeconds)
+	return stores
+}
+
+// setupSegments creates an audience segment membership store per tenant, each loaded on creation and kept
+// refreshed in the background. The known tenants are warmed up now.
+func setupSegments(cfg *models.AppConfig, logger *slog.Logger, d *sql.DB, tenants *tenant.Store) *tenant.Stores[*segment.Store] {
+	stores := tenant.NewStores(tenants, func(tenantID string) *segment.Store {
+		store := segment.NewStore(logger, func(add func(segmentID, userID string)) error {
+			return db.LoadSegmentMemberships(context.Background(), d, tenantID, add)
+		})
+
+		// A failed initial load is retried by deliveries, at most every refresh.RetryInterval, and on every refresh
+		if err := store.Refresh(); err != nil {
+			logger.Warn("initial segment membership load failed", "tenant_id", tenantID, "error", err)
+		}
+
+		store.StartRefresh(time.Duration(cfg.SegmentRefreshSeconds) * time.Second)
+		return store
+	})
+
+	for _, tenantID := range knownTenants(tenants) {
+		if _, err := stores.Get(tenantID); err != nil {
+			logger.Warn("error creating tenant store", "tenant_id", tenantID, "error", err)
+		}
+	}
+	return stores
+}
+
+// knownTenants returns the IDs of the tenants to warm up, or none while the directory cannot be loaded;
+// their stores are then created on first delivery
+func knownTenants(tenants *tenant.Store) []string {
+	directory, err := tenants.Directory()
+	if err != nil {
+		return nil
+	}
+	return directory.IDs()
+}
+
+// setupAuth loads API clients and keys and keeps them refreshed in the background. It returns nil when
+// AUTH_ENABLED is false, which leaves every endpoint open.
+func setupAuth(cfg *models.AppConfig, logger *slog.Logger, d *sql.DB) *auth.Store {
+	if !cfg.AuthEnabled {
+		logger.Warn("api key authentication disabled: AUTH_ENABLED not set")
+		return nil
+	}
+
+	store := auth.NewStore(logger, func() ([]models.APIClient, []models.APIKey, error) {
+		return db.LoadAPIKeys(context.Background(), d)
+	})
+
+	// A failed initial load is retried by requests, at most every refresh.RetryInterval, and on every refresh
+	if err := store.Refresh(); err != nil {
+		logger.Warn("initial api key load failed", "error", err)
+	}
+
+	store.StartRefresh(time.Duration(cfg.AuthRefreshSeconds) * time.Second)
+	logger.Info("api key authentication enabled", "refresh_seconds", cfg.AuthRefreshSeconds)
+	return store
+}
+
+// jwksMinRefresh is the least time between reloads of the JWKS triggered by tokens with an unknown key ID
+const jwksMinRefresh = 30 * time.Second
+
+// setupJWT loads the signing keys of the staff identity provider from JWT_JWKS_URL or JWT_JWKS_FILE and
+// keeps them refreshed in the background. Returns nil when neither is set.
+func setupJWT(cfg *models.AppConfig, logger *slog.Logger) *auth.Verifier {
+	if !cfg.JWTEnabled() {
+		logger.Info("staff token authentication disabled: JWT_JWKS_URL and JWT_JWKS_FILE not set")
+		return nil
+	}
+
+	loader := auth.JWKSFromFile(cfg.JWTJWKSFile)
+	if cfg.JWTJWKSURL != "" {
+		loader = auth.JWKSFromURL(&http.Client{Timeout: 10 * time.Second}, cfg.JWTJWKSURL)
+	}
+	keys := auth.NewKeySet(logger, loader, jwksMinRefresh)
+
+	// A failed initial load is retried by requests, at most every jwksMinRefresh, and on every refresh
+	if err := keys.Refresh(context.Background()); err != nil {
+		logger.Warn("initial jwks load failed", "error", err)
+	}
+	keys.StartRefresh(time.Duration(cfg.JWTJWKSRefreshSeconds) * time.Second)
+
+	verifier := auth.NewVerifier(keys, auth.VerifierConfig{
+		Issuer:       cfg.JWTIssuer,
+		Audience:     cfg.JWTAudience,
+		RolesClaim:   cfg.JWTRolesClaim,
+		TenantsClaim: cfg.JWTTenantsClaim,
+		Leeway:       time.Duration(cfg.JWTLeewaySeconds) * time.Second,
+	})
+	logger.Info("staff token authentication enabled", "issuer", cfg.JWTIssuer, "refresh_seconds", cfg.JWTJWKSRefreshSeconds)
+	return verifier
+}
+
+// setupRateLimitStore creates the token bucket store for RATE_LIMIT_STORE. The memory store limits each
+// instance on its own; the Redis store shares the limits of all instances. Returns nil with "none".
+func setupRateLimitStore(cfg *models.AppConfig) utils.RateLimitStore {
+	if cfg.RateLimitStore != "none" && len(cfg.TrustedProxies) == 0 {
+		// Behind a load balancer every request would come from its IP and share one bucket
+		slog.Warn("rate limits fall back to the remote address: TRUSTED_PROXIES not set")
+	}
+
+	switch cfg.RateLimitStore {
+	case "none":
+		slog.Info("rate limiting disabled: RATE_LIMIT_STORE not set")
+		return nil
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPass,
+			DB:       cfg.RedisDB,
+		})
+		// Requests are let through while Redis is unreachable, so a failed ping is not fatal
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			slog.Warn("redis unreachable, requests are not rate limited until it is", "addr", cfg.RedisAddr, "error", err)
+		}
+		slog.Info("rate limiting with redis store", "addr", cfg.RedisAddr)
+		return cache.NewRedisRateLimitStore(client, "ratelimit:")
+	default:
+		slog.Info("rate limiting with in-memory store")
+		return utils.NewMemoryRateLimitStore()
+	}
+}
+
+// rateLimitKey returns the key function for RATE_LIMIT_*_KEY
+func rateLimitKey(key string) utils.RateLimitKeyFunc {
+	switch key {
+	case "app_id":
+		return utils.RateLimitByAppID
+	case "ip":
+		return utils.RateLimitByIP
+	default:
+		return utils.RateLimitByClient
+	}
+}
+
+// setupEnrichers builds the optional request enrichment stages. Explicit query parameters win over
+// enriched values, and GeoIP runs first. The returned func releases their resources.
+func setupEnrichers(cfg *models.AppConfig) ([]handler.Enricher, func()) {
+	var enrichers []handler.Enricher
+	closeFn := func() {}
+
+	if cfg.GeoIPDBPath == "" {
+		slog.Info("geoip enrichment disabled: GEOIP_DB_PATH not set")
+	} else if reader, err := geo.Open(cfg.GeoIPDBPath); err != nil {
+		slog.Warn("geoip enrichment disabled", "error", err)
+	} else {
+		enrichers = append(enrichers, handler.NewGeoEnricher(reader))
+		closeFn = func() {
+			if err := reader.Close(); err != nil {
+				slog.Error("error closing geoip database", "error", err)
+			}
+		}
+	}
+
+	if cfg.UserAgentEnrichment {
+		enrichers = append(enrichers, handler.NewUserAgentEnricher())
+		slog.Info("user-agent enrichment enabled")
+	}
+
+	return enrichers, closeFn
+}
+
+// setupRequestLog creates the sampled request log behind reach estimates. With REQUEST_LOG_PATH set, the
+// samples of previous runs are loaded from the JSONL file, which is then compacted to the retained window
+// and appended to. The returned func waits for pending samples to be written and closes the file.
+func setupRequestLog(cfg *models.AppConfig) (*reach.Log, func()) {
+	maxAge := time.Duration(cfg.RequestSampleMaxAgeHours) * time.Hour
+	requestLog := reach.NewLog(cfg.RequestSampleSize, cfg.RequestSampleRate, maxAge)
+	if cfg.RequestLogPath == "" {
+		slog.Info("request log kept in memory only: REQUEST_LOG_PATH not set")
+		return requestLog, func() {}
+	}
+
+	if file, err := os.Open(cfg.RequestLogPath); err == nil {
+		loaded, err := requestLog.Load(file)
+		file.Close()
+		if err != nil {
+			slog.Warn("request log is partly unreadable", "path", cfg.RequestLogPath, "error", err)
+		}
+		slog.Info("sampled requests loaded", "path", cfg.RequestLogPath, "count", loaded)
+	} else if !os.IsNotExist(err) {
+		slog.Warn("could not read request log", "error", err)
+	}
+
+	if err := compactRequestLog(cfg.RequestLogPath, requestLog.Samples()); err != nil {
+		slog.Warn("could not compact request log", "error", err)
+	}
+
+	file, err := os.OpenFile(cfg.RequestLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		slog.Warn("request log kept in memory only", "error", err)
+		return requestLog, func() {}
+	}
+	requestLog.PersistTo(file)
+
+	return requestLog, func() {
+		requestLog.Close()
+		if err := file.Close(); err != nil {
+			slog.Error("error closing request log", "error", err)
+		}
+	}
+}
+
+// compactRequestLog rewrites the request log file with only the samples still retained in memory
+func compactRequestLog(path string, samples []reach.Sample) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	for _, sample := range samples {
+		if err := encoder.Encode(sample); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 // setupRouter configures the main application router with middleware and routes
-func setupRouter(db *sql.DB, memCache *cache.MemoryCache) *gin.Engine {
+func setupRouter(cfg *models.AppConfig, logger *slog.Logger, db *sql.DB, memCache *cache.MemoryCache, targetingStores *tenant.Stores[*targeting.Store],
+	segmentStores *tenant.Stores[*segment.Store], authStore *auth.Store, verifier *auth.Verifier, tenantResolver *tenant.Resolver, rateLimitStore utils.RateLimitStore,
+	requestLog *reach.Log, deliveryHandler *handler.DeliveryHandler, healthHandler *handler.HealthHandler) *gin.Engine {
 	router := gin.New()
 
-	// Add middleware
+	// Only trust X-Forwarded-For from configured proxies; with none, the client IP is the remote address
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fatal("invalid TRUSTED_PROXIES", "error", err)
+	}
+
+	// Add middleware. The server span comes first so that everything below runs inside it.
+	router.Use(otelgin.Middleware(tracing.ServiceName))
 	router.Use(utils.GinPrometheusMiddleware())
 	router.Use(utils.RequestIDMiddleware())
-	router.Use(gin.Recovery())
-	router.Use(gin.Logger())
+	router.Use(utils.AccessLogMiddleware(logger))
+	router.Use(utils.RecoveryMiddleware())
+
+	// Setup routes; with API keys enabled, delivery requires the delivery:read scope. Admin accepts staff
+	// tokens and API keys, with permissions checked per route. The tenant is resolved after authentication
+	// from the API key, the staff token or the host; admin requests never get the fallback tenant. Rate limits
+	// run after authentication, so that they can be keyed by client.
+	var deliveryMiddleware []gin.HandlerFunc
+	if authStore != nil {
+		deliveryMiddleware = append(deliveryMiddleware, handler.APIKeyAuth(authStore, auth.ScopeDeliveryRead))
+	}
+	adminAuth := handler.NewAdminAuth(verifier, authStore)
+	adminMiddleware := []gin.HandlerFunc{adminAuth.Authenticate()}
+	deliveryMiddleware = append(deliveryMiddleware, handler.TenantMiddleware(tenantResolver))
+	adminMiddleware = append(adminMiddleware, handler.TenantMiddleware(tenantResolver.WithoutFallback()))
+	if rateLimitStore != nil {
+		// v1 and v2 delivery share one budget
+		deliveryMiddleware = append(deliveryMiddleware, utils.RateLimitMiddleware("delivery",
+			utils.RateLimit{Rate: cfg.RateLimitDeliveryRPS, Burst: cfg.RateLimitDeliveryBurst}, rateLimitStore, rateLimitKey(cfg.RateLimitDeliveryKey)))
+		adminMiddleware = append(adminMiddleware, utils.RateLimitMiddleware("admin",
+			utils.RateLimit{Rate: cfg.RateLimitAdminRPS, Burst: cfg.RateLimitAdminBurst}, rateLimitStore, rateLimitKey(cfg.RateLimitAdminKey)))
+	}
 
-	// Setup routes
 	baseRoute := "/api/v1"
-	Delivery(router.Group(baseRoute), db, memCache)
+	Delivery(router.Group(baseRoute, deliveryMiddleware...), deliveryHandler)
+	DeliveryV2(router.Group("/api/v2", deliveryMiddleware...), deliveryHandler)
+	Admin(router.Group(baseRoute+"/admin", adminMiddleware...), adminAuth, db, memCache, targetingStores, segmentStores, requestLog)
+	Explain(router.Group(baseRoute, adminMiddleware...), adminAuth, deliveryHandler)
 
-	// Health check endpoint
-	router.GET("/health", healthCheckHandler)
+	// Health checks stay open to probes; /health is kept as an alias of /livez for existing probes
+	router.GET("/health", healthHandler.Livez)
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
 
 	return router
 }
 
-// healthCheckHandler handles health check requests
-func healthCheckHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status": "healthy",
-		"time":   time.Now().UTC(),
-	})
-}
-
 // createHTTPServer creates and configures the HTTP server
 func createHTTPServer(cfg *models.AppConfig, router *gin.Engine) *http.Server {
 	return &http.Server{
@@ -136,26 +528,57 @@ func createHTTPServer(cfg *models.AppConfig, router *gin.Engine) *http.Server {
 
 // startServer starts the HTTP server in a goroutine
 func startServer(server *http.Server, port string) {
-	log.Printf("Server starting on port %s", port)
+	slog.Info("server starting", "port", port)
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Error starting server: %v", err)
+		fatal("error starting server", "error", err)
 	}
 }
 
+// startGRPCServer starts the gRPC delivery service on its own port
+func startGRPCServer(cfg *models.AppConfig, logger *slog.Logger, deliveryHandler *handler.DeliveryHandler, authStore *auth.Store,
+	tenantResolver *tenant.Resolver, rateLimitStore utils.RateLimitStore) (*grpc.Server, *health.Server) {
+	// gRPC delivery shares the HTTP delivery budget
+	var rateLimit *rpc.RateLimit
+	if rateLimitStore != nil {
+		rateLimit = &rpc.RateLimit{
+			Group: "delivery",
+			Limit: utils.RateLimit{Rate: cfg.RateLimitDeliveryRPS, Burst: cfg.RateLimitDeliveryBurst},
+			Store: rateLimitStore,
+			Key:   cfg.RateLimitDeliveryKey,
+		}
+	}
+
+	server, healthServer := rpc.NewGRPCServer(deliveryHandler, logger, authStore, tenantResolver, rateLimit)
+
+	listener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		fatal("error listening on grpc port", "port", cfg.GRPCPort, "error", err)
+	}
+
+	go func() {
+		slog.Info("grpc server starting", "port", cfg.GRPCPort)
+		if err := server.Serve(listener); err != nil {
+			fatal("error starting grpc server", "error", err)
+		}
+	}()
+
+	return server, healthServer
+}
+
 // startMetricsServer starts the Prometheus metrics server
-func startMetricsServer() *http.Server {
+func startMetricsServer(cfg *models.AppConfig) *http.Server {
 	metricsRouter := gin.New()
-	metricsRouter.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	metricsRouter.GET("/metrics", gin.WrapH(utils.MetricsHandler()))
 
 	server := &http.Server{
-		Addr:    ":9090",
+		Addr:    ":" + cfg.MetricsPort,
 		Handler: metricsRouter,
 	}
 
 	go func() {
-		log.Println("Metrics server starting on :9090")
+		slog.Info("metrics server starting", "port", cfg.MetricsPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("Error starting metrics server: %v", err)
+			slog.Error("error starting metrics server", "error", err)
 		}
 	}()
 
@@ -163,25 +586,48 @@ func startMetricsServer() *http.Server {
 }
 
 // waitForShutdown waits for interrupt signal and gracefully shuts down servers
-func waitForShutdown(server *http.Server, metricsServer *http.Server) {
+func waitForShutdown(cfg *models.AppConfig, healthHandler *handler.HealthHandler, server *http.Server, metricsServer *http.Server,
+	grpcServer *grpc.Server, grpcHealth *health.Server) {
 	// Wait for interrupt signal to gracefully shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	slog.Info("shutting down server")
+
+	// Report not ready on HTTP and gRPC first, and keep serving while load balancers notice
+	healthHandler.SetShuttingDown()
+	grpcHealth.Shutdown()
+	if delay := time.Duration(cfg.ShutdownDelaySeconds) * time.Second; delay > 0 {
+		slog.Info("waiting for load balancers to stop routing", "delay_seconds", cfg.ShutdownDelaySeconds)
+		time.Sleep(delay)
+	}
 
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Drain in-flight gRPC calls within the same deadline as HTTP
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		fatal("server forced to shutdown", "error", err)
+	}
+
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		slog.Warn("grpc server forced to stop")
+		grpcServer.Stop()
 	}
 
 	// Shutdown metrics server
 	if err := metricsServer.Shutdown(ctx); err != nil {
-		log.Printf("Error shutting down metrics server: %v", err)
+		slog.Error("error shutting down metrics server", "error", err)
 	}
 
-	log.Println("Server exited")
+	slog.Info("server exited")
 }
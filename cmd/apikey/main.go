@@ -0,0 +1,177 @@
+// Command apikey manages API clients and their keys:
+//
+//	apikey create-client -id mobile-sdk -name "Mobile SDK" -scopes delivery:read [-tenant games]
+//	apikey issue-key -client mobile-sdk [-expires-in 2160h]
+//	apikey rotate-key -client mobile-sdk [-overlap 24h]
+//	apikey revoke-key -key 3f2a9c0d1e4b5a6f
+//	apikey disable-client -id mobile-sdk
+//	apikey enable-client -id mobile-sdk
+//
+// A client belongs to one tenant and its keys only reach that tenant's campaigns. Raw keys are printed once
+// and never stored. Changes reach running servers within AUTH_REFRESH_SECONDS.
+package main
+
+import (
+	"campaign/internal/domain/auth"
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/tenant"
+	"campaign/internal/infrastructure/db"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+const usage = "usage: apikey create-client|issue-key|rotate-key|revoke-key|disable-client|enable-client [flags]"
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	// Load configuration
+	cfg, err := models.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	// Connect to database
+	dbConnString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		cfg.DBHOST, cfg.DBPORT, cfg.DBUSER, cfg.DBPass, cfg.DBName)
+
+	d, err := db.Connect(dbConnString)
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer d.Close()
+
+	ctx := context.Background()
+	switch command {
+	case "create-client":
+		err = createClient(ctx, d, args)
+	case "issue-key":
+		err = issueKey(ctx, d, args, false)
+	case "rotate-key":
+		err = issueKey(ctx, d, args, true)
+	case "revoke-key":
+		err = revokeKey(ctx, d, args)
+	case "disable-client":
+		err = setEnabled(ctx, d, args, false)
+	case "enable-client":
+		err = setEnabled(ctx, d, args, true)
+	default:
+		log.Fatal(usage)
+	}
+	if err != nil {
+		log.Fatalf("Error running %s: %v", command, err)
+	}
+}
+
+func createClient(ctx context.Context, d *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("create-client", flag.ExitOnError)
+	id := flags.String("id", "", "client ID, lowercase letters, digits, '-' and '_'")
+	name := flags.String("name", "", "human readable name")
+	scopes := flags.String("scopes", auth.ScopeDeliveryRead, "comma-separated scopes: delivery:read, admin:write, track:write")
+	tenantID := flags.String("tenant", tenant.DefaultID, "tenant whose campaigns the client's keys reach")
+	flags.Parse(args)
+
+	if *id == "" || *name == "" {
+		return errors.New("-id and -name are required")
+	}
+	client := models.APIClient{ClientID: *id, TenantID: *tenantID, Name: *name, Scopes: strings.Split(*scopes, ","), Enabled: true}
+	if err := auth.ValidateScopes(client.Scopes); err != nil {
+		return err
+	}
+
+	if err := db.CreateAPIClient(ctx, d, client); err != nil {
+		return err
+	}
+	log.Printf("Created client %s of tenant %s with scopes %s", client.ClientID, client.TenantID, strings.Join(client.Scopes, ","))
+	return nil
+}
+
+// issueKey adds a key to a client. When rotating, the client's other keys expire after the overlap, which
+// gives callers that long to switch to the new key.
+func issueKey(ctx context.Context, d *sql.DB, args []string, rotate bool) error {
+	flags := flag.NewFlagSet("issue-key", flag.ExitOnError)
+	clientID := flags.String("client", "", "client ID")
+	expiresIn := flags.Duration("expires-in", 0, "lifetime of the new key; 0 never expires")
+	overlap := flags.Duration("overlap", 24*time.Hour, "with rotate-key, how long the previous keys stay valid")
+	flags.Parse(args)
+
+	if *clientID == "" {
+		return errors.New("-client is required")
+	}
+
+	now := time.Now()
+	var expiresAt, expireOthersAt *time.Time
+	if *expiresIn > 0 {
+		t := now.Add(*expiresIn)
+		expiresAt = &t
+	}
+	if rotate {
+		t := now.Add(*overlap)
+		expireOthersAt = &t
+	}
+
+	raw, key, err := auth.GenerateKey(*clientID, now, expiresAt)
+	if err != nil {
+		return err
+	}
+	if err := db.RotateAPIKey(ctx, d, key, expireOthersAt); errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("client %s not found", *clientID)
+	} else if err != nil {
+		return err
+	}
+
+	if rotate {
+		log.Printf("Issued key %s for client %s; previous keys expire at %s", key.KeyID, *clientID, expireOthersAt.Format(time.RFC3339))
+	} else {
+		log.Printf("Issued key %s for client %s", key.KeyID, *clientID)
+	}
+	// The raw key goes to stdout alone, so that it can be piped into a secret store
+	fmt.Println(raw)
+	return nil
+}
+
+func revokeKey(ctx context.Context, d *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("revoke-key", flag.ExitOnError)
+	keyID := flags.String("key", "", "key ID, the hex part after ck_")
+	flags.Parse(args)
+
+	if *keyID == "" {
+		return errors.New("-key is required")
+	}
+	if err := db.RevokeAPIKey(ctx, d, *keyID); errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("key %s not found", *keyID)
+	} else if err != nil {
+		return err
+	}
+	log.Printf("Revoked key %s", *keyID)
+	return nil
+}
+
+func setEnabled(ctx context.Context, d *sql.DB, args []string, enabled bool) error {
+	flags := flag.NewFlagSet("enable-client", flag.ExitOnError)
+	id := flags.String("id", "", "client ID")
+	flags.Parse(args)
+
+	if *id == "" {
+		return errors.New("-id is required")
+	}
+	if err := db.SetAPIClientEnabled(ctx, d, *id, enabled); errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("client %s not found", *id)
+	} else if err != nil {
+		return err
+	}
+	log.Printf("Client %s enabled: %t", *id, enabled)
+	return nil
+}
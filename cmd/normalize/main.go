@@ -0,0 +1,38 @@
+// Command normalize brings stored targeting rule values in line with the normalizers applied on write and
+// on delivery (country aliases, alpha-3 codes and names to alpha-2, operating system aliases, and so on).
+// It is idempotent; run it once after applying the migrations, and again after adding aliases.
+package main
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/infrastructure/db"
+	"context"
+	"fmt"
+	"log"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	// Load configuration
+	cfg, err := models.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	// Connect to database
+	dbConnString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		cfg.DBHOST, cfg.DBPORT, cfg.DBUSER, cfg.DBPass, cfg.DBName)
+
+	d, err := db.Connect(dbConnString)
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer d.Close()
+
+	updated, deleted, skipped, err := db.NormalizeTargetingRules(context.Background(), d)
+	if err != nil {
+		log.Fatalf("Error normalizing targeting rules: %v", err)
+	}
+	log.Printf("Normalized targeting rules: %d updated, %d duplicates deleted, %d invalid left as is", updated, deleted, skipped)
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"campaign/internal/domain/models"
+	"campaign/internal/domain/tenant"
 	"campaign/internal/infrastructure/db"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -46,7 +48,7 @@ func main() {
 
 	log.Println("Connected to database successfully")
 
-	// Seed campaigns
+	// Seed campaigns, all in the default tenant
 	if err := seedCampaigns(d); err != nil {
 		log.Fatalf("Error seeding campaigns: %v", err)
 	}
@@ -99,16 +101,16 @@ func seedCampaigns(db *sql.DB) error {
 
 	for _, campaign := range campaigns {
 		query := `
-			INSERT INTO campaigns (campaign_id, campaign_name, image_url, call_to_action, campaign_status)
-			VALUES ($1, $2, $3, $4, $5)
-			ON CONFLICT (campaign_id) DO UPDATE SET
+			INSERT INTO campaigns (tenant_id, campaign_id, campaign_name, image_url, call_to_action, campaign_status)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (tenant_id, campaign_id) DO UPDATE SET
 				campaign_name = EXCLUDED.campaign_name,
 				image_url = EXCLUDED.image_url,
 				call_to_action = EXCLUDED.call_to_action,
 				campaign_status = EXCLUDED.campaign_status,
 				udate = NOW()
 		`
-		_, err := db.Exec(query, campaign.ID, campaign.Name, campaign.ImageURL, campaign.CallToAction, campaign.Status)
+		_, err := db.Exec(query, tenant.DefaultID, campaign.ID, campaign.Name, campaign.ImageURL, campaign.CallToAction, campaign.Status)
 		if err != nil {
 			return fmt.Errorf("error inserting campaign %s: %v", campaign.ID, err)
 		}
@@ -118,7 +120,7 @@ func seedCampaigns(db *sql.DB) error {
 	return nil
 }
 
-func seedTargetingRules(db *sql.DB) error {
+func seedTargetingRules(d *sql.DB) error {
 	rules := []TargetingRule{
 		// App targeting - test_app
 		{"camp_001", "app_id", "include", "test_app"},
@@ -335,19 +337,33 @@ func seedTargetingRules(db *sql.DB) error {
 	}
 
 	for _, rule := range rules {
-		query := `
-			INSERT INTO targeting_rules (campaign_id, dimension, type, value)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (campaign_id, dimension, type, value) DO UPDATE SET
-				udate = NOW()
-		`
-		_, err := db.Exec(query, rule.CampaignID, rule.Dimension, rule.Type, rule.Value)
+		err := db.UpsertTargetingRule(context.Background(), d, tenant.DefaultID, models.TargetingRule{
+			CampaignID: rule.CampaignID,
+			Dimension:  rule.Dimension,
+			Type:       rule.Type,
+			Value:      rule.Value,
+		})
 		if err != nil {
 			return fmt.Errorf("error inserting targeting rule for campaign %s: %v", rule.CampaignID, err)
 		}
 		log.Printf("Inserted/updated targeting rule: %s %s %s %s", rule.CampaignID, rule.Dimension, rule.Type, rule.Value)
 	}
 
+	// Operator-based rules for typed targeting
+	operatorRules := []models.TargetingRule{
+		{CampaignID: "camp_018", Dimension: "app_version", Type: "include", Operator: "semver_gte", Value: "5.2.0"},
+		{CampaignID: "camp_019", Dimension: "age", Type: "include", Operator: "range", Value: "[18,34]"},
+		{CampaignID: "camp_022", Dimension: "locale", Type: "include", Operator: "prefix", Value: "en-"},
+		{CampaignID: "camp_023", Dimension: "os_version", Type: "exclude", Operator: "range", Value: "[,14)"},
+	}
+
+	for _, rule := range operatorRules {
+		if err := db.UpsertTargetingRule(context.Background(), d, tenant.DefaultID, rule); err != nil {
+			return fmt.Errorf("error inserting targeting rule for campaign %s: %v", rule.CampaignID, err)
+		}
+		log.Printf("Inserted/updated targeting rule: %s %s %s %s %s", rule.CampaignID, rule.Dimension, rule.Type, rule.Operator, rule.Value)
+	}
+
 	return nil
 }
 
@@ -360,16 +376,16 @@ func seedInactiveCampaigns(db *sql.DB) error {
 
 	for _, campaign := range inactiveCampaigns {
 		query := `
-			INSERT INTO campaigns (campaign_id, campaign_name, image_url, call_to_action, campaign_status)
-			VALUES ($1, $2, $3, $4, $5)
-			ON CONFLICT (campaign_id) DO UPDATE SET
+			INSERT INTO campaigns (tenant_id, campaign_id, campaign_name, image_url, call_to_action, campaign_status)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (tenant_id, campaign_id) DO UPDATE SET
 				campaign_name = EXCLUDED.campaign_name,
 				image_url = EXCLUDED.image_url,
 				call_to_action = EXCLUDED.call_to_action,
 				campaign_status = EXCLUDED.campaign_status,
 				udate = NOW()
 		`
-		_, err := db.Exec(query, campaign.ID, campaign.Name, campaign.ImageURL, campaign.CallToAction, campaign.Status)
+		_, err := db.Exec(query, tenant.DefaultID, campaign.ID, campaign.Name, campaign.ImageURL, campaign.CallToAction, campaign.Status)
 		if err != nil {
 			return fmt.Errorf("error inserting inactive campaign %s: %v", campaign.ID, err)
 		}
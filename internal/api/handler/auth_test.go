@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"campaign/internal/domain/auth"
+	"campaign/internal/domain/models"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reader, readerKey, err := auth.GenerateKey("reader", time.Now().Add(-time.Minute), nil)
+	require.NoError(t, err)
+	store := auth.NewStore(slog.Default(), func() ([]models.APIClient, []models.APIKey, error) {
+		clients := []models.APIClient{{ClientID: "reader", Scopes: []string{auth.ScopeDeliveryRead}, Enabled: true}}
+		return clients, []models.APIKey{readerKey}, nil
+	})
+
+	router := gin.New()
+	handler := func(c *gin.Context) {
+		client, ok := auth.FromContext(c.Request.Context())
+		require.True(t, ok)
+		c.String(http.StatusOK, client.ID+" "+c.GetString("client_id"))
+	}
+	router.GET("/delivery", APIKeyAuth(store, auth.ScopeDeliveryRead), handler)
+	router.GET("/admin", APIKeyAuth(store, auth.ScopeAdminWrite), handler)
+
+	request := func(path, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/delivery", reader)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "reader reader", w.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, request("/delivery", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("/delivery", reader+"x").Code)
+	assert.Equal(t, http.StatusForbidden, request("/admin", reader).Code)
+}
+
+func TestAdminAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keys := auth.NewKeySet(slog.Default(), func(ctx context.Context) (map[string]crypto.PublicKey, error) {
+		return map[string]crypto.PublicKey{"k1": &signingKey.PublicKey}, nil
+	}, time.Minute)
+	verifier := auth.NewVerifier(keys, auth.VerifierConfig{Issuer: "https://sso.example.com", Audience: "campaign-admin", RolesClaim: "roles"})
+
+	token := func(roles ...string) string {
+		claims := jwt.MapClaims{
+			"iss": "https://sso.example.com", "aud": "campaign-admin", "sub": "staff-1",
+			"exp": time.Now().Add(time.Hour).Unix(), "roles": roles,
+		}
+		raw := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		raw.Header["kid"] = "k1"
+		signed, err := raw.SignedString(signingKey)
+		require.NoError(t, err)
+		return signed
+	}
+
+	admin, adminKey, err := auth.GenerateKey("admin", time.Now().Add(-time.Minute), nil)
+	require.NoError(t, err)
+	store := auth.NewStore(slog.Default(), func() ([]models.APIClient, []models.APIKey, error) {
+		clients := []models.APIClient{{ClientID: "admin", Scopes: []string{auth.ScopeAdminWrite}, Enabled: true}}
+		return clients, []models.APIKey{adminKey}, nil
+	})
+
+	newRouter := func(adminAuth *AdminAuth) *gin.Engine {
+		router := gin.New()
+		group := router.Group("/admin", adminAuth.Authenticate())
+		ok := func(c *gin.Context) { c.String(http.StatusOK, c.GetString("user")) }
+		group.GET("/segments", adminAuth.Require(auth.PermissionView), ok)
+		group.POST("/segments", adminAuth.Require(auth.PermissionEdit), ok)
+		group.PUT("/campaigns/:campaign_id/status", adminAuth.Require(auth.PermissionApprove), ok)
+		return router
+	}
+	request := func(router *gin.Engine, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	bearer := func(raw string) map[string]string { return map[string]string{"Authorization": "Bearer " + raw} }
+
+	router := newRouter(NewAdminAuth(verifier, store))
+
+	// Roles map to permissions per route
+	viewer := bearer(token(auth.RoleViewer))
+	w := request(router, "GET", "/admin/segments", viewer)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "staff-1", w.Body.String())
+	assert.Equal(t, http.StatusForbidden, request(router, "POST", "/admin/segments", viewer).Code)
+
+	editor := bearer(token(auth.RoleEditor))
+	assert.Equal(t, http.StatusOK, request(router, "POST", "/admin/segments", editor).Code)
+	assert.Equal(t, http.StatusForbidden, request(router, "PUT", "/admin/campaigns/c1/status", editor).Code)
+	assert.Equal(t, http.StatusOK, request(router, "PUT", "/admin/campaigns/c1/status", bearer(token(auth.RoleApprover))).Code)
+
+	// A token without known roles authenticates but is permitted nothing
+	assert.Equal(t, http.StatusForbidden, request(router, "GET", "/admin/segments", bearer(token("guest"))).Code)
+	assert.Equal(t, http.StatusUnauthorized, request(router, "GET", "/admin/segments", bearer("not.a.token")).Code)
+
+	// API clients with admin:write may view and edit, but not approve
+	apiKey := map[string]string{APIKeyHeader: admin}
+	assert.Equal(t, http.StatusOK, request(router, "POST", "/admin/segments", apiKey).Code)
+	assert.Equal(t, http.StatusForbidden, request(router, "PUT", "/admin/campaigns/c1/status", apiKey).Code)
+	assert.Equal(t, http.StatusUnauthorized, request(router, "GET", "/admin/segments", nil).Code)
+
+	// Without API keys, a token is required
+	tokensOnly := newRouter(NewAdminAuth(verifier, nil))
+	assert.Equal(t, http.StatusUnauthorized, request(tokensOnly, "GET", "/admin/segments", nil).Code)
+
+	// Without either, admin is open
+	open := newRouter(NewAdminAuth(nil, nil))
+	assert.Equal(t, http.StatusOK, request(open, "PUT", "/admin/campaigns/c1/status", nil).Code)
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
+	"campaign/internal/infrastructure/cache"
+	"campaign/internal/infrastructure/db"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxExpressionBodyBytes = 64 << 10
+
+type CampaignHandler struct {
+	db              *sql.DB
+	memeCache       *cache.MemoryCache
+	targetingStores *tenant.Stores[*targeting.Store]
+}
+
+func NewCampaignHandler(db *sql.DB, memCache *cache.MemoryCache, stores *tenant.Stores[*targeting.Store]) *CampaignHandler {
+	return &CampaignHandler{
+		db:              db,
+		memeCache:       memCache,
+		targetingStores: stores,
+	}
+}
+
+// GetTargetingExpression returns the campaign's targeting expression, or null if it has none
+func (h *CampaignHandler) GetTargetingExpression(c *gin.Context) {
+	campaignID := c.Param("campaign_id")
+
+	campaign, err := db.GetCampaign(c.Request.Context(), h.db, tenant.FromContext(c.Request.Context()), campaignID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.ErrorJSONGin(c, http.StatusNotFound, utils.ErrCampaignNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error getting campaign", "campaign_id", campaignID, "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	var expression json.RawMessage
+	if campaign.TargetingExpression != "" {
+		expression = json.RawMessage(campaign.TargetingExpression)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"campaign_id": campaignID,
+		"expression":  expression,
+	})
+}
+
+// SetTargetingExpression validates the JSON expression in the request body and stores it on the campaign
+func (h *CampaignHandler) SetTargetingExpression(c *gin.Context) {
+	campaignID := c.Param("campaign_id")
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxExpressionBodyBytes+1))
+	if err != nil {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, "could not read request body")
+		return
+	}
+	if len(body) > maxExpressionBodyBytes {
+		utils.ErrorJSONGin(c, http.StatusRequestEntityTooLarge, "targeting expression is too large")
+		return
+	}
+
+	expression, err := targeting.ParseExpression(body)
+	if err != nil {
+		utils.ErrorJSONGinWithDetails(c, http.StatusBadRequest, utils.ErrInvalidExpression, err.Error())
+		return
+	}
+
+	// Segment conditions must name segments of the tenant, or they could never match
+	if segments := expression.Values(targeting.SegmentDimension); len(segments) > 0 {
+		ctx := c.Request.Context()
+		missing, err := db.MissingSegments(ctx, h.db, tenant.FromContext(ctx), segments)
+		if err != nil {
+			logging.FromContext(ctx).Error("error checking segments", "campaign_id", campaignID, "error", err)
+			utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+			return
+		}
+		if len(missing) > 0 {
+			utils.ErrorJSONGinWithDetails(c, http.StatusBadRequest, utils.ErrUnknownSegment, strings.Join(missing, ", "))
+			return
+		}
+	}
+
+	// Store the normalized form so that what is evaluated is what is returned
+	normalized, err := json.Marshal(expression)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error marshalling targeting expression", "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	if !h.updateExpression(c, campaignID, string(normalized)) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"campaign_id": campaignID,
+		"expression":  json.RawMessage(normalized),
+	})
+}
+
+// DeleteTargetingExpression removes the campaign's targeting expression
+func (h *CampaignHandler) DeleteTargetingExpression(c *gin.Context) {
+	if !h.updateExpression(c, c.Param("campaign_id"), "") {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetCampaignStatus activates or pauses a campaign, from a {"status": "ACTIVE"|"INACTIVE"} body. Going live
+// is the approval step, so it needs the approve permission rather than edit.
+func (h *CampaignHandler) SetCampaignStatus(c *gin.Context) {
+	campaignID := c.Param("campaign_id")
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || (body.Status != "ACTIVE" && body.Status != "INACTIVE") {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, utils.ErrInvalidStatus)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := db.UpdateCampaignStatus(ctx, h.db, tenant.FromContext(ctx), campaignID, body.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.ErrorJSONGin(c, http.StatusNotFound, utils.ErrCampaignNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error updating campaign status", "campaign_id", campaignID, "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	h.invalidate(ctx)
+	c.JSON(http.StatusOK, gin.H{
+		"campaign_id": campaignID,
+		"status":      body.Status,
+	})
+}
+
+// updateExpression persists the expression and makes it visible to delivery. It writes the error
+// response itself and reports whether the update succeeded.
+func (h *CampaignHandler) updateExpression(c *gin.Context, campaignID, expression string) bool {
+	err := db.UpdateCampaignTargetingExpression(c.Request.Context(), h.db, tenant.FromContext(c.Request.Context()), campaignID, expression)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.ErrorJSONGin(c, http.StatusNotFound, utils.ErrCampaignNotFound)
+		return false
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error updating targeting expression", "campaign_id", campaignID, "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return false
+	}
+
+	h.invalidate(c.Request.Context())
+	return true
+}
+
+// invalidate reloads the tenant's targeting snapshot and drops its cached delivery responses after a write
+func (h *CampaignHandler) invalidate(ctx context.Context) {
+	tenantID := tenant.FromContext(ctx)
+	store, err := h.targetingStores.Get(tenantID)
+	if err == nil {
+		err = store.Refresh()
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error refreshing targeting snapshot after write", "error", err)
+	}
+	h.memeCache.DeletePrefix(tenantCachePrefix(tenantID))
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"campaign/internal/infrastructure/cache"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaignHandler_SetCampaignStatusValidatesBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	campaignHandler := NewCampaignHandler(nil, cache.NewMemoryCache(), nil)
+	router := gin.New()
+	router.PUT("/campaigns/:campaign_id/status", campaignHandler.SetCampaignStatus)
+
+	// Invalid bodies are rejected before the database is touched
+	for _, body := range []string{`{"status": "PAUSED"}`, `{"status": "active"}`, `{}`, `not json`} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/campaigns/c1/status", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), "status must be ACTIVE or INACTIVE", body)
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/tenant"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+)
+
+// cursor is the keyset position encoded in an opaque next_cursor token: the last key of the previous page
+type cursor struct {
+	After string `json:"a"`
+}
+
+func encodeCursor(after string) string {
+	data, _ := json.Marshal(cursor{After: after})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the key to continue after; an empty token starts from the beginning
+func decodeCursor(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid cursor")
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.After == "" {
+		return "", fmt.Errorf("invalid cursor")
+	}
+	return c.After, nil
+}
+
+// paginateAfter returns up to limit campaigns with an ID after the given one, and the cursor for the
+// next page if more remain. campaigns must be ordered by campaign_id.
+func paginateAfter(campaigns []models.Campaign, after string, limit int) ([]models.Campaign, string) {
+	start := sort.Search(len(campaigns), func(i int) bool {
+		return campaigns[i].CampaignID > after
+	})
+
+	end := start + limit
+	if end >= len(campaigns) {
+		return campaigns[start:], ""
+	}
+	return campaigns[start:end], encodeCursor(campaigns[end-1].CampaignID)
+}
+
+// deliverCursorPage serves one keyset-paginated page of matching campaigns in a
+// {"campaigns": [...], "next_cursor": "..."} envelope. Unlike page/limit, a cursor never skips or repeats
+// campaigns when campaigns are added or removed between requests.
+func (h *DeliveryHandler) deliverCursorPage(c *gin.Context, params map[string][]string, token string, encoding *responseEncoding) {
+	if c.Query("page") != "" {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, "page and cursor cannot be combined")
+		return
+	}
+
+	after, err := decodeCursor(token)
+	if err != nil {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, limit, err := h.parsePaginationParams(c)
+	if err != nil {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, ttl, hit, err := h.cursorPage(c.Request.Context(), params, after, limit, encoding)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error loading targeting snapshot", "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	c.Header("X-Cache-Type", cacheType(hit))
+	serveCached(c, data, ttl, encoding.contentType)
+}
+
+// cursorPage returns one keyset page in the given encoding from the response cache, matching and caching it on a miss.
+// It also returns how long the bytes stay cached and whether they came from the cache.
+func (h *DeliveryHandler) cursorPage(ctx context.Context, params map[string][]string, after string, limit int, encoding *responseEncoding) ([]byte, time.Duration, bool, error) {
+	cacheKey := tenantCacheKey(ctx, fmt.Sprintf("delivery_cursor:%s:after%s:limit%d%s", h.cacheKeyParams(ctx, params), after, limit, encoding.cacheSuffix))
+	if cachedData, ids, ttl, found := h.memeCache.GetWithMetaContext(ctx, cacheKey); found {
+		logging.FromContext(ctx).Debug("cache hit", "key", cacheKey)
+		utils.RecordCacheHit()
+		utils.RecordDelivery(tenant.FromContext(ctx), params, ids)
+		return cachedData, ttl, true, nil
+	}
+
+	logging.FromContext(ctx).Debug("cache miss", "key", cacheKey)
+	utils.RecordCacheMiss()
+
+	snapshot, err := h.snapshot(ctx)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	campaigns, nextCursor := paginateAfter(snapshot.Match(params), after, limit)
+	response := models.DeliveryPage{
+		Campaigns:  h.buildResponse(campaigns),
+		NextCursor: nextCursor,
+	}
+
+	responseBytes, err := encoding.encode(response, func() proto.Message {
+		return deliveryProto(response.Campaigns, response.NextCursor)
+	})
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	ids := campaignIDsOf(response.Campaigns)
+	ttl := responseTTL(snapshot)
+	h.memeCache.SetWithMetaContext(ctx, cacheKey, responseBytes, ids, ttl)
+	utils.RecordDelivery(tenant.FromContext(ctx), params, ids)
+	return responseBytes, ttl, false, nil
+}
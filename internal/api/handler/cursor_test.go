@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/infrastructure/cache"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	after, err := decodeCursor(encodeCursor("camp_042"))
+	assert.NoError(t, err)
+	assert.Equal(t, "camp_042", after)
+
+	after, err = decodeCursor("")
+	assert.NoError(t, err)
+	assert.Equal(t, "", after)
+
+	for _, token := range []string{"not base64!", "bm90IGpzb24", encodeCursor("")} {
+		_, err = decodeCursor(token)
+		assert.Error(t, err, token)
+	}
+}
+
+func TestDeliveryHandler_CursorPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	campaigns := []models.Campaign{
+		{CampaignID: "camp_001"},
+		{CampaignID: "camp_002"},
+		{CampaignID: "camp_004"},
+	}
+	store := targeting.NewStore(slog.Default(), func() ([]models.Campaign, []models.TargetingRule, error) {
+		return campaigns, nil, nil
+	})
+	handler := NewDeliveryHandlerWithStore(nil, cache.NewMemoryCache(), store, nil)
+
+	fetch := func(token string) models.DeliveryPage {
+		query := url.Values{"app_id": {"test_app"}, "country": {"US"}, "os": {"android"}, "limit": {"2"}, "cursor": {token}}
+
+		req, _ := http.NewRequest("GET", "/delivery?"+query.Encode(), nil)
+		w := serve(handler.DeliveryHandler, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var page models.DeliveryPage
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page
+	}
+
+	first := fetch("")
+	assert.Len(t, first.Campaigns, 2)
+	assert.Equal(t, "camp_002", first.Campaigns[1].CampaignID)
+	assert.NotEmpty(t, first.NextCursor)
+
+	// A campaign added before the cursor position must not shift the next page
+	campaigns = append([]models.Campaign{{CampaignID: "camp_000"}}, campaigns...)
+	assert.NoError(t, store.Refresh())
+
+	second := fetch(first.NextCursor)
+	assert.Len(t, second.Campaigns, 1)
+	assert.Equal(t, "camp_004", second.Campaigns[0].CampaignID)
+	assert.Empty(t, second.NextCursor)
+}
+
+func TestDeliveryHandler_InvalidCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		queryParams   string
+		expectedError string
+	}{
+		{name: "malformed cursor", queryParams: "app_id=test_app&country=US&os=android&cursor=%21%21", expectedError: "invalid cursor"},
+		{name: "cursor with page", queryParams: "app_id=test_app&country=US&os=android&cursor=&page=2", expectedError: "page and cursor cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewDeliveryHandlerWithStore(nil, cache.NewMemoryCache(), nil, nil)
+
+			req, _ := http.NewRequest("GET", "/delivery?"+tt.queryParams, nil)
+			w := serve(handler.DeliveryHandler, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedError, response["error"])
+		})
+	}
+}
+
+func TestGetAvailableValues_PaginationIsOptIn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockCache := cache.NewMemoryCache()
+	handler := NewDeliveryHandler(nil, mockCache)
+
+	full, _ := json.Marshal(valuesResponse{Dimension: "country", Values: []string{"CA", "GB", "US"}})
+	page, _ := json.Marshal(valuesResponse{Dimension: "country", Values: []string{"CA"}, NextCursor: encodeCursor("CA")})
+	mockCache.Set("tenant:default:dimension_values:country:after:limit0", full, 5*time.Minute)
+	mockCache.Set("tenant:default:dimension_values:country:after:limit1", page, 5*time.Minute)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []byte
+	}{
+		{name: "full list without limit or cursor", query: "", want: full},
+		{name: "paginated with limit", query: "?limit=1", want: page},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/dimensions/:dimension/values", handler.GetAvailableValues)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/dimensions/country/values"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, string(tt.want), w.Body.String())
+		})
+	}
+}
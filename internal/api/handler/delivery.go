@@ -1,41 +1,103 @@
 package handler
 
 import (
+	"campaign/internal/api/rpc/deliverypb"
 	"campaign/internal/domain/models"
+	"campaign/internal/domain/reach"
+	"campaign/internal/domain/segment"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
 	"campaign/internal/infrastructure/cache"
 	"campaign/internal/infrastructure/db"
+	"campaign/pkg/logging"
 	"campaign/pkg/utils"
+	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
 	cacheTTLE = 5 * time.Minute
 )
 
+// responseTTL is how long a delivery response matched from snapshot may be cached: cacheTTLE, cut short
+// when a campaign's flight starts or ends sooner, so that cached responses follow flight windows
+func responseTTL(snapshot *targeting.Snapshot) time.Duration {
+	ttl := cacheTTLE
+	if next := snapshot.NextFlightChange(time.Now()); !next.IsZero() {
+		ttl = min(ttl, time.Until(next))
+	}
+	return ttl
+}
+
+// reservedParams are query parameters that control the response rather than targeting
+var reservedParams = map[string]bool{
+	"page":          true,
+	"limit":         true,
+	"cursor":        true,
+	"include_total": true,
+	"campaign_id":   true,
+}
+
 type DeliveryHandler struct {
-	db        *sql.DB
-	memeCache *cache.MemoryCache
+	db              *sql.DB
+	memeCache       *cache.MemoryCache
+	targetingStores *tenant.Stores[*targeting.Store]
+	segmentStores   *tenant.Stores[*segment.Store]
+	enrichers       []Enricher
+	requestLog      *reach.Log
 	//redis *redis.Client
 }
 
-func NewDeliveryHandler(db *sql.DB, memCache *cache.MemoryCache) *DeliveryHandler {
+// NewDeliveryHandler creates a handler whose targeting snapshots are loaded from db per tenant on first use
+func NewDeliveryHandler(d *sql.DB, memCache *cache.MemoryCache) *DeliveryHandler {
+	stores := tenant.NewStores(nil, func(tenantID string) *targeting.Store {
+		return targeting.NewStore(slog.Default(), func() ([]models.Campaign, []models.TargetingRule, error) {
+			return db.LoadTargetingData(context.Background(), d, tenantID)
+		})
+	})
+	return NewTenantDeliveryHandler(d, memCache, stores, nil)
+}
+
+// NewDeliveryHandlerWithStore creates a handler that matches the campaigns of every tenant against one
+// shared targeting store. segments may be nil to disable audience segments. Enrichers run in order and
+// only fill dimensions missing from the query string.
+func NewDeliveryHandlerWithStore(db *sql.DB, memCache *cache.MemoryCache, store *targeting.Store, segments *segment.Store, enrichers ...Enricher) *DeliveryHandler {
+	var segmentStores *tenant.Stores[*segment.Store]
+	if segments != nil {
+		segmentStores = tenant.Shared(segments)
+	}
+	return NewTenantDeliveryHandler(db, memCache, tenant.Shared(store), segmentStores, enrichers...)
+}
+
+// NewTenantDeliveryHandler creates a handler that matches each request against the targeting store of its
+// tenant. segments may be nil to disable audience segments.
+func NewTenantDeliveryHandler(db *sql.DB, memCache *cache.MemoryCache, stores *tenant.Stores[*targeting.Store], segments *tenant.Stores[*segment.Store], enrichers ...Enricher) *DeliveryHandler {
 	return &DeliveryHandler{
-		db:        db,
-		memeCache: memCache,
+		db:              db,
+		memeCache:       memCache,
+		targetingStores: stores,
+		segmentStores:   segments,
+		enrichers:       enrichers,
 		//redis: redis,
 	}
 }
 
+// SetRequestLog samples the targeting of every valid delivery request into requestLog for reach estimates
+func (h *DeliveryHandler) SetRequestLog(requestLog *reach.Log) {
+	h.requestLog = requestLog
+}
+
 func (h *DeliveryHandler) DeliveryHandler(c *gin.Context) {
 	// Start timing for latency measurement
 	start := time.Now()
@@ -45,12 +107,25 @@ func (h *DeliveryHandler) DeliveryHandler(c *gin.Context) {
 		utils.DeliveryAPILatency.Observe(duration.Seconds())
 	}()
 
+	ctx := c.Request.Context()
+	logger := logging.FromContext(ctx)
+
 	// Extract all query parameters for dynamic targeting
 	targetingParams := h.extractTargetingParams(c)
+	if !h.resolveTargeting(c, targetingParams) {
+		return
+	}
+	h.recordSample(ctx, targetingParams)
 
-	// Validate required parameters (keeping backward compatibility)
-	if err := h.validateRequiredParams(targetingParams); err != nil {
-		utils.ErrorJSONGin(c, http.StatusBadRequest, err.Error())
+	// Responses vary by Accept: JSON, protobuf or MessagePack
+	encoding := acceptEncoding(c, allEncodings...)
+	if encoding == nil {
+		return
+	}
+
+	// A cursor parameter (empty for the first page) selects keyset pagination
+	if token, ok := c.GetQuery("cursor"); ok {
+		h.deliverCursorPage(c, targetingParams, token, encoding)
 		return
 	}
 
@@ -64,75 +139,194 @@ func (h *DeliveryHandler) DeliveryHandler(c *gin.Context) {
 	offset := (page - 1) * limit
 
 	// Generate cache key
-	cacheKey := h.generateCacheKey(targetingParams, page, limit)
+	cacheKey := tenantCacheKey(ctx, h.generateCacheKey(ctx, targetingParams, page, limit)+encoding.cacheSuffix)
 
 	// Try to get from cache first
-	if cachedData, found := h.memeCache.Get(cacheKey); found {
-		log.Printf("In-memory Cache HIT for key: %s", cacheKey)
+	// The served campaign IDs are cached next to the response, so a hit is counted without decoding it
+	if cachedData, ids, ttl, found := h.memeCache.GetWithMetaContext(ctx, cacheKey); found {
+		logger.Debug("cache hit", "key", cacheKey)
 		c.Header("X-Cache-Type", "IN_MEMORY_HIT")
-		c.Data(http.StatusOK, "application/json", cachedData)
+		serveCached(c, cachedData, ttl, encoding.contentType)
 		utils.RecordCacheHit()
+		utils.RecordDelivery(tenant.FromContext(ctx), targetingParams, ids)
 		return
 	}
 
-	log.Printf("In-memory Cache MISS for key: %s", cacheKey)
+	logger.Debug("cache miss", "key", cacheKey)
 	utils.RecordCacheMiss()
 	c.Header("X-Cache-Type", "MISS")
 
-	// Convert targeting params to dimensions
-	dimensions := h.convertToTargetingDimensions(targetingParams)
-
-	// Get data from database using dynamic approach
-	dbCampaigns, err := db.GetTargetedCampaignsDynamic(h.db, dimensions, limit, offset)
+	// Match campaigns against the tenant's in-memory targeting snapshot
+	snapshot, err := h.snapshot(ctx)
 	if err != nil {
-		log.Printf("Database error: %v", err)
+		logger.Error("error loading targeting snapshot", "error", err)
 		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
 		return
 	}
 
+	campaigns := paginate(snapshot.Match(targetingParams), offset, limit)
+
 	// Build response
-	response := h.buildResponse(dbCampaigns)
+	response := h.buildResponse(campaigns)
 
-	// Marshal response for caching and sending
-	responseBytes, err := json.Marshal(response)
+	// Encode response for caching and sending
+	responseBytes, err := encoding.encode(response, func() proto.Message { return deliveryProto(response, "") })
 	if err != nil {
-		log.Printf("Error marshalling delivery response: %v", err)
+		logger.Error("error marshalling delivery response", "error", err)
 		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
 		return
 	}
 
 	// Cache the response
-	h.memeCache.Set(cacheKey, responseBytes, cacheTTLE)
-	log.Printf("Successfully stored response in in-memory cache for key: %s", cacheKey)
+	ids := campaignIDsOf(response)
+	ttl := responseTTL(snapshot)
+	h.memeCache.SetWithMetaContext(ctx, cacheKey, responseBytes, ids, ttl)
+	logger.Debug("response cached", "key", cacheKey)
 
-	c.JSON(http.StatusOK, response)
+	utils.RecordDelivery(tenant.FromContext(ctx), targetingParams, ids)
+
+	serveCached(c, responseBytes, ttl, encoding.contentType)
 }
 
-// extractTargetingParams extracts all targeting parameters from the request
-func (h *DeliveryHandler) extractTargetingParams(c *gin.Context) map[string]string {
-	params := make(map[string]string)
+// extractTargetingParams extracts all targeting parameters from the request, normalized per dimension.
+// A dimension may be repeated (e.g. language=en&language=es); values are de-duplicated and sorted.
+func (h *DeliveryHandler) extractTargetingParams(c *gin.Context) map[string][]string {
+	params := make(map[string][]string)
 
 	// Extract all query parameters that could be targeting dimensions
 	for key, values := range c.Request.URL.Query() {
-		// Skip pagination parameters
-		if key == "page" || key == "limit" {
+		// Skip pagination and response options
+		if reservedParams[key] {
 			continue
 		}
 
-		if len(values) > 0 && strings.TrimSpace(values[0]) != "" {
-			params[key] = strings.TrimSpace(values[0])
+		if normalized := normalizeValues(key, values); len(normalized) > 0 {
+			params[key] = normalized
+		}
+	}
+
+	// Coordinates are matched as a single "lat,lon" location dimension
+	if lat, lon := c.Query("lat"), c.Query("lon"); lat != "" && lon != "" {
+		delete(params, "lat")
+		delete(params, "lon")
+		if location := targeting.Normalize("location", lat+","+lon); location != "" {
+			params["location"] = []string{location}
 		}
 	}
 
 	return params
 }
 
+// normalizeValues normalizes the raw values of a dimension, dropping empty values and duplicates, and sorts them
+func normalizeValues(dimension string, values []string) []string {
+	var normalized []string
+	for _, raw := range values {
+		if value := targeting.Normalize(dimension, raw); value != "" && !slices.Contains(normalized, value) {
+			normalized = append(normalized, value)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// resolveTargeting completes the request dimensions with derived values and audience segments, then
+// validates them. It writes the error response itself and reports whether delivery can proceed.
+func (h *DeliveryHandler) resolveTargeting(c *gin.Context, params map[string][]string) bool {
+	// Derive missing dimensions (country from the client IP, os from the User-Agent, ...)
+	h.enrichTargetingParams(c, params)
+
+	if err := h.prepareTargeting(c.Request.Context(), params); err != nil {
+		writeServiceError(c, err)
+		return false
+	}
+
+	return true
+}
+
+// prepareTargeting resolves audience segments and validates the required dimensions. It is shared by the
+// HTTP and gRPC transports and by explanations; errors caused by the request are *RequestError.
+func (h *DeliveryHandler) prepareTargeting(ctx context.Context, params map[string][]string) error {
+	// Replace user_id with the user's audience segments
+	if err := h.resolveSegments(ctx, params); err != nil {
+		return fmt.Errorf("error resolving segments: %w", err)
+	}
+
+	// Validate required parameters (keeping backward compatibility)
+	if err := h.validateRequiredParams(params); err != nil {
+		return &RequestError{Message: err.Error()}
+	}
+
+	return nil
+}
+
+// recordSample samples a delivery request for reach estimates. It is only called by the HTTP delivery
+// handlers, so that explanations and other callers do not skew the sample. Only the dimensions reach can
+// use are kept: the required ones and those the tenant's campaigns target.
+func (h *DeliveryHandler) recordSample(ctx context.Context, params map[string][]string) {
+	if h.requestLog == nil {
+		return
+	}
+
+	dimensions := make(map[string][]string, len(params))
+	for dimension, values := range params {
+		if slices.Contains(utils.TargetingDimensions, dimension) || h.targets(ctx, dimension) {
+			dimensions[dimension] = values
+		}
+	}
+	h.requestLog.Record(tenant.FromContext(ctx), dimensions)
+}
+
+// resolveSegments replaces the request's user_id with the segments the user belongs to, so that responses
+// are cached per segment combination rather than per user. Clients cannot assert segments themselves.
+func (h *DeliveryHandler) resolveSegments(ctx context.Context, params map[string][]string) error {
+	userIDs := params["user_id"]
+	delete(params, "user_id")
+	delete(params, targeting.SegmentDimension)
+
+	if h.segmentStores == nil || len(userIDs) == 0 {
+		return nil
+	}
+
+	store, err := h.segmentStores.Get(tenant.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	membership, err := store.Membership()
+	if err != nil {
+		return err
+	}
+
+	if segments := membership.Lookup(userIDs[0]); len(segments) > 0 {
+		params[targeting.SegmentDimension] = segments
+	}
+	return nil
+}
+
+// snapshot returns the targeting snapshot of the request's tenant
+func (h *DeliveryHandler) snapshot(ctx context.Context) (*targeting.Snapshot, error) {
+	store, err := h.targetingStores.Get(tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return store.Snapshot()
+}
+
+// targets reports whether a campaign of the request's tenant targets dimension. Without a snapshot it
+// answers true, so that the dimension is kept wherever it might matter.
+func (h *DeliveryHandler) targets(ctx context.Context, dimension string) bool {
+	snapshot, err := h.snapshot(ctx)
+	if err != nil {
+		return true
+	}
+	return snapshot.Targets(dimension)
+}
+
 // validateRequiredParams validates the required parameters (backward compatibility)
-func (h *DeliveryHandler) validateRequiredParams(params map[string]string) error {
+func (h *DeliveryHandler) validateRequiredParams(params map[string][]string) error {
 	requiredParams := []string{"app_id", "country", "os"}
 
 	for _, param := range requiredParams {
-		if value, exists := params[param]; !exists || value == "" {
+		if values, exists := params[param]; !exists || len(values) == 0 {
 			switch param {
 			case "app_id":
 				return fmt.Errorf(utils.ErrMissingApp)
@@ -147,23 +341,18 @@ func (h *DeliveryHandler) validateRequiredParams(params map[string]string) error
 	return nil
 }
 
-// convertToTargetingDimensions converts query parameters to targeting dimensions
-func (h *DeliveryHandler) convertToTargetingDimensions(params map[string]string) []db.TargetingDimension {
-	var dimensions []db.TargetingDimension
-
-	// Define known targeting dimensions (can be extended)
-	knownDimensions := []string{"app_id", "country", "os", "device_type", "language", "timezone", "age_group", "gender"}
+// paginate returns the page of campaigns starting at offset
+func paginate(campaigns []models.Campaign, offset, limit int) []models.Campaign {
+	if offset >= len(campaigns) {
+		return nil
+	}
 
-	for _, dim := range knownDimensions {
-		if value, exists := params[dim]; exists && value != "" {
-			dimensions = append(dimensions, db.TargetingDimension{
-				Dimension: dim,
-				Value:     value,
-			})
-		}
+	end := offset + limit
+	if end > len(campaigns) {
+		end = len(campaigns)
 	}
 
-	return dimensions
+	return campaigns[offset:end]
 }
 
 func (h *DeliveryHandler) parsePaginationParams(c *gin.Context) (page, limit int, err error) {
@@ -183,24 +372,35 @@ func (h *DeliveryHandler) parsePaginationParams(c *gin.Context) (page, limit int
 	return page, limit, nil
 }
 
-func (h *DeliveryHandler) generateCacheKey(params map[string]string, page, limit int) string {
+func (h *DeliveryHandler) generateCacheKey(ctx context.Context, params map[string][]string, page, limit int) string {
+	return fmt.Sprintf("delivery:%s:page%d:limit%d", h.cacheKeyParams(ctx, params), page, limit)
+}
+
+// sparseKeyDimensions take many distinct values but are rarely targeted. They are left out of cache keys
+// unless a campaign of the tenant targets them, since they would split the cache without changing a response.
+var sparseKeyDimensions = []string{"browser", "os_version"}
+
+// cacheKeyParams renders the targeting dimensions in a canonical order for use in cache keys
+func (h *DeliveryHandler) cacheKeyParams(ctx context.Context, params map[string][]string) string {
 	// Sort parameters for consistent cache keys
 	var keys []string
 	for k := range params {
+		if slices.Contains(sparseKeyDimensions, k) && !h.targets(ctx, k) {
+			continue
+		}
 		keys = append(keys, k)
 	}
 
 	// Actually sort the keys for consistent cache keys
 	sort.Strings(keys)
 
-	// Build sorted parameter string
+	// Build sorted parameter string; multi-valued dimensions are already sorted by extractTargetingParams
 	var paramParts []string
 	for _, key := range keys {
-		paramParts = append(paramParts, fmt.Sprintf("%s:%s", key, params[key]))
+		paramParts = append(paramParts, fmt.Sprintf("%s:%s", key, strings.Join(params[key], ",")))
 	}
 
-	paramString := strings.Join(paramParts, ":")
-	return fmt.Sprintf("delivery:%s:page%d:limit%d", paramString, page, limit)
+	return strings.Join(paramParts, ":")
 }
 
 func (h *DeliveryHandler) buildResponse(campaigns []models.Campaign) []models.DeliveryResponse {
@@ -219,19 +419,21 @@ func (h *DeliveryHandler) buildResponse(campaigns []models.Campaign) []models.De
 
 // GetAvailableDimensions returns all available targeting dimensions
 func (h *DeliveryHandler) GetAvailableDimensions(c *gin.Context) {
-	dimensions, err := db.GetAvailableDimensions(h.db)
+	h.serveDiscovery(c, dimensionsCacheKey, h.loadDimensions)
+}
+
+const dimensionsCacheKey = "dimensions"
+
+func (h *DeliveryHandler) loadDimensions(ctx context.Context) (discoveryResponse, error) {
+	dimensions, err := db.GetAvailableDimensions(ctx, h.db, tenant.FromContext(ctx))
 	if err != nil {
-		log.Printf("Error getting available dimensions: %v", err)
-		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
-		return
+		return nil, err
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"dimensions": dimensions,
-	})
+	return dimensionsResponse{Dimensions: dimensions}, nil
 }
 
-// GetAvailableValues returns all available values for a specific dimension
+// GetAvailableValues returns the available values for a specific dimension in order. Pagination is opt-in:
+// with limit or cursor the values are cursor-paginated, otherwise the full list is returned as it always was.
 func (h *DeliveryHandler) GetAvailableValues(c *gin.Context) {
 	dimension := c.Param("dimension")
 	if dimension == "" {
@@ -239,15 +441,129 @@ func (h *DeliveryHandler) GetAvailableValues(c *gin.Context) {
 		return
 	}
 
-	values, err := db.GetAvailableValuesForDimension(h.db, dimension)
+	after, err := decodeCursor(c.Query("cursor"))
 	if err != nil {
-		log.Printf("Error getting available values for dimension %s: %v", dimension, err)
+		utils.ErrorJSONGin(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit := 0
+	limitStr, hasLimit := c.GetQuery("limit")
+	if _, hasCursor := c.GetQuery("cursor"); hasLimit || hasCursor {
+		if !hasLimit {
+			limitStr = strconv.Itoa(utils.DefaultValuesPageLimit)
+		}
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > utils.MaxValuesPageLimit {
+			utils.ErrorJSONGin(c, http.StatusBadRequest, fmt.Sprintf("invalid limit parameter: %s", limitStr))
+			return
+		}
+	}
+
+	cacheKey, load := h.valuesLoader(dimension, after, limit)
+	h.serveDiscovery(c, cacheKey, load)
+}
+
+// discoveryResponse is a discovery response body; message is its protobuf form, the one gRPC returns
+type discoveryResponse interface {
+	message() proto.Message
+}
+
+type dimensionsResponse struct {
+	Dimensions []string `json:"dimensions"`
+}
+
+func (r dimensionsResponse) message() proto.Message {
+	return &deliverypb.ListDimensionsResponse{Dimensions: r.Dimensions}
+}
+
+type valuesResponse struct {
+	Dimension  string   `json:"dimension"`
+	Values     []string `json:"values"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+func (r valuesResponse) message() proto.Message {
+	return &deliverypb.ListValuesResponse{Values: r.Values, NextCursor: r.NextCursor}
+}
+
+// discoveryLoader loads a discovery response from the database on a cache miss
+type discoveryLoader func(ctx context.Context) (discoveryResponse, error)
+
+// valuesLoader returns the cache key and loader of one page of a dimension's values; a limit of 0 loads
+// every value after the cursor
+func (h *DeliveryHandler) valuesLoader(dimension, after string, limit int) (string, discoveryLoader) {
+	cacheKey := fmt.Sprintf("dimension_values:%s:after%s:limit%d", dimension, after, limit)
+	return cacheKey, func(ctx context.Context) (discoveryResponse, error) {
+		// Fetch one extra value to know whether another page follows
+		fetch := limit
+		if limit > 0 {
+			fetch = limit + 1
+		}
+		values, err := db.GetAvailableValuesForDimension(ctx, h.db, tenant.FromContext(ctx), dimension, after, fetch)
+		if err != nil {
+			return nil, fmt.Errorf("dimension %s: %w", dimension, err)
+		}
+
+		response := valuesResponse{Dimension: dimension, Values: values}
+		if limit > 0 && len(values) > limit {
+			response.Values = values[:limit]
+			response.NextCursor = encodeCursor(values[limit-1])
+		}
+		return response, nil
+	}
+}
+
+// serveDiscovery serves a discovery response from the response cache, loading and caching it on a miss,
+// so that polling clients can revalidate it with If-None-Match
+func (h *DeliveryHandler) serveDiscovery(c *gin.Context, cacheKey string, load discoveryLoader) {
+	encoding := acceptEncoding(c, allEncodings...)
+	if encoding == nil {
+		return
+	}
+
+	data, ttl, hit, err := h.discoveryData(c.Request.Context(), cacheKey, load, encoding)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error getting discovery data", "key", cacheKey, "error", err)
 		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"dimension": dimension,
-		"values":    values,
-	})
+	c.Header("X-Cache-Type", cacheType(hit))
+	serveCached(c, data, ttl, encoding.contentType)
+}
+
+// discoveryData returns a discovery response in the given encoding from the response cache, loading and
+// caching it on a miss. It also returns how long the bytes stay cached and whether they came from the cache.
+func (h *DeliveryHandler) discoveryData(ctx context.Context, cacheKey string, load discoveryLoader, encoding *responseEncoding) ([]byte, time.Duration, bool, error) {
+	cacheKey = tenantCacheKey(ctx, cacheKey+encoding.cacheSuffix)
+	if cachedData, ttl, found := h.memeCache.GetWithTTLContext(ctx, cacheKey); found {
+		logging.FromContext(ctx).Debug("cache hit", "key", cacheKey)
+		utils.RecordCacheHit()
+		return cachedData, ttl, true, nil
+	}
+
+	logging.FromContext(ctx).Debug("cache miss", "key", cacheKey)
+	utils.RecordCacheMiss()
+
+	response, err := load(ctx)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	responseBytes, err := encoding.encode(response, response.message)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	h.memeCache.SetContext(ctx, cacheKey, responseBytes, cacheTTLE)
+	return responseBytes, cacheTTLE, false, nil
+}
+
+// cacheType is the X-Cache-Type header value for a cache lookup
+func cacheType(hit bool) string {
+	if hit {
+		return "IN_MEMORY_HIT"
+	}
+	return "MISS"
 }
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	maxDeliveryBodyBytes = 64 << 10
+	maxPlacements        = 20
+
+	// placementDimension lets campaigns target specific slots. It is set per placement in batch requests.
+	placementDimension = "placement"
+)
+
+// BatchDeliveryHandler fills several placements in one round-trip from a JSON request body.
+// Placements are filled in request order and a campaign is served in at most one placement.
+func (h *DeliveryHandler) BatchDeliveryHandler(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		utils.DeliveryAPILatency.Observe(time.Since(start).Seconds())
+	}()
+
+	var req models.DeliveryRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, maxDeliveryBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.ErrorJSONGin(c, http.StatusRequestEntityTooLarge, "request body is too large")
+			return
+		}
+		utils.ErrorJSONGinWithDetails(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	placements, err := validatePlacements(req.Placements)
+	if err != nil {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	targetingParams := extractBodyParams(&req)
+	if !h.resolveTargeting(c, targetingParams) {
+		return
+	}
+	h.recordSample(c.Request.Context(), targetingParams)
+
+	encoding := acceptEncoding(c, allEncodings...)
+	if encoding == nil {
+		return
+	}
+
+	data, hit, err := h.batchPage(c.Request.Context(), targetingParams, placements, encoding)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error filling placements", "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	c.Header("X-Cache-Type", cacheType(hit))
+	c.Data(http.StatusOK, encoding.contentType, data)
+}
+
+// batchPage returns the filled placements in the given encoding from the response cache, filling and caching
+// them on a miss
+func (h *DeliveryHandler) batchPage(ctx context.Context, params map[string][]string, placements []models.Placement, encoding *responseEncoding) ([]byte, bool, error) {
+	cacheKey := tenantCacheKey(ctx, h.generateBatchCacheKey(ctx, params, placements)+encoding.cacheSuffix)
+	if cachedData, ids, _, found := h.memeCache.GetWithMetaContext(ctx, cacheKey); found {
+		logging.FromContext(ctx).Debug("cache hit", "key", cacheKey)
+		utils.RecordCacheHit()
+		utils.RecordDelivery(tenant.FromContext(ctx), params, ids)
+		return cachedData, true, nil
+	}
+
+	logging.FromContext(ctx).Debug("cache miss", "key", cacheKey)
+	utils.RecordCacheMiss()
+
+	snapshot, err := h.snapshot(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	response := h.fillPlacements(snapshot, params, placements)
+	responseBytes, err := encoding.encode(response, func() proto.Message { return batchProto(response) })
+	if err != nil {
+		return nil, false, err
+	}
+
+	var ids []string
+	for _, placement := range response.Placements {
+		ids = append(ids, campaignIDsOf(placement.Campaigns)...)
+	}
+	h.memeCache.SetWithMetaContext(ctx, cacheKey, responseBytes, ids, responseTTL(snapshot))
+	utils.RecordDelivery(tenant.FromContext(ctx), params, ids)
+	return responseBytes, false, nil
+}
+
+// validatePlacements checks placement IDs and limits, applying the default limit where none is given
+func validatePlacements(placements []models.Placement) ([]models.Placement, error) {
+	if len(placements) == 0 {
+		return nil, fmt.Errorf("at least one placement is required")
+	}
+	if len(placements) > maxPlacements {
+		return nil, fmt.Errorf("at most %d placements are allowed", maxPlacements)
+	}
+
+	validated := make([]models.Placement, 0, len(placements))
+	seen := make(map[string]bool, len(placements))
+	for _, placement := range placements {
+		placement.PlacementID = targeting.Normalize(placementDimension, placement.PlacementID)
+		if placement.PlacementID == "" {
+			return nil, fmt.Errorf("placement_id is required")
+		}
+		if seen[placement.PlacementID] {
+			return nil, fmt.Errorf("duplicate placement_id: %s", placement.PlacementID)
+		}
+		seen[placement.PlacementID] = true
+
+		if placement.Limit == 0 {
+			placement.Limit = utils.DefaultApiPageLimit
+		}
+		if placement.Limit < 1 || placement.Limit > 100 {
+			return nil, fmt.Errorf("invalid limit for placement %s: %d", placement.PlacementID, placement.Limit)
+		}
+
+		validated = append(validated, placement)
+	}
+
+	return validated, nil
+}
+
+// extractBodyParams builds the normalized targeting dimensions of a JSON delivery request.
+// Top-level app_id, country and os take precedence over the same keys in dimensions.
+func extractBodyParams(req *models.DeliveryRequest) map[string][]string {
+	params := make(map[string][]string)
+
+	for dimension, values := range req.Dimensions {
+		if normalized := normalizeValues(dimension, values); len(normalized) > 0 {
+			params[dimension] = normalized
+		}
+	}
+
+	for dimension, value := range map[string]string{"app_id": req.AppID, "country": req.Country, "os": req.OS} {
+		if normalized := normalizeValues(dimension, []string{value}); len(normalized) > 0 {
+			params[dimension] = normalized
+		}
+	}
+
+	// The placement dimension is set per slot when the placements are filled
+	delete(params, placementDimension)
+
+	if user := req.User; user != nil {
+		if userID := strings.TrimSpace(user.UserID); userID != "" {
+			params["user_id"] = []string{userID}
+		}
+		if user.Lat != nil && user.Lon != nil {
+			coordinates := strconv.FormatFloat(*user.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(*user.Lon, 'f', -1, 64)
+			if location := targeting.Normalize("location", coordinates); location != "" {
+				params["location"] = []string{location}
+			}
+		}
+	}
+
+	return params
+}
+
+// fillPlacements matches each placement in order and skips campaigns already served in an earlier one
+func (h *DeliveryHandler) fillPlacements(snapshot *targeting.Snapshot, params map[string][]string, placements []models.Placement) models.BatchDeliveryResponse {
+	response := models.BatchDeliveryResponse{
+		Placements: make([]models.PlacementResponse, 0, len(placements)),
+	}
+
+	served := make(map[string]bool)
+	placementParams := make(map[string][]string, len(params)+1)
+	for dimension, values := range params {
+		placementParams[dimension] = values
+	}
+
+	for _, placement := range placements {
+		placementParams[placementDimension] = []string{placement.PlacementID}
+
+		var campaigns []models.Campaign
+		for _, campaign := range snapshot.Match(placementParams) {
+			if len(campaigns) == placement.Limit {
+				break
+			}
+			if served[campaign.CampaignID] {
+				continue
+			}
+			served[campaign.CampaignID] = true
+			campaigns = append(campaigns, campaign)
+		}
+
+		response.Placements = append(response.Placements, models.PlacementResponse{
+			PlacementID: placement.PlacementID,
+			Campaigns:   h.buildResponse(campaigns),
+		})
+	}
+
+	return response
+}
+
+// generateBatchCacheKey includes the placements in order, since the order decides deduplication
+func (h *DeliveryHandler) generateBatchCacheKey(ctx context.Context, params map[string][]string, placements []models.Placement) string {
+	parts := make([]string, 0, len(placements))
+	for _, placement := range placements {
+		parts = append(parts, fmt.Sprintf("%s=%d", placement.PlacementID, placement.Limit))
+	}
+	return fmt.Sprintf("delivery_batch:%s:placements:%s", h.cacheKeyParams(ctx, params), strings.Join(parts, ","))
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/infrastructure/cache"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchDeliveryHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	campaigns := []models.Campaign{
+		{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"},
+		{CampaignID: "camp_002", ImageURL: "b.jpg", CallToAction: "B"},
+		{CampaignID: "camp_003", ImageURL: "c.jpg", CallToAction: "C"},
+		{CampaignID: "camp_004", ImageURL: "d.jpg", CallToAction: "D"},
+	}
+	rules := []models.TargetingRule{
+		{CampaignID: "camp_001", Dimension: "placement", Type: "include", Operator: "eq", Value: "interstitial"},
+		{CampaignID: "camp_004", Dimension: "language", Type: "include", Operator: "eq", Value: "es"},
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want map[string][]string
+	}{
+		{
+			name: "deduplicated across placements",
+			body: `{"app_id": "test_app", "country": "us", "os": "android",
+				"placements": [{"placement_id": "banner", "limit": 1}, {"placement_id": "feed", "limit": 5}]}`,
+			want: map[string][]string{"banner": {"camp_002"}, "feed": {"camp_003", "camp_004"}},
+		},
+		{
+			name: "placement targeting and dimensions",
+			body: `{"dimensions": {"app_id": "test_app", "country": "US", "os": "android", "language": ["en", "es"]},
+				"placements": [{"placement_id": "interstitial", "limit": 2}, {"placement_id": "feed"}]}`,
+			want: map[string][]string{"interstitial": {"camp_001", "camp_002"}, "feed": {"camp_003", "camp_004"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newStaticHandler(campaigns, rules)
+			req, _ := http.NewRequest("POST", "/delivery", strings.NewReader(tt.body))
+			w := serve(handler.BatchDeliveryHandler, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response models.BatchDeliveryResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+			got := make(map[string][]string)
+			for _, placement := range response.Placements {
+				got[placement.PlacementID] = []string{}
+				for _, campaign := range placement.Campaigns {
+					got[placement.PlacementID] = append(got[placement.PlacementID], campaign.CampaignID)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBatchDeliveryHandler_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		body          string
+		expectedError string
+	}{
+		{name: "malformed json", body: `{"app_id":`, expectedError: "invalid request body"},
+		{name: "unknown field", body: `{"app": "test_app"}`, expectedError: "invalid request body"},
+		{name: "no placements", body: `{"app_id": "test_app", "country": "US", "os": "android"}`, expectedError: "at least one placement is required"},
+		{
+			name:          "duplicate placement",
+			body:          `{"app_id": "test_app", "country": "US", "os": "android", "placements": [{"placement_id": "a"}, {"placement_id": "a"}]}`,
+			expectedError: "duplicate placement_id: a",
+		},
+		{
+			name:          "limit too large",
+			body:          `{"app_id": "test_app", "country": "US", "os": "android", "placements": [{"placement_id": "a", "limit": 101}]}`,
+			expectedError: "invalid limit for placement a: 101",
+		},
+		{name: "missing os", body: `{"app_id": "test_app", "country": "US", "placements": [{"placement_id": "a"}]}`, expectedError: "missing os parameter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewDeliveryHandlerWithStore(nil, cache.NewMemoryCache(), nil, nil)
+			req, _ := http.NewRequest("POST", "/delivery", strings.NewReader(tt.body))
+			w := serve(handler.BatchDeliveryHandler, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedError, response["error"])
+		})
+	}
+}
@@ -2,9 +2,14 @@ package handler
 
 import (
 	"campaign/internal/domain/models"
+	"campaign/internal/domain/segment"
+	"campaign/internal/domain/targeting"
 	"campaign/internal/infrastructure/cache"
+	"campaign/internal/infrastructure/geo"
 	"campaign/pkg/utils"
 	"encoding/json"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -82,7 +87,6 @@ func TestDeliveryHandler_CacheMiss(t *testing.T) {
 	req, _ := http.NewRequest("GET", "/delivery?app_id=test_app&country=US&os=android", nil)
 	c.Request = req
 
-
 	defer func() {
 		if r := recover(); r != nil {
 			// Test passes because we expected the panic due to nil DB
@@ -117,7 +121,7 @@ func TestDeliveryHandler_CacheHit(t *testing.T) {
 
 	cachedData, _ := json.Marshal(cachedResponse)
 	// Use the actual cache key that will be generated (alphabetical order)
-	cacheKey := "delivery:app_id:test_app:country:US:os:android:page1:limit10"
+	cacheKey := "tenant:default:delivery:app_id:test_app:country:US:os:android:page1:limit10"
 	mockCache.Set(cacheKey, cachedData, 5*time.Minute)
 
 	// Create request
@@ -152,12 +156,12 @@ func TestDeliveryHandler_PaginationParametersValid(t *testing.T) {
 		{
 			name:        "custom pagination",
 			queryParams: "app_id=test_app&country=US&os=android&page=2&limit=20",
-			cacheKey:    "delivery:app_id:test_app:country:US:os:android:page2:limit20",
+			cacheKey:    "tenant:default:delivery:app_id:test_app:country:US:os:android:page2:limit20",
 		},
 		{
 			name:        "default pagination",
 			queryParams: "app_id=test_app&country=US&os=android",
-			cacheKey:    "delivery:app_id:test_app:country:US:os:android:page1:limit10",
+			cacheKey:    "tenant:default:delivery:app_id:test_app:country:US:os:android:page1:limit10",
 		},
 	}
 
@@ -286,17 +290,17 @@ func TestDeliveryHandler_CacheKeyGeneration(t *testing.T) {
 		{
 			name:        "basic params",
 			queryParams: "app_id=app1&country=US&os=android",
-			cacheKey:    "delivery:app_id:app1:country:US:os:android:page1:limit10",
+			cacheKey:    "tenant:default:delivery:app_id:app1:country:US:os:android:page1:limit10",
 		},
 		{
 			name:        "different app",
 			queryParams: "app_id=app2&country=US&os=android",
-			cacheKey:    "delivery:app_id:app2:country:US:os:android:page1:limit10",
+			cacheKey:    "tenant:default:delivery:app_id:app2:country:US:os:android:page1:limit10",
 		},
 		{
 			name:        "different country",
 			queryParams: "app_id=app1&country=CA&os=android",
-			cacheKey:    "delivery:app_id:app1:country:CA:os:android:page1:limit10",
+			cacheKey:    "tenant:default:delivery:app_id:app1:country:CA:os:android:page1:limit10",
 		},
 	}
 
@@ -327,3 +331,264 @@ func TestDeliveryHandler_CacheKeyGeneration(t *testing.T) {
 		})
 	}
 }
+
+func TestDeliveryHandler_NormalizedCacheKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockCache := cache.NewMemoryCache()
+	handler := NewDeliveryHandler(nil, mockCache)
+
+	cachedResponse := []models.DeliveryResponse{
+		{CampaignID: "camp_001", ImageURL: "test.jpg", CallToAction: "Test"},
+	}
+	cachedData, _ := json.Marshal(cachedResponse)
+	mockCache.Set("tenant:default:delivery:app_id:test_app:country:US:os:ios:page1:limit10", cachedData, 5*time.Minute)
+
+	tests := []struct {
+		name        string
+		queryParams string
+	}{
+		{name: "lower case country", queryParams: "app_id=test_app&country=us&os=ios"},
+		{name: "alpha-3 country", queryParams: "app_id=test_app&country=USA&os=ios"},
+		{name: "os alias", queryParams: "app_id=test_app&country=US&os=iPadOS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req, _ := http.NewRequest("GET", "/delivery?"+tt.queryParams, nil)
+			c.Request = req
+
+			handler.DeliveryHandler(c)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "IN_MEMORY_HIT", w.Header().Get("X-Cache-Type"))
+		})
+	}
+}
+
+func TestDeliveryHandler_MultiValuedCacheKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockCache := cache.NewMemoryCache()
+	handler := NewDeliveryHandler(nil, mockCache)
+
+	cachedResponse := []models.DeliveryResponse{
+		{CampaignID: "camp_001", ImageURL: "test.jpg", CallToAction: "Test"},
+	}
+	cachedData, _ := json.Marshal(cachedResponse)
+	mockCache.Set("tenant:default:delivery:app_id:test_app:country:US:language:en,es:os:android:page1:limit10", cachedData, 5*time.Minute)
+
+	tests := []struct {
+		name        string
+		queryParams string
+	}{
+		{name: "values in order", queryParams: "app_id=test_app&country=US&os=android&language=en&language=es"},
+		{name: "values reversed", queryParams: "app_id=test_app&country=US&os=android&language=es&language=en"},
+		{name: "duplicate values", queryParams: "app_id=test_app&country=US&os=android&language=es&language=EN&language=en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req, _ := http.NewRequest("GET", "/delivery?"+tt.queryParams, nil)
+			c.Request = req
+
+			handler.DeliveryHandler(c)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "IN_MEMORY_HIT", w.Header().Get("X-Cache-Type"))
+		})
+	}
+}
+
+// staticStore returns a targeting store that always loads campaigns and rules
+func staticStore(campaigns []models.Campaign, rules []models.TargetingRule) *targeting.Store {
+	return targeting.NewStore(slog.Default(), func() ([]models.Campaign, []models.TargetingRule, error) {
+		return campaigns, rules, nil
+	})
+}
+
+// newStaticHandler returns a delivery handler with an empty cache serving campaigns and rules
+func newStaticHandler(campaigns []models.Campaign, rules []models.TargetingRule) *DeliveryHandler {
+	return NewDeliveryHandlerWithStore(nil, cache.NewMemoryCache(), staticStore(campaigns, rules), nil)
+}
+
+// serve runs handle on a test context for req and returns the recorded response
+func serve(handle gin.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	handle(c)
+	return w
+}
+
+// campaignIDs decodes a delivery response and returns its campaign IDs in order
+func campaignIDs(t *testing.T, w *httptest.ResponseRecorder) []string {
+	var response []models.DeliveryResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	var got []string
+	for _, r := range response {
+		got = append(got, r.CampaignID)
+	}
+	return got
+}
+
+func TestDeliveryHandler_TargetingSnapshot(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	campaigns := []models.Campaign{
+		{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"},
+		{CampaignID: "camp_002", ImageURL: "b.jpg", CallToAction: "B"},
+	}
+	rules := []models.TargetingRule{
+		{CampaignID: "camp_001", Dimension: "country", Type: "include", Operator: "eq", Value: "US"},
+		{CampaignID: "camp_002", Dimension: "app_version", Type: "include", Operator: "semver_gte", Value: "5.2.0"},
+	}
+
+	tests := []struct {
+		name        string
+		queryParams string
+		want        []string
+	}{
+		{name: "version below minimum", queryParams: "app_id=test_app&country=us&os=android&app_version=5.1.0", want: []string{"camp_001"}},
+		{name: "version above minimum", queryParams: "app_id=test_app&country=us&os=android&app_version=5.10.0", want: []string{"camp_001", "camp_002"}},
+		{name: "country not included", queryParams: "app_id=test_app&country=CA&os=android&app_version=6", want: []string{"camp_002"}},
+		{name: "second page", queryParams: "app_id=test_app&country=US&os=android&app_version=6&page=2&limit=1", want: []string{"camp_002"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newStaticHandler(campaigns, rules)
+			req, _ := http.NewRequest("GET", "/delivery?"+tt.queryParams, nil)
+			w := serve(handler.DeliveryHandler, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "MISS", w.Header().Get("X-Cache-Type"))
+			assert.Equal(t, tt.want, campaignIDs(t, w))
+		})
+	}
+}
+
+// staticLocation resolves every IP to the same location
+type staticLocation geo.Location
+
+func (l staticLocation) Lookup(ip net.IP) (*geo.Location, error) {
+	location := geo.Location(l)
+	return &location, nil
+}
+
+func TestDeliveryHandler_EnrichedParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockCache := cache.NewMemoryCache()
+	enricher := &GeoEnricher{reader: staticLocation{Country: "CA", Region: "ca-on", City: "Toronto"}}
+	handler := NewDeliveryHandlerWithStore(nil, mockCache, nil, nil, enricher)
+
+	cachedData, _ := json.Marshal([]models.DeliveryResponse{{CampaignID: "camp_001"}})
+	mockCache.Set("tenant:default:delivery:app_id:test_app:city:toronto:country:CA:os:android:region:CA-ON:page1:limit10", cachedData, 5*time.Minute)
+	mockCache.Set("tenant:default:delivery:app_id:test_app:country:US:os:android:page1:limit10", cachedData, 5*time.Minute)
+	mockCache.Set("tenant:default:delivery:app_id:test_app:country:US:location:40.71,-74.01:os:android:page1:limit10", cachedData, 5*time.Minute)
+
+	tests := []struct {
+		name        string
+		queryParams string
+		inferred    string
+	}{
+		{name: "location derived when missing", queryParams: "app_id=test_app&os=android",
+			inferred: "city=toronto (geoip), country=CA (geoip), region=CA-ON (geoip)"},
+		{name: "explicit country skips geo inference", queryParams: "app_id=test_app&country=US&os=android"},
+		{name: "lat and lon become a coarse location", queryParams: "app_id=test_app&country=US&os=android&lat=40.712776&lon=-74.005974"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req, _ := http.NewRequest("GET", "/delivery?"+tt.queryParams, nil)
+			req.RemoteAddr = "203.0.113.7:4321"
+			c.Request = req
+
+			handler.DeliveryHandler(c)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "IN_MEMORY_HIT", w.Header().Get("X-Cache-Type"))
+			assert.Equal(t, tt.inferred, w.Header().Get("X-Targeting-Inferred"))
+		})
+	}
+}
+
+func TestDeliveryHandler_UserAgentEnrichment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockCache := cache.NewMemoryCache()
+	// Only os_version is targeted, so browser stays out of the cache key
+	store := staticStore([]models.Campaign{{CampaignID: "camp_001"}},
+		[]models.TargetingRule{{CampaignID: "camp_001", Dimension: "os_version", Type: "include", Operator: "semver_gte", Value: "13"}})
+	handler := NewDeliveryHandlerWithStore(nil, mockCache, store, nil, NewUserAgentEnricher())
+
+	cachedData, _ := json.Marshal([]models.DeliveryResponse{{CampaignID: "camp_001"}})
+	mockCache.Set("tenant:default:delivery:app_id:test_app:country:US:device_type:mobile:os:android:os_version:14:page1:limit10", cachedData, 5*time.Minute)
+	mockCache.Set("tenant:default:delivery:app_id:test_app:country:US:os:ios:page1:limit10", cachedData, 5*time.Minute)
+
+	tests := []struct {
+		name        string
+		queryParams string
+		inferred    string
+	}{
+		{name: "device derived when missing", queryParams: "app_id=test_app&country=US",
+			inferred: "browser=chrome (user_agent), device_type=mobile (user_agent), os=android (user_agent), os_version=14 (user_agent)"},
+		{name: "explicit os matching the user agent", queryParams: "app_id=test_app&country=US&os=Android",
+			inferred: "browser=chrome (user_agent), device_type=mobile (user_agent), os_version=14 (user_agent)"},
+		{name: "explicit os contradicting the user agent", queryParams: "app_id=test_app&country=US&os=ios"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/delivery?"+tt.queryParams, nil)
+			req.Header.Set("User-Agent", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36")
+			w := serve(handler.DeliveryHandler, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "IN_MEMORY_HIT", w.Header().Get("X-Cache-Type"))
+			assert.Equal(t, tt.inferred, w.Header().Get("X-Targeting-Inferred"))
+		})
+	}
+}
+
+func TestDeliveryHandler_SegmentTargeting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := staticStore([]models.Campaign{
+		{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"},
+		{CampaignID: "camp_002", ImageURL: "b.jpg", CallToAction: "B"},
+	}, []models.TargetingRule{
+		{CampaignID: "camp_001", Dimension: "segment", Type: "include", Operator: "eq", Value: "high_value"},
+		{CampaignID: "camp_002", Dimension: "segment", Type: "exclude", Operator: "eq", Value: "churned"},
+	})
+	segments := segment.NewStore(slog.Default(), func(add func(segmentID, userID string)) error {
+		add("high_value", "user-1")
+		add("churned", "user-2")
+		return nil
+	})
+
+	tests := []struct {
+		name        string
+		queryParams string
+		want        []string
+	}{
+		{name: "member of included segment", queryParams: "app_id=test_app&country=US&os=android&user_id=user-1", want: []string{"camp_001", "camp_002"}},
+		{name: "member of excluded segment", queryParams: "app_id=test_app&country=US&os=android&user_id=user-2", want: nil},
+		{name: "unknown user", queryParams: "app_id=test_app&country=US&os=android&user_id=user-3", want: []string{"camp_002"}},
+		{name: "no user", queryParams: "app_id=test_app&country=US&os=android", want: []string{"camp_002"}},
+		{name: "segment cannot be asserted", queryParams: "app_id=test_app&country=US&os=android&segment=high_value", want: []string{"camp_002"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewDeliveryHandlerWithStore(nil, cache.NewMemoryCache(), store, segments)
+			req, _ := http.NewRequest("GET", "/delivery?"+tt.queryParams, nil)
+			w := serve(handler.DeliveryHandler, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.want, campaignIDs(t, w))
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/tenant"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deliveryPage is the version-independent result of matching one page, cached by the v2 endpoint
+// so that the per-request metadata can be added on every hit
+type deliveryPage struct {
+	Campaigns  []models.DeliveryResponse `json:"campaigns"`
+	Total      int                       `json:"total"`
+	HasMore    bool                      `json:"has_more"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+// DeliveryHandlerV2 serves the same targeting as DeliveryHandler wrapped in a response envelope with
+// pagination metadata, the request ID and the serving time. ?include_total=true adds the total match count.
+// Like v1, a cursor parameter (empty for the first page) selects keyset pagination instead of page.
+func (h *DeliveryHandler) DeliveryHandlerV2(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		utils.DeliveryAPILatency.Observe(time.Since(start).Seconds())
+	}()
+
+	targetingParams := h.extractTargetingParams(c)
+	if !h.resolveTargeting(c, targetingParams) {
+		return
+	}
+	h.recordSample(c.Request.Context(), targetingParams)
+
+	// The envelope has no protobuf message, so v2 is served as JSON or MessagePack
+	encoding := acceptEncoding(c, jsonEncoding, msgpackEncoding)
+	if encoding == nil {
+		return
+	}
+
+	token, useCursor := c.GetQuery("cursor")
+	if useCursor && c.Query("page") != "" {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, "page and cursor cannot be combined")
+		return
+	}
+	after, err := decodeCursor(token)
+	if err != nil {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, limit, err := h.parsePaginationParams(c)
+	if err != nil {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	includeTotal, err := strconv.ParseBool(c.DefaultQuery("include_total", "false"))
+	if err != nil {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, fmt.Sprintf("invalid include_total parameter: %s", c.Query("include_total")))
+		return
+	}
+
+	var result *deliveryPage
+	if useCursor {
+		page = 0
+		result, err = h.matchCursorPage(c, targetingParams, after, limit)
+	} else {
+		result, err = h.matchPage(c, targetingParams, page, limit)
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error matching campaigns", "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	envelope := models.DeliveryEnvelope{
+		Campaigns:  result.Campaigns,
+		Page:       page,
+		Limit:      limit,
+		HasMore:    result.HasMore,
+		NextCursor: result.NextCursor,
+		RequestID:  c.GetString("request_id"),
+		ServedAt:   start.UTC(),
+	}
+	if includeTotal {
+		envelope.Total = &result.Total
+	}
+
+	data, err := encoding.encode(envelope, nil)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error marshalling delivery response", "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, encoding.contentType, data)
+}
+
+// matchPage returns one page of the campaigns matching params, from the response cache when possible
+func (h *DeliveryHandler) matchPage(c *gin.Context, params map[string][]string, page, limit int) (*deliveryPage, error) {
+	ctx := c.Request.Context()
+	cacheKey := fmt.Sprintf("delivery_v2:%s:page%d:limit%d", h.cacheKeyParams(ctx, params), page, limit)
+	offset := (page - 1) * limit
+
+	return h.cachedPage(c, cacheKey, params, func(matched []models.Campaign) *deliveryPage {
+		return &deliveryPage{
+			Campaigns: h.buildResponse(paginate(matched, offset, limit)),
+			Total:     len(matched),
+			HasMore:   offset+limit < len(matched),
+		}
+	})
+}
+
+// matchCursorPage returns the keyset page of the campaigns matching params after the given campaign ID,
+// from the response cache when possible
+func (h *DeliveryHandler) matchCursorPage(c *gin.Context, params map[string][]string, after string, limit int) (*deliveryPage, error) {
+	ctx := c.Request.Context()
+	cacheKey := fmt.Sprintf("delivery_v2_cursor:%s:after%s:limit%d", h.cacheKeyParams(ctx, params), after, limit)
+
+	return h.cachedPage(c, cacheKey, params, func(matched []models.Campaign) *deliveryPage {
+		campaigns, nextCursor := paginateAfter(matched, after, limit)
+		return &deliveryPage{
+			Campaigns:  h.buildResponse(campaigns),
+			Total:      len(matched),
+			HasMore:    nextCursor != "",
+			NextCursor: nextCursor,
+		}
+	})
+}
+
+// cachedPage returns the page cached under cacheKey, or matches params and caches the page that cut takes
+// from the matched campaigns
+func (h *DeliveryHandler) cachedPage(c *gin.Context, cacheKey string, params map[string][]string, cut func([]models.Campaign) *deliveryPage) (*deliveryPage, error) {
+	ctx := c.Request.Context()
+	cacheKey = tenantCacheKey(ctx, cacheKey)
+
+	if cachedData, found := h.memeCache.GetContext(ctx, cacheKey); found {
+		var result deliveryPage
+		if err := json.Unmarshal(cachedData, &result); err == nil {
+			logging.FromContext(ctx).Debug("cache hit", "key", cacheKey)
+			c.Header("X-Cache-Type", "IN_MEMORY_HIT")
+			utils.RecordCacheHit()
+			utils.RecordDelivery(tenant.FromContext(ctx), params, campaignIDsOf(result.Campaigns))
+			return &result, nil
+		}
+	}
+
+	logging.FromContext(ctx).Debug("cache miss", "key", cacheKey)
+	utils.RecordCacheMiss()
+	c.Header("X-Cache-Type", "MISS")
+
+	snapshot, err := h.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := cut(snapshot.Match(params))
+	if data, err := json.Marshal(result); err == nil {
+		h.memeCache.SetContext(ctx, cacheKey, data, responseTTL(snapshot))
+	}
+	utils.RecordDelivery(tenant.FromContext(ctx), params, campaignIDsOf(result.Campaigns))
+
+	return result, nil
+}
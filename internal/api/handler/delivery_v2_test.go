@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/infrastructure/cache"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryHandlerV2_Envelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	campaigns := []models.Campaign{{CampaignID: "camp_001"}, {CampaignID: "camp_002"}, {CampaignID: "camp_003"}}
+
+	tests := []struct {
+		name        string
+		queryParams string
+		want        []string
+		hasMore     bool
+		total       *int
+	}{
+		{name: "first page", queryParams: "limit=2", want: []string{"camp_001", "camp_002"}, hasMore: true},
+		{name: "last page with total", queryParams: "limit=2&page=2&include_total=true", want: []string{"camp_003"}, total: intPtr(3)},
+		{name: "past the end", queryParams: "limit=2&page=3&include_total=1", want: nil, total: intPtr(3)},
+	}
+
+	handler := newStaticHandler(campaigns, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Repeat to serve the second response from the cache
+			for _, cacheType := range []string{"MISS", "IN_MEMORY_HIT"} {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request, _ = http.NewRequest("GET", "/api/v2/delivery?app_id=test_app&country=US&os=android&"+tt.queryParams, nil)
+				c.Set("request_id", "req-123")
+
+				handler.DeliveryHandlerV2(c)
+
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, cacheType, w.Header().Get("X-Cache-Type"))
+
+				var envelope models.DeliveryEnvelope
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &envelope))
+
+				var got []string
+				for _, campaign := range envelope.Campaigns {
+					got = append(got, campaign.CampaignID)
+				}
+				assert.Equal(t, tt.want, got)
+				assert.Equal(t, tt.hasMore, envelope.HasMore)
+				assert.Equal(t, tt.total, envelope.Total)
+				assert.Equal(t, "req-123", envelope.RequestID)
+				assert.False(t, envelope.ServedAt.IsZero())
+			}
+		})
+	}
+}
+
+func TestDeliveryHandlerV2_Cursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	campaigns := []models.Campaign{{CampaignID: "camp_001"}, {CampaignID: "camp_002"}, {CampaignID: "camp_003"}}
+	handler := newStaticHandler(campaigns, nil)
+
+	deliver := func(query string) (int, models.DeliveryEnvelope) {
+		req, _ := http.NewRequest("GET", "/api/v2/delivery?app_id=test_app&country=US&os=android&"+query, nil)
+		w := serve(handler.DeliveryHandlerV2, req)
+
+		var envelope models.DeliveryEnvelope
+		json.Unmarshal(w.Body.Bytes(), &envelope)
+		return w.Code, envelope
+	}
+
+	code, first := deliver("limit=2&cursor=")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, first.Campaigns, 2)
+	assert.Zero(t, first.Page)
+	assert.True(t, first.HasMore)
+	assert.NotEmpty(t, first.NextCursor)
+
+	code, second := deliver("limit=2&include_total=true&cursor=" + first.NextCursor)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []models.DeliveryResponse{{CampaignID: "camp_003"}}, second.Campaigns)
+	assert.False(t, second.HasMore)
+	assert.Empty(t, second.NextCursor)
+	assert.Equal(t, intPtr(3), second.Total)
+
+	code, _ = deliver("page=2&cursor=")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = deliver("cursor=not-a-cursor")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestDeliveryHandlerV2_InvalidIncludeTotal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewDeliveryHandlerWithStore(nil, cache.NewMemoryCache(), nil, nil)
+
+	req, _ := http.NewRequest("GET", "/api/v2/delivery?app_id=test_app&country=US&os=android&include_total=maybe", nil)
+	w := serve(handler.DeliveryHandlerV2, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"campaign/internal/api/rpc/deliverypb"
+	"campaign/internal/domain/models"
+	"campaign/pkg/utils"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// responseEncoding is a wire format for delivery responses. Each encoding is cached under its own key
+// suffix, so a cache hit is written out as stored without re-encoding.
+type responseEncoding struct {
+	contentType string
+	cacheSuffix string
+}
+
+var (
+	jsonEncoding     = &responseEncoding{contentType: "application/json"}
+	protobufEncoding = &responseEncoding{contentType: "application/x-protobuf", cacheSuffix: ":pb"}
+	msgpackEncoding  = &responseEncoding{contentType: "application/msgpack", cacheSuffix: ":msgpack"}
+
+	// allEncodings are served by responses that have a protobuf message
+	allEncodings = []*responseEncoding{jsonEncoding, protobufEncoding, msgpackEncoding}
+)
+
+// acceptedEncodings maps the media types clients send to the encoding that serves them
+var acceptedEncodings = map[string]*responseEncoding{
+	"application/json":       jsonEncoding,
+	"application/*":          jsonEncoding,
+	"*/*":                    jsonEncoding,
+	"application/x-protobuf": protobufEncoding,
+	"application/protobuf":   protobufEncoding,
+	"application/msgpack":    msgpackEncoding,
+	"application/x-msgpack":  msgpackEncoding,
+}
+
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+// negotiateEncoding picks the supported encoding with the highest q-value in the Accept header. JSON is the
+// fallback when the header is missing or lists no media type we know, so existing clients are unaffected.
+// It returns nil when the header only accepts encodings that are not supported.
+func negotiateEncoding(accept string, supported ...*responseEncoding) *responseEncoding {
+	var best *responseEncoding
+	bestQ, listed := 0.0, false
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		encoding, ok := acceptedEncodings[mediaType]
+		if !ok {
+			continue
+		}
+		listed = true
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		// The first of equally preferred encodings wins
+		if q > bestQ && slices.Contains(supported, encoding) {
+			best, bestQ = encoding, q
+		}
+	}
+
+	if !listed {
+		return jsonEncoding
+	}
+	return best
+}
+
+// acceptEncoding negotiates the encoding of a response among supported, marking the response as varying by
+// Accept. It answers 406 and returns nil when the client accepts none of them.
+func acceptEncoding(c *gin.Context, supported ...*responseEncoding) *responseEncoding {
+	c.Header("Vary", "Accept")
+	encoding := negotiateEncoding(c.GetHeader("Accept"), supported...)
+	if encoding == nil {
+		utils.ErrorJSONGin(c, http.StatusNotAcceptable, utils.ErrNotAcceptable)
+	}
+	return encoding
+}
+
+// encode serializes a delivery response; message builds its protobuf form when that encoding is requested
+func (e *responseEncoding) encode(response interface{}, message func() proto.Message) ([]byte, error) {
+	switch e {
+	case protobufEncoding:
+		return proto.Marshal(message())
+	case msgpackEncoding:
+		var data []byte
+		err := codec.NewEncoderBytes(&data, msgpackHandle).Encode(response)
+		return data, err
+	default:
+		return json.Marshal(response)
+	}
+}
+
+// deliveryProto is the protobuf form of a delivery response, the same message gRPC Deliver returns
+func deliveryProto(campaigns []models.DeliveryResponse, nextCursor string) *deliverypb.DeliverResponse {
+	message := &deliverypb.DeliverResponse{
+		Campaigns:  make([]*deliverypb.Campaign, 0, len(campaigns)),
+		NextCursor: nextCursor,
+	}
+	for _, campaign := range campaigns {
+		message.Campaigns = append(message.Campaigns, &deliverypb.Campaign{
+			CampaignId:   campaign.CampaignID,
+			ImageUrl:     campaign.ImageURL,
+			CallToAction: campaign.CallToAction,
+		})
+	}
+	return message
+}
+
+// batchProto is the protobuf form of a batch delivery response, the same message gRPC BatchDeliver returns
+func batchProto(response models.BatchDeliveryResponse) *deliverypb.BatchDeliverResponse {
+	message := &deliverypb.BatchDeliverResponse{
+		Placements: make([]*deliverypb.PlacementCampaigns, 0, len(response.Placements)),
+	}
+	for _, placement := range response.Placements {
+		message.Placements = append(message.Placements, &deliverypb.PlacementCampaigns{
+			PlacementId: placement.PlacementID,
+			Campaigns:   deliveryProto(placement.Campaigns, "").Campaigns,
+		})
+	}
+	return message
+}
+
+// campaignIDsOf returns the IDs of the campaigns in a response
+func campaignIDsOf(campaigns []models.DeliveryResponse) []string {
+	ids := make([]string, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		ids = append(ids, campaign.CampaignID)
+	}
+	return ids
+}
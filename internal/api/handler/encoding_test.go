@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"campaign/internal/api/rpc/deliverypb"
+	"campaign/internal/domain/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	jsonOrMsgpack := []*responseEncoding{jsonEncoding, msgpackEncoding}
+	tests := []struct {
+		accept    string
+		supported []*responseEncoding
+		want      *responseEncoding
+	}{
+		{accept: "", supported: allEncodings, want: jsonEncoding},
+		{accept: "*/*", supported: allEncodings, want: jsonEncoding},
+		{accept: "text/html", supported: allEncodings, want: jsonEncoding},
+		{accept: "application/x-protobuf", supported: allEncodings, want: protobufEncoding},
+		{accept: "application/msgpack", supported: allEncodings, want: msgpackEncoding},
+		{accept: "application/json;q=0.5, application/x-msgpack", supported: allEncodings, want: msgpackEncoding},
+		{accept: "application/x-protobuf, application/json", supported: allEncodings, want: protobufEncoding},
+		{accept: "application/x-protobuf;q=0, application/json", supported: allEncodings, want: jsonEncoding},
+		{accept: "application/x-protobuf, application/json;q=0.1", supported: jsonOrMsgpack, want: jsonEncoding},
+		{accept: "application/x-protobuf", supported: jsonOrMsgpack, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiateEncoding(tt.accept, tt.supported...))
+		})
+	}
+}
+
+func TestDeliveryHandler_ResponseEncodings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := newStaticHandler([]models.Campaign{{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"}}, nil)
+	want := []models.DeliveryResponse{{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"}}
+
+	decoders := map[string]func(t *testing.T, body []byte) []models.DeliveryResponse{
+		"application/json": func(t *testing.T, body []byte) []models.DeliveryResponse {
+			var response []models.DeliveryResponse
+			assert.NoError(t, json.Unmarshal(body, &response))
+			return response
+		},
+		"application/x-protobuf": func(t *testing.T, body []byte) []models.DeliveryResponse {
+			var message deliverypb.DeliverResponse
+			assert.NoError(t, proto.Unmarshal(body, &message))
+			var response []models.DeliveryResponse
+			for _, campaign := range message.GetCampaigns() {
+				response = append(response, models.DeliveryResponse{
+					CampaignID:   campaign.GetCampaignId(),
+					ImageURL:     campaign.GetImageUrl(),
+					CallToAction: campaign.GetCallToAction(),
+				})
+			}
+			return response
+		},
+		"application/msgpack": func(t *testing.T, body []byte) []models.DeliveryResponse {
+			var response []models.DeliveryResponse
+			assert.NoError(t, codec.NewDecoderBytes(body, msgpackHandle).Decode(&response))
+			return response
+		},
+	}
+
+	for contentType, decode := range decoders {
+		t.Run(contentType, func(t *testing.T) {
+			var etags []string
+			for _, cacheType := range []string{"MISS", "IN_MEMORY_HIT"} {
+				req, _ := http.NewRequest("GET", "/delivery?app_id=test_app&country=US&os=android", nil)
+				req.Header.Set("Accept", contentType)
+				w := serve(handler.DeliveryHandler, req)
+
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, cacheType, w.Header().Get("X-Cache-Type"))
+				assert.Equal(t, contentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, "Accept", w.Header().Get("Vary"))
+				assert.Equal(t, want, decode(t, w.Body.Bytes()))
+				etags = append(etags, w.Header().Get("ETag"))
+			}
+			assert.Equal(t, etags[0], etags[1])
+		})
+	}
+}
+
+func TestBatchDeliveryHandler_ResponseEncodings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := newStaticHandler([]models.Campaign{{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"}}, nil)
+	body := `{"app_id": "test_app", "country": "US", "os": "android", "placements": [{"placement_id": "banner"}]}`
+
+	for _, cacheType := range []string{"MISS", "IN_MEMORY_HIT"} {
+		req, _ := http.NewRequest("POST", "/delivery/batch", strings.NewReader(body))
+		req.Header.Set("Accept", "application/x-protobuf")
+		w := serve(handler.BatchDeliveryHandler, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, cacheType, w.Header().Get("X-Cache-Type"))
+		assert.Equal(t, "application/x-protobuf", w.Header().Get("Content-Type"))
+
+		var message deliverypb.BatchDeliverResponse
+		assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), &message))
+		if assert.Len(t, message.GetPlacements(), 1) {
+			assert.Equal(t, "banner", message.GetPlacements()[0].GetPlacementId())
+			assert.Equal(t, "camp_001", message.GetPlacements()[0].GetCampaigns()[0].GetCampaignId())
+		}
+	}
+}
+
+func TestDeliveryHandlerV2_ResponseEncodings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := newStaticHandler([]models.Campaign{{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"}}, nil)
+
+	deliver := func(accept string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/v2/delivery?app_id=test_app&country=US&os=android", nil)
+		req.Header.Set("Accept", accept)
+		return serve(handler.DeliveryHandlerV2, req)
+	}
+
+	w := deliver("application/msgpack")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+	var envelope models.DeliveryEnvelope
+	assert.NoError(t, codec.NewDecoderBytes(w.Body.Bytes(), msgpackHandle).Decode(&envelope))
+	assert.Equal(t, []models.DeliveryResponse{{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"}}, envelope.Campaigns)
+
+	// The envelope has no protobuf form
+	w = deliver("application/x-protobuf")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"campaign/internal/domain/targeting"
+	"campaign/internal/infrastructure/geo"
+	"campaign/pkg/logging"
+	"campaign/pkg/useragent"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Enricher derives targeting values from the request itself (client IP, headers) rather than the query string
+type Enricher interface {
+	// Name identifies the enricher in logs and debug headers
+	Name() string
+	// Enrich returns derived dimension values; an empty map means nothing could be derived. explicit holds
+	// the dimensions the caller supplied, so that an enricher can hold back values that would contradict them.
+	Enrich(c *gin.Context, explicit map[string][]string) map[string]string
+}
+
+// enrichTargetingParams fills dimensions the caller did not supply with values derived by the enrichers.
+// Explicit query parameters always take precedence, and earlier enrichers win over later ones.
+// The inferred values are reported in the X-Targeting-Inferred debug header, e.g. "os=android (user_agent)".
+func (h *DeliveryHandler) enrichTargetingParams(c *gin.Context, params map[string][]string) {
+	var inferred []string
+
+	explicit := maps.Clone(params)
+	for _, enricher := range h.enrichers {
+		for dimension, raw := range enricher.Enrich(c, explicit) {
+			if _, exists := params[dimension]; exists {
+				continue
+			}
+			if value := targeting.Normalize(dimension, raw); value != "" {
+				params[dimension] = []string{value}
+				inferred = append(inferred, fmt.Sprintf("%s=%s (%s)", dimension, value, enricher.Name()))
+			}
+		}
+	}
+
+	if len(inferred) > 0 {
+		sort.Strings(inferred)
+		c.Header("X-Targeting-Inferred", strings.Join(inferred, ", "))
+	}
+}
+
+// locationDimensions describe where the client is. A caller sending any of them knows its location better
+// than its IP does, and mixing the two could yield a region outside the given country.
+var locationDimensions = []string{"country", "region", "city", "location"}
+
+// geoLookup resolves IP addresses to locations; *geo.Reader in production
+type geoLookup interface {
+	Lookup(ip net.IP) (*geo.Location, error)
+}
+
+// GeoEnricher derives country, region and city from the client IP using a MaxMind-format database, unless
+// the caller sent a location dimension itself.
+// The client IP honours X-Forwarded-For only from the router's trusted proxies.
+type GeoEnricher struct {
+	reader geoLookup
+}
+
+func NewGeoEnricher(reader *geo.Reader) *GeoEnricher {
+	return &GeoEnricher{reader: reader}
+}
+
+func (e *GeoEnricher) Name() string {
+	return "geoip"
+}
+
+func (e *GeoEnricher) Enrich(c *gin.Context, explicit map[string][]string) map[string]string {
+	for _, dimension := range locationDimensions {
+		if _, ok := explicit[dimension]; ok {
+			return nil
+		}
+	}
+
+	ip := net.ParseIP(c.ClientIP())
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() {
+		return nil
+	}
+
+	location, err := e.reader.Lookup(ip)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Warn("geoip lookup failed", "ip", ip.String(), "error", err)
+		return nil
+	}
+	if location == nil {
+		return nil
+	}
+
+	derived := map[string]string{"country": location.Country}
+	if location.Region != "" {
+		derived["region"] = location.Region
+	}
+	if location.City != "" {
+		derived["city"] = location.City
+	}
+
+	return derived
+}
+
+// deviceDimensions are the explicit dimensions a User-Agent must agree with before anything is inferred from it
+var deviceDimensions = []string{"os", "device_type"}
+
+// UserAgentEnricher derives os, os_version, device_type and browser from the User-Agent and Client Hints
+// headers. When the caller's own os or device_type contradicts the headers, the request describes another
+// device (a server-side integration, say) and nothing is derived.
+type UserAgentEnricher struct{}
+
+func NewUserAgentEnricher() *UserAgentEnricher {
+	return &UserAgentEnricher{}
+}
+
+func (e *UserAgentEnricher) Name() string {
+	return "user_agent"
+}
+
+func (e *UserAgentEnricher) Enrich(c *gin.Context, explicit map[string][]string) map[string]string {
+	info := useragent.Parse(c.Request.Header)
+
+	derived := make(map[string]string)
+	for dimension, value := range map[string]string{
+		"os":          info.OS,
+		"os_version":  info.OSVersion,
+		"device_type": info.DeviceType,
+		"browser":     info.Browser,
+	} {
+		if value != "" {
+			derived[dimension] = value
+		}
+	}
+
+	for _, dimension := range deviceDimensions {
+		if values, ok := explicit[dimension]; ok && !slices.Contains(values, targeting.Normalize(dimension, derived[dimension])) {
+			return nil
+		}
+	}
+
+	return derived
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveCached writes cached response bytes with a strong ETag and a Cache-Control max-age equal to the time the
+// bytes stay in the response cache. A client that already holds them gets 304 Not Modified with no body.
+// Responses are private because they can depend on the client IP, User-Agent and user segments.
+func serveCached(c *gin.Context, data []byte, ttl time.Duration, contentType string) {
+	etag := computeETag(data)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(ttl.Seconds())))
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// computeETag returns a strong entity tag for the response bytes
+func computeETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches implements the weak comparison If-None-Match requires: "*", or any listed tag equal to etag
+// once a W/ prefix is ignored
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/infrastructure/cache"
+	"campaign/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETagMatches(t *testing.T) {
+	etag := `"abc"`
+
+	assert.False(t, etagMatches("", etag))
+	assert.True(t, etagMatches(`"abc"`, etag))
+	assert.True(t, etagMatches(`W/"abc"`, etag))
+	assert.True(t, etagMatches(`"xyz", "abc"`, etag))
+	assert.True(t, etagMatches("*", etag))
+	assert.False(t, etagMatches(`"xyz"`, etag))
+}
+
+func TestDeliveryHandler_ConditionalRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := newStaticHandler([]models.Campaign{{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"}}, nil)
+
+	deliver := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/delivery?app_id=test_app&country=US&os=android", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		return serve(handler.DeliveryHandler, req)
+	}
+
+	first := deliver("")
+	assert.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	assert.Equal(t, "private, max-age=300", first.Header().Get("Cache-Control"))
+
+	// The cached bytes are identical, so the tag is too
+	second := deliver("")
+	assert.Equal(t, "IN_MEMORY_HIT", second.Header().Get("X-Cache-Type"))
+	assert.Equal(t, etag, second.Header().Get("ETag"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+
+	notModified := deliver(etag)
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.String())
+	assert.Equal(t, etag, notModified.Header().Get("ETag"))
+
+	maxAge := regexp.MustCompile(`max-age=(\d+)`).FindStringSubmatch(notModified.Header().Get("Cache-Control"))
+	assert.Len(t, maxAge, 2)
+	seconds, _ := strconv.Atoi(maxAge[1])
+	assert.LessOrEqual(t, seconds, 300)
+
+	assert.Equal(t, http.StatusOK, deliver(`"stale"`).Code)
+}
+
+func TestDeliveryHandler_CacheExpiresWithFlight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	end := time.Now().Add(30 * time.Second)
+	store := staticStore([]models.Campaign{{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A", EndTime: &end}}, nil)
+	memCache := cache.NewMemoryCache()
+	handler := NewDeliveryHandlerWithStore(nil, memCache, store, nil)
+
+	req, _ := http.NewRequest("GET", "/delivery?app_id=test_app&country=US&os=android", nil)
+	w := serve(handler.DeliveryHandler, req)
+
+	// The response must not be served from the cache after camp_001's flight ends
+	maxAge := regexp.MustCompile(`max-age=(\d+)`).FindStringSubmatch(w.Header().Get("Cache-Control"))
+	assert.Len(t, maxAge, 2)
+	seconds, _ := strconv.Atoi(maxAge[1])
+	assert.LessOrEqual(t, seconds, 30)
+
+	_, ttl, found := memCache.GetWithTTL(tenantCacheKey(req.Context(), handler.generateCacheKey(req.Context(), map[string][]string{
+		"app_id": {"test_app"}, "country": {"US"}, "os": {"android"},
+	}, 1, utils.DefaultApiPageLimit)))
+	assert.True(t, found)
+	assert.LessOrEqual(t, ttl, 30*time.Second)
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
+	"campaign/internal/infrastructure/db"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type explainResponse struct {
+	Request          map[string][]string     `json:"request"`
+	SnapshotLoadedAt time.Time               `json:"snapshot_loaded_at"`
+	Campaigns        []targeting.Explanation `json:"campaigns"`
+}
+
+// ExplainDelivery reports why each campaign would or would not be delivered for the request, with a verdict
+// per dimension. ?campaign_id= limits the report to one campaign. Targeting parameters are resolved exactly
+// as for delivery, but the response cache is bypassed so the live snapshot is always explained.
+func (h *DeliveryHandler) ExplainDelivery(c *gin.Context) {
+	targetingParams := h.extractTargetingParams(c)
+	if !h.resolveTargeting(c, targetingParams) {
+		return
+	}
+
+	snapshot, err := h.snapshot(c.Request.Context())
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error loading targeting snapshot", "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	response := explainResponse{
+		Request:          targetingParams,
+		SnapshotLoadedAt: snapshot.LoadedAt,
+	}
+
+	now := time.Now()
+	if campaignID := c.Query("campaign_id"); campaignID != "" {
+		explanation, found := snapshot.ExplainCampaign(campaignID, targetingParams, now)
+		if !found {
+			// Not in the snapshot: either inactive, unknown, or created since the last refresh
+			campaign, err := db.GetCampaign(c.Request.Context(), h.db, tenant.FromContext(c.Request.Context()), campaignID)
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.ErrorJSONGin(c, http.StatusNotFound, utils.ErrCampaignNotFound)
+				return
+			}
+			if err != nil {
+				logging.FromContext(c.Request.Context()).Error("error getting campaign", "campaign_id", campaignID, "error", err)
+				utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+				return
+			}
+
+			explanation = targeting.Explanation{CampaignID: campaignID, Verdict: targeting.VerdictInactive}
+			if campaign.CampaignStatus == "ACTIVE" {
+				explanation.Verdict = targeting.VerdictNotLoaded
+				explanation.Detail = "campaign is active but not in the targeting snapshot yet"
+			}
+		}
+		response.Campaigns = []targeting.Explanation{explanation}
+	} else {
+		response.Campaigns = snapshot.Explain(targetingParams, now)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/infrastructure/cache"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryHandler_ExplainDelivery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := staticStore([]models.Campaign{{CampaignID: "camp_001"}, {CampaignID: "camp_002"}}, []models.TargetingRule{
+		{CampaignID: "camp_002", Dimension: "country", Type: "exclude", Operator: "eq", Value: "US"},
+	})
+	memCache := cache.NewMemoryCache()
+	handler := NewDeliveryHandlerWithStore(nil, memCache, store, nil)
+
+	tests := []struct {
+		name        string
+		queryParams string
+		want        map[string]targeting.Verdict
+	}{
+		{
+			name:        "all campaigns",
+			queryParams: "app_id=test_app&country=usa&os=android",
+			want:        map[string]targeting.Verdict{"camp_001": targeting.VerdictDelivered, "camp_002": targeting.VerdictExcluded},
+		},
+		{
+			name:        "single campaign",
+			queryParams: "app_id=test_app&country=usa&os=android&campaign_id=camp_002",
+			want:        map[string]targeting.Verdict{"camp_002": targeting.VerdictExcluded},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/delivery/explain?"+tt.queryParams, nil)
+			w := serve(handler.ExplainDelivery, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+			var response explainResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, []string{"US"}, response.Request["country"])
+			assert.NotContains(t, response.Request, "campaign_id")
+
+			got := make(map[string]targeting.Verdict)
+			for _, explanation := range response.Campaigns {
+				got[explanation.CampaignID] = explanation.Verdict
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// Explanations never go through the response cache
+	assert.Equal(t, 0, memCache.Size())
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"campaign/internal/domain/auth"
+	"campaign/internal/domain/segment"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
+	"campaign/internal/infrastructure/cache"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	healthStatusUp   = "up"
+	healthStatusDown = "down"
+
+	cacheProbeKey = "health:probe"
+)
+
+// HealthCheck is one dependency that must be available for the service to be ready
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// DependencyStatus is the outcome of one readiness check
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type readinessResponse struct {
+	Status       string                      `json:"status"`
+	Time         time.Time                   `json:"time"`
+	Dependencies map[string]DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// HealthHandler serves liveness and readiness. Liveness only says the process is serving HTTP; readiness
+// runs every dependency check concurrently within a timeout and turns false for good once shutdown starts.
+type HealthHandler struct {
+	checks       []HealthCheck
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewHealthHandler(timeout time.Duration, checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+// SetShuttingDown makes readiness fail, so that load balancers stop routing here before the server drains
+func (h *HealthHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Livez reports that the process is up. It does not touch dependencies, so a database outage never
+// gets the service restarted.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "healthy",
+		"time":   time.Now().UTC(),
+	})
+}
+
+// Readyz reports whether every dependency is available, with the status and latency of each.
+// It answers 503 when any check fails or the server is shutting down.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	if h.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, readinessResponse{Status: "shutting_down", Time: time.Now().UTC()})
+		return
+	}
+
+	dependencies, ready := h.runChecks(c.Request.Context())
+	response := readinessResponse{Status: "ready", Time: time.Now().UTC(), Dependencies: dependencies}
+	if !ready {
+		response.Status = "not_ready"
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *HealthHandler) runChecks(ctx context.Context) (map[string]DependencyStatus, bool) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	statuses := make([]DependencyStatus, len(h.checks))
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			statuses[i] = runCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	dependencies := make(map[string]DependencyStatus, len(h.checks))
+	ready := true
+	for i, check := range h.checks {
+		dependencies[check.Name] = statuses[i]
+		ready = ready && statuses[i].Status == healthStatusUp
+	}
+	return dependencies, ready
+}
+
+// runCheck runs one check, giving up when ctx expires even if the check ignores it
+func runCheck(ctx context.Context, check HealthCheck) DependencyStatus {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errors.New("timed out")
+	}
+
+	status := DependencyStatus{
+		Status:    healthStatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		status.Status = healthStatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// DatabaseCheck pings the database
+func DatabaseCheck(db *sql.DB) HealthCheck {
+	return HealthCheck{Name: "database", Check: db.PingContext}
+}
+
+// CacheCheck writes a probe entry to the response cache and reads it back. The probe key is overwritten
+// rather than deleted, so that checks never evict cached responses once it exists.
+func CacheCheck(memCache *cache.MemoryCache) HealthCheck {
+	return HealthCheck{Name: "cache", Check: func(ctx context.Context) error {
+		memCache.Set(cacheProbeKey, []byte(strconv.FormatInt(time.Now().UnixNano(), 10)), time.Minute)
+		if _, found := memCache.Get(cacheProbeKey); !found {
+			return errors.New("probe entry not readable")
+		}
+		return nil
+	}}
+}
+
+// TargetingCheck requires the targeting snapshot of every tenant served so far, or warmed up, to be loaded
+func TargetingCheck(stores *tenant.Stores[*targeting.Store]) HealthCheck {
+	return HealthCheck{Name: "targeting", Check: func(ctx context.Context) error {
+		var err error
+		stores.Each(func(tenantID string, store *targeting.Store) {
+			if err == nil && !store.Ready() {
+				err = fmt.Errorf("targeting snapshot of tenant %s not loaded yet", tenantID)
+			}
+		})
+		return err
+	}}
+}
+
+// SegmentsCheck requires the audience segment membership of every tenant served so far, or warmed up, to be loaded
+func SegmentsCheck(stores *tenant.Stores[*segment.Store]) HealthCheck {
+	return HealthCheck{Name: "segments", Check: func(ctx context.Context) error {
+		var err error
+		stores.Each(func(tenantID string, store *segment.Store) {
+			if err == nil && !store.Ready() {
+				err = fmt.Errorf("segment membership of tenant %s not loaded yet", tenantID)
+			}
+		})
+		return err
+	}}
+}
+
+// TenantsCheck requires the tenant directory to be loaded, since requests cannot be resolved by host before
+func TenantsCheck(store *tenant.Store) HealthCheck {
+	return HealthCheck{Name: "tenants", Check: func(ctx context.Context) error {
+		if !store.Ready() {
+			return errors.New("tenants not loaded yet")
+		}
+		return nil
+	}}
+}
+
+// APIKeysCheck requires the initial API keys to be loaded, since no authenticated request can be served before
+func APIKeysCheck(store *auth.Store) HealthCheck {
+	return HealthCheck{Name: "api_keys", Check: func(ctx context.Context) error {
+		if !store.Ready() {
+			return errors.New("api keys not loaded yet")
+		}
+		return nil
+	}}
+}
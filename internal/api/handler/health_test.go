@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
+	"campaign/internal/infrastructure/cache"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandler_Readyz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	loadErr := errors.New("database is down")
+	store := targeting.NewStore(slog.Default(), func() ([]models.Campaign, []models.TargetingRule, error) {
+		if loadErr != nil {
+			return nil, nil, loadErr
+		}
+		return nil, nil, nil
+	})
+
+	slow := HealthCheck{Name: "slow", Check: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}}
+	healthy := NewHealthHandler(100*time.Millisecond, CacheCheck(cache.NewMemoryCache()), TargetingCheck(tenant.Shared(store)))
+	timingOut := NewHealthHandler(20*time.Millisecond, CacheCheck(cache.NewMemoryCache()), slow)
+
+	readyz := func(h *HealthHandler) (int, readinessResponse) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "/readyz", nil)
+		h.Readyz(c)
+
+		var response readinessResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	// Not ready until the targeting snapshot has been loaded
+	code, response := readyz(healthy)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", response.Status)
+	assert.Equal(t, healthStatusUp, response.Dependencies["cache"].Status)
+	assert.Equal(t, healthStatusDown, response.Dependencies["targeting"].Status)
+	assert.Equal(t, "targeting snapshot of tenant default not loaded yet", response.Dependencies["targeting"].Error)
+
+	loadErr = nil
+	require.NoError(t, store.Refresh())
+	code, response = readyz(healthy)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", response.Status)
+	assert.Len(t, response.Dependencies, 2)
+
+	// A check that outlives the timeout fails without holding up the response
+	start := time.Now()
+	code, response = readyz(timingOut)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "timed out", response.Dependencies["slow"].Error)
+	assert.Equal(t, healthStatusUp, response.Dependencies["cache"].Status)
+
+	// Shutting down fails readiness regardless of dependencies
+	healthy.SetShuttingDown()
+	code, response = readyz(healthy)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "shutting_down", response.Status)
+}
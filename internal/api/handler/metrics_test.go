@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/tenant"
+	"campaign/internal/infrastructure/cache"
+	"campaign/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryHandler_ServeMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := newStaticHandler([]models.Campaign{{CampaignID: "metrics_camp"}}, []models.TargetingRule{
+		{CampaignID: "metrics_camp", Dimension: "country", Type: "include", Operator: "eq", Value: "US"},
+	})
+
+	router := gin.New()
+	router.GET("/delivery", handler.DeliveryHandler)
+	router.GET("/v2/delivery", handler.DeliveryHandlerV2)
+	router.POST("/delivery/batch", handler.BatchDeliveryHandler)
+
+	serves := utils.CampaignServesTotal.WithLabelValues(tenant.DefaultID, "metrics_camp")
+	requests := utils.DeliveryRequestsTotal.WithLabelValues(tenant.DefaultID, "metrics_app")
+	zeroFill := utils.DeliveryZeroFillTotal.WithLabelValues(tenant.DefaultID, "metrics_app")
+
+	// Every request is sent twice, so that the second one is a cache hit
+	targets := []struct {
+		method, path, body, accept string
+	}{
+		{method: "GET", path: "/delivery?app_id=metrics_app&country=US&os=ios"},
+		{method: "GET", path: "/delivery?app_id=metrics_app&country=US&os=ios", accept: "application/x-protobuf"},
+		{method: "GET", path: "/delivery?app_id=metrics_app&country=US&os=ios&cursor=", accept: "application/msgpack"},
+		{method: "GET", path: "/v2/delivery?app_id=metrics_app&country=US&os=ios"},
+		{method: "POST", path: "/delivery/batch", body: `{"app_id": "metrics_app", "country": "US", "os": "ios", "placements": [{"placement_id": "top"}]}`},
+		{method: "GET", path: "/delivery?app_id=metrics_app&country=CA&os=ios"},
+	}
+	for _, target := range targets {
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest(target.method, target.path, strings.NewReader(target.body))
+			req.Header.Set("Accept", target.accept)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code, target.path)
+		}
+	}
+
+	assert.Equal(t, 10.0, testutil.ToFloat64(serves))
+	assert.Equal(t, 12.0, testutil.ToFloat64(requests))
+	assert.Equal(t, 2.0, testutil.ToFloat64(zeroFill))
+}
+
+func TestGetAvailableValues_CacheMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockCache := cache.NewMemoryCache()
+	handler := NewDeliveryHandler(nil, mockCache)
+	mockCache.Set("tenant:default:dimension_values:os:after:limit0", []byte(`{"dimension":"os","values":["ios"]}`), time.Minute)
+
+	router := gin.New()
+	router.GET("/dimensions/:dimension/values", handler.GetAvailableValues)
+
+	hits := utils.CacheActionsTotal.WithLabelValues("hit")
+	before := testutil.ToFloat64(hits)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/dimensions/os/values", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, before+1, testutil.ToFloat64(hits))
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/reach"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxReachBodyBytes = 64 << 10
+	maxProposedRules  = 100
+)
+
+type ReachHandler struct {
+	requestLog *reach.Log
+}
+
+func NewReachHandler(requestLog *reach.Log) *ReachHandler {
+	return &ReachHandler{
+		requestLog: requestLog,
+	}
+}
+
+type proposedRule struct {
+	Dimension string `json:"dimension"`
+	Type      string `json:"type"`
+	Operator  string `json:"operator"`
+	Value     string `json:"value"`
+}
+
+type reachEstimateRequest struct {
+	Rules      []proposedRule  `json:"rules"`
+	Expression json.RawMessage `json:"expression"`
+	Breakdown  []string        `json:"breakdown"`
+}
+
+// EstimateReach reports the share of recently sampled delivery requests of the tenant that a proposed rule set, and
+// optionally a targeting expression, would qualify for, broken down by the values of every dimension
+// involved. Rules are validated exactly as when they are stored.
+func (h *ReachHandler) EstimateReach(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReachBodyBytes)
+
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+
+	var req reachEstimateRequest
+	if err := decoder.Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.ErrorJSONGin(c, http.StatusRequestEntityTooLarge, "request body is too large")
+			return
+		}
+		utils.ErrorJSONGinWithDetails(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	rules, expression, breakdown, err := prepareProposedTargeting(req)
+	if err != nil {
+		utils.ErrorJSONGinWithDetails(c, http.StatusBadRequest, "invalid targeting", err.Error())
+		return
+	}
+
+	samples := h.requestLog.SamplesOf(tenant.FromContext(c.Request.Context()))
+	if len(samples) == 0 {
+		utils.ErrorJSONGin(c, http.StatusServiceUnavailable, "no delivery requests have been sampled yet")
+		return
+	}
+
+	estimate, err := reach.EstimateReach(samples, rules, expression, breakdown)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error estimating reach", "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
+
+// prepareProposedTargeting validates and normalizes the proposed rules, expression and breakdown dimensions
+func prepareProposedTargeting(req reachEstimateRequest) ([]models.TargetingRule, *targeting.Expression, []string, error) {
+	if string(req.Expression) == "null" {
+		req.Expression = nil
+	}
+	if len(req.Rules) == 0 && len(req.Expression) == 0 {
+		return nil, nil, nil, fmt.Errorf("at least one rule or an expression is required")
+	}
+	if len(req.Rules) > maxProposedRules {
+		return nil, nil, nil, fmt.Errorf("at most %d rules are allowed", maxProposedRules)
+	}
+
+	rules := make([]models.TargetingRule, 0, len(req.Rules))
+	for i, proposed := range req.Rules {
+		rule, err := targeting.PrepareRule(models.TargetingRule{
+			Dimension: proposed.Dimension,
+			Type:      proposed.Type,
+			Operator:  proposed.Operator,
+			Value:     proposed.Value,
+		})
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("rules[%d]: %v", i, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	var expression *targeting.Expression
+	if len(req.Expression) > 0 {
+		var err error
+		if expression, err = targeting.ParseExpression(req.Expression); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	breakdown := make([]string, 0, len(req.Breakdown))
+	for _, dimension := range req.Breakdown {
+		dimension = strings.ToLower(strings.TrimSpace(dimension))
+		if dimension == "" || reservedParams[dimension] {
+			return nil, nil, nil, fmt.Errorf("invalid breakdown dimension %q", dimension)
+		}
+		breakdown = append(breakdown, dimension)
+	}
+
+	return rules, expression, breakdown, nil
+}
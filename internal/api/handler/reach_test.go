@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/reach"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReachHandler_EstimateReach(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	requestLog := reach.NewLog(100, 1, 0)
+	deliveryHandler := newStaticHandler(nil, nil)
+	deliveryHandler.SetRequestLog(requestLog)
+	reachHandler := NewReachHandler(requestLog)
+
+	estimate := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/admin/reach-estimate", strings.NewReader(body))
+		return serve(reachHandler.EstimateReach, req)
+	}
+
+	body := `{"rules": [{"dimension": "country", "type": "include", "operator": "in", "value": "us,ca"}], "breakdown": ["os"]}`
+	assert.Equal(t, http.StatusServiceUnavailable, estimate(body).Code)
+
+	// Delivery samples the normalized request; invalid requests are not sampled
+	for _, query := range []string{
+		"app_id=app&country=usa&os=android",
+		"app_id=app&country=CA&os=ios",
+		"app_id=app&country=DE&os=android",
+		"app_id=app&country=DE",
+	} {
+		req, _ := http.NewRequest("GET", "/delivery?"+query, nil)
+		serve(deliveryHandler.DeliveryHandler, req)
+	}
+
+	w := estimate(body)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response reach.Estimate
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.SampledRequests)
+	assert.Equal(t, 2, response.MatchedRequests)
+	assert.Equal(t, []reach.ValueReach{
+		{Value: "android", Requests: 2, Matched: 1, Share: 0.5},
+		{Value: "ios", Requests: 1, Matched: 1, Share: 1},
+	}, response.Breakdown["os"])
+	assert.Contains(t, response.Breakdown, "country")
+
+	for name, body := range map[string]string{
+		"empty":              `{}`,
+		"invalid rule":       `{"rules": [{"dimension": "country", "type": "maybe", "value": "US"}]}`,
+		"invalid expression": `{"expression": {"and": []}}`,
+		"reserved breakdown": `{"rules": [{"dimension": "os", "type": "include", "value": "ios"}], "breakdown": ["page"]}`,
+		"unknown field":      `{"rule": []}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, estimate(body).Code, name)
+	}
+}
+
+func TestDeliveryHandler_RecordsSample(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	requestLog := reach.NewLog(100, 1, 0)
+	deliveryHandler := newStaticHandler([]models.Campaign{{CampaignID: "camp_001"}},
+		[]models.TargetingRule{{CampaignID: "camp_001", Dimension: "language", Type: "include", Operator: "eq", Value: "en"}})
+	deliveryHandler.SetRequestLog(requestLog)
+
+	query := "app_id=app&country=US&os=android&language=en&city=paris"
+	for _, handle := range []gin.HandlerFunc{deliveryHandler.DeliveryHandler, deliveryHandler.ExplainDelivery} {
+		req, _ := http.NewRequest("GET", "/delivery?"+query, nil)
+		require.Equal(t, http.StatusOK, serve(handle, req).Code)
+	}
+
+	// Explanations are not sampled, and untargeted dimensions are not kept
+	samples := requestLog.Samples()
+	require.Len(t, samples, 1)
+	assert.Equal(t, map[string][]string{
+		"app_id": {"app"}, "country": {"US"}, "os": {"android"}, "language": {"en"},
+	}, samples[0].Dimensions)
+}
@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"bufio"
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/segment"
+	"campaign/internal/domain/tenant"
+	"campaign/internal/infrastructure/cache"
+	"campaign/internal/infrastructure/db"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxSegmentUploadBytes = 256 << 20
+	maxUserIDLength       = 256
+)
+
+var segmentIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+type SegmentHandler struct {
+	db            *sql.DB
+	memeCache     *cache.MemoryCache
+	segmentStores *tenant.Stores[*segment.Store]
+}
+
+func NewSegmentHandler(db *sql.DB, memCache *cache.MemoryCache, stores *tenant.Stores[*segment.Store]) *SegmentHandler {
+	return &SegmentHandler{
+		db:            db,
+		memeCache:     memCache,
+		segmentStores: stores,
+	}
+}
+
+type createSegmentRequest struct {
+	SegmentID   string `json:"segment_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// CreateSegment creates an empty segment that targeting rules can reference with dimension "segment"
+func (h *SegmentHandler) CreateSegment(c *gin.Context) {
+	var req createSegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorJSONGinWithDetails(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	req.SegmentID = strings.TrimSpace(req.SegmentID)
+	req.Name = strings.TrimSpace(req.Name)
+	if !segmentIDPattern.MatchString(req.SegmentID) {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, "segment_id must be lower-case letters, digits, '_' or '-'")
+		return
+	}
+	if req.Name == "" {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	newSegment := models.Segment{SegmentID: req.SegmentID, Name: req.Name, Description: req.Description}
+	err := db.CreateSegment(c.Request.Context(), h.db, tenant.FromContext(c.Request.Context()), newSegment)
+	if errors.Is(err, db.ErrAlreadyExists) {
+		utils.ErrorJSONGin(c, http.StatusConflict, "segment already exists")
+		return
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error creating segment", "segment_id", req.SegmentID, "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newSegment)
+}
+
+// GetSegments lists segments with their member counts
+func (h *SegmentHandler) GetSegments(c *gin.Context) {
+	segments, err := db.GetSegments(c.Request.Context(), h.db, tenant.FromContext(c.Request.Context()))
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error getting segments", "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"segments": segments,
+	})
+}
+
+// UploadMembers bulk-loads user IDs into a segment. The body is CSV (text/csv; first column, optional
+// "user_id" header) or NDJSON (application/x-ndjson; {"user_id": "..."} per line).
+// With ?mode=replace the upload becomes the segment's complete member list.
+func (h *SegmentHandler) UploadMembers(c *gin.Context) {
+	segmentID := c.Param("segment_id")
+
+	mode := c.DefaultQuery("mode", "append")
+	if mode != "append" && mode != "replace" {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, "mode must be append or replace")
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxSegmentUploadBytes)
+
+	var userIDs []string
+	var err error
+	switch mediaType {
+	case "text/csv":
+		userIDs, err = parseCSVUserIDs(body)
+	case "application/x-ndjson", "application/jsonl", "application/json-seq":
+		userIDs, err = parseNDJSONUserIDs(body)
+	default:
+		utils.ErrorJSONGin(c, http.StatusUnsupportedMediaType, "Content-Type must be text/csv or application/x-ndjson")
+		return
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.ErrorJSONGin(c, http.StatusRequestEntityTooLarge, "upload is too large")
+			return
+		}
+		utils.ErrorJSONGinWithDetails(c, http.StatusBadRequest, "invalid upload", err.Error())
+		return
+	}
+
+	tenantID := tenant.FromContext(c.Request.Context())
+	added, err := db.AddSegmentMembers(c.Request.Context(), h.db, tenantID, segmentID, userIDs, mode == "replace")
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.ErrorJSONGin(c, http.StatusNotFound, "segment not found")
+		return
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error uploading segment members", "segment_id", segmentID, "error", err)
+		utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	// Make the new members visible to the tenant's delivery right away
+	store, err := h.segmentStores.Get(tenantID)
+	if err == nil {
+		err = store.Refresh()
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("error refreshing segment membership after upload", "error", err)
+	}
+	h.memeCache.DeletePrefix(tenantCachePrefix(tenantID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"segment_id": segmentID,
+		"mode":       mode,
+		"received":   len(userIDs),
+		"added":      added,
+	})
+}
+
+func parseCSVUserIDs(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var userIDs []string
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		userID := strings.TrimSpace(record[0])
+		if line == 1 && strings.EqualFold(userID, "user_id") {
+			continue
+		}
+		if err := validateUserID(userID, line); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
+}
+
+func parseNDJSONUserIDs(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	var userIDs []string
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record struct {
+			UserID string `json:"user_id"`
+		}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		userID := strings.TrimSpace(record.UserID)
+		if err := validateUserID(userID, line); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+func validateUserID(userID string, line int) error {
+	if userID == "" {
+		return fmt.Errorf("line %d: empty user_id", line)
+	}
+	if len(userID) > maxUserIDLength {
+		return fmt.Errorf("line %d: user_id longer than %d bytes", line, maxUserIDLength)
+	}
+	return nil
+}
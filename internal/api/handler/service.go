@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestError is an error caused by the caller's input. HTTP maps it to 400 and gRPC to InvalidArgument;
+// any other error is an internal error.
+type RequestError struct {
+	Message string
+}
+
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+// writeServiceError writes the HTTP response for an error returned by the shared delivery code
+func writeServiceError(c *gin.Context, err error) {
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		utils.ErrorJSONGin(c, http.StatusBadRequest, requestErr.Message)
+		return
+	}
+
+	logging.FromContext(c.Request.Context()).Error("error serving delivery request", "error", err)
+	utils.ErrorJSONGin(c, http.StatusInternalServerError, utils.InternalServerError)
+}
+
+// The methods below expose delivery and discovery to transports other than Gin. They run the same
+// normalization, segment resolution, validation, targeting and response cache as the HTTP handlers,
+// but without request enrichment, since there is no client IP or User-Agent to derive values from.
+// The tenant is taken from ctx, as set by the transport.
+
+// Deliver returns one keyset page of the campaigns matching the dimensions. cursor is the previous page's
+// next_cursor, empty for the first page; limit 0 means the default page size.
+func (h *DeliveryHandler) Deliver(ctx context.Context, dimensions map[string][]string, userID, cursor string, limit int) (*models.DeliveryPage, error) {
+	params := normalizeDimensions(dimensions, userID)
+	if err := h.prepareTargeting(ctx, params); err != nil {
+		return nil, err
+	}
+
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, &RequestError{Message: err.Error()}
+	}
+
+	if limit == 0 {
+		limit = utils.DefaultApiPageLimit
+	}
+	if limit < 1 || limit > 100 {
+		return nil, &RequestError{Message: fmt.Sprintf("invalid limit parameter: %d", limit)}
+	}
+
+	data, _, _, err := h.cursorPage(ctx, params, after, limit, jsonEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	var page models.DeliveryPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// BatchDeliver fills the placements in order, serving each campaign in at most one of them
+func (h *DeliveryHandler) BatchDeliver(ctx context.Context, dimensions map[string][]string, userID string, placements []models.Placement) (*models.BatchDeliveryResponse, error) {
+	placements, err := validatePlacements(placements)
+	if err != nil {
+		return nil, &RequestError{Message: err.Error()}
+	}
+
+	params := normalizeDimensions(dimensions, userID)
+	delete(params, placementDimension)
+	if err := h.prepareTargeting(ctx, params); err != nil {
+		return nil, err
+	}
+
+	data, _, err := h.batchPage(ctx, params, placements, jsonEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	var response models.BatchDeliveryResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListDimensions returns every dimension used by targeting rules
+func (h *DeliveryHandler) ListDimensions(ctx context.Context) ([]string, error) {
+	data, _, _, err := h.discoveryData(ctx, dimensionsCacheKey, h.loadDimensions, jsonEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	var response dimensionsResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	return response.Dimensions, nil
+}
+
+// ListValues returns one page of a dimension's values and the cursor of the next page, if any
+func (h *DeliveryHandler) ListValues(ctx context.Context, dimension, cursor string, limit int) ([]string, string, error) {
+	if dimension == "" {
+		return nil, "", &RequestError{Message: "dimension parameter is required"}
+	}
+
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", &RequestError{Message: err.Error()}
+	}
+
+	if limit == 0 {
+		limit = utils.DefaultValuesPageLimit
+	}
+	if limit < 1 || limit > utils.MaxValuesPageLimit {
+		return nil, "", &RequestError{Message: fmt.Sprintf("invalid limit parameter: %d", limit)}
+	}
+
+	cacheKey, load := h.valuesLoader(dimension, after, limit)
+	data, _, _, err := h.discoveryData(ctx, cacheKey, load, jsonEncoding)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var response valuesResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, "", err
+	}
+	return response.Values, response.NextCursor, nil
+}
+
+// normalizeDimensions normalizes caller-supplied dimensions the same way as query parameters
+func normalizeDimensions(dimensions map[string][]string, userID string) map[string][]string {
+	params := make(map[string][]string, len(dimensions)+1)
+	for dimension, values := range dimensions {
+		if normalized := normalizeValues(dimension, values); len(normalized) > 0 {
+			params[dimension] = normalized
+		}
+	}
+
+	if normalized := normalizeValues("user_id", []string{userID}); len(normalized) > 0 {
+		params["user_id"] = normalized
+	}
+	return params
+}
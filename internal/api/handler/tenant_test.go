@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"campaign/internal/domain/auth"
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
+	"campaign/internal/infrastructure/cache"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Both tenants use the same campaign ID for different campaigns
+	campaigns := map[string][]models.Campaign{
+		"games": {{CampaignID: "camp_001", ImageURL: "games.jpg"}},
+		"news":  {{CampaignID: "camp_001", ImageURL: "news.jpg"}, {CampaignID: "camp_002", ImageURL: "news2.jpg"}},
+	}
+	directory := tenant.NewStore(slog.Default(), func() ([]models.Tenant, error) {
+		return []models.Tenant{
+			{TenantID: "games", Hosts: []string{"ads.games.example.com"}},
+			{TenantID: "news", Hosts: []string{"ads.news.example.com"}},
+		}, nil
+	})
+	stores := tenant.NewStores(directory, func(tenantID string) *targeting.Store {
+		return targeting.NewStore(slog.Default(), func() ([]models.Campaign, []models.TargetingRule, error) {
+			return campaigns[tenantID], nil, nil
+		})
+	})
+	memCache := cache.NewMemoryCache()
+	handler := NewTenantDeliveryHandler(nil, memCache, stores, nil)
+
+	newsKey, newsAPIKey, err := auth.GenerateKey("news-sdk", time.Now().Add(-time.Minute), nil)
+	require.NoError(t, err)
+	keys := auth.NewStore(slog.Default(), func() ([]models.APIClient, []models.APIKey, error) {
+		clients := []models.APIClient{{ClientID: "news-sdk", TenantID: "news", Scopes: []string{auth.ScopeDeliveryRead}, Enabled: true}}
+		return clients, []models.APIKey{newsAPIKey}, nil
+	})
+
+	newRouter := func(fallback string, withKeys bool) *gin.Engine {
+		router := gin.New()
+		var middleware []gin.HandlerFunc
+		if withKeys {
+			middleware = append(middleware, APIKeyAuth(keys, auth.ScopeDeliveryRead))
+		}
+		middleware = append(middleware, TenantMiddleware(tenant.NewResolver(directory, fallback)))
+		router.GET("/delivery", append(middleware, func(c *gin.Context) {
+			c.Header("X-Tenant", c.GetString("tenant_id"))
+			handler.DeliveryHandler(c)
+		})...)
+		return router
+	}
+
+	deliver := func(router *gin.Engine, host, key string) (*httptest.ResponseRecorder, []models.DeliveryResponse) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/delivery?app_id=test_app&country=US&os=android", nil)
+		req.Host = host
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		router.ServeHTTP(w, req)
+
+		var response []models.DeliveryResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w, response
+	}
+
+	router := newRouter(tenant.DefaultID, false)
+
+	w, response := deliver(router, "ads.games.example.com", "")
+	assert.Equal(t, "games", w.Header().Get("X-Tenant"))
+	assert.Equal(t, []models.DeliveryResponse{{CampaignID: "camp_001", ImageURL: "games.jpg"}}, response)
+
+	// The same request on another tenant's host misses the first tenant's cached response
+	w, response = deliver(router, "ads.news.example.com:443", "")
+	assert.Equal(t, "news", w.Header().Get("X-Tenant"))
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache-Type"))
+	require.Len(t, response, 2)
+	assert.Equal(t, "news.jpg", response[0].ImageURL)
+
+	w, _ = deliver(router, "ads.games.example.com", "")
+	assert.Equal(t, "IN_MEMORY_HIT", w.Header().Get("X-Cache-Type"))
+
+	// Unknown hosts get the fallback tenant, or are rejected without one
+	w, response = deliver(router, "unknown.example.com", "")
+	assert.Equal(t, tenant.DefaultID, w.Header().Get("X-Tenant"))
+	assert.Empty(t, response)
+
+	w, _ = deliver(newRouter("", false), "unknown.example.com", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// An API key selects its client's tenant whatever the host
+	w, response = deliver(newRouter("", true), "ads.games.example.com", newsKey)
+	assert.Equal(t, "news", w.Header().Get("X-Tenant"))
+	assert.Len(t, response, 2)
+
+	// Invalidating one tenant keeps the other's cached responses
+	memCache.DeletePrefix(tenantCachePrefix("news"))
+	w, _ = deliver(router, "ads.games.example.com", "")
+	assert.Equal(t, "IN_MEMORY_HIT", w.Header().Get("X-Cache-Type"))
+	w, _ = deliver(router, "ads.news.example.com", "")
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache-Type"))
+}
+
+func TestTenantMiddleware_StaffTenants(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	directory := tenant.NewStore(slog.Default(), func() ([]models.Tenant, error) {
+		return []models.Tenant{
+			{TenantID: "games", Hosts: []string{"admin.games.example.com"}},
+			{TenantID: "news", Hosts: []string{"admin.news.example.com"}},
+		}, nil
+	})
+
+	router := gin.New()
+	var user *auth.User
+	router.PUT("/admin", func(c *gin.Context) {
+		if user != nil {
+			c.Request = c.Request.WithContext(auth.NewUserContext(c.Request.Context(), user))
+		}
+	}, TenantMiddleware(tenant.NewResolver(directory, tenant.DefaultID).WithoutFallback()), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("tenant_id"))
+	})
+	request := func(host string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/admin", nil)
+		req.Host = host
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A token listing one tenant is bound to it, whatever the host
+	user = &auth.User{Subject: "staff-1", Tenants: []string{"news"}}
+	w := request("admin.games.example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "news", w.Body.String())
+
+	// A single tenant that does not exist is denied rather than given a store
+	user = &auth.User{Subject: "staff-1", Tenants: []string{"gmaes"}}
+	assert.Equal(t, http.StatusForbidden, request("admin.games.example.com").Code)
+
+	// With several tenants the host picks one, which must be listed
+	user = &auth.User{Subject: "staff-1", Tenants: []string{"games", "news"}}
+	w = request("admin.games.example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "games", w.Body.String())
+
+	user = &auth.User{Subject: "staff-1", Tenants: []string{"games", "sports"}}
+	assert.Equal(t, http.StatusForbidden, request("admin.news.example.com").Code)
+
+	// Tokens without tenants administer none
+	user = &auth.User{Subject: "staff-1"}
+	assert.Equal(t, http.StatusForbidden, request("admin.games.example.com").Code)
+
+	// Admin requests on unknown hosts are rejected rather than given the fallback tenant
+	user = nil
+	assert.Equal(t, http.StatusBadRequest, request("unknown.example.com").Code)
+	user = &auth.User{Subject: "staff-1", Tenants: []string{"games", "news"}}
+	assert.Equal(t, http.StatusBadRequest, request("unknown.example.com").Code)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"campaign/internal/domain/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestDeliveryHandler_CacheSpans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	handler := newStaticHandler([]models.Campaign{{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"}}, nil)
+
+	router := gin.New()
+	router.Use(otelgin.Middleware("test", otelgin.WithPropagators(propagation.TraceContext{})))
+	router.GET("/delivery", handler.DeliveryHandler)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/delivery?app_id=app&country=US&os=android", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	spans := recorder.Ended()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	}
+	assert.Equal(t, []string{"MemoryCache.Get", "MemoryCache.Set", "/delivery", "MemoryCache.Get", "/delivery"}, names)
+
+	// Cache spans are children of their request's server span, which continues the caller's trace
+	assert.Equal(t, spans[2].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, "00f067aa0ba902b7", spans[2].Parent().SpanID().String())
+	assert.Contains(t, spans[3].Attributes(), attribute.Bool("cache.hit", true))
+}
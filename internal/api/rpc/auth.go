@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"campaign/internal/domain/auth"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// apiKeyMetadata carries the API key of gRPC calls
+const apiKeyMetadata = "x-api-key"
+
+// healthServicePrefix marks the health service, which stays open like the HTTP probes
+const healthServicePrefix = "/grpc.health.v1.Health/"
+
+// authInterceptor authenticates calls like handler.APIKeyAuth does HTTP requests. Every delivery service
+// method requires the delivery:read scope.
+func authInterceptor(store *auth.Store) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, healthServicePrefix) {
+			return next(ctx, req)
+		}
+
+		keyring, err := store.Keyring()
+		if err != nil {
+			logging.FromContext(ctx).Error("error loading api keys", "error", err)
+			return nil, status.Error(codes.Unavailable, utils.InternalServerError)
+		}
+
+		var raw string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(apiKeyMetadata); len(values) > 0 {
+				raw = values[0]
+			}
+		}
+
+		client, err := keyring.Authenticate(raw, time.Now())
+		if err != nil {
+			reason := auth.FailureReason(err)
+			utils.APIAuthFailuresTotal.WithLabelValues(reason).Inc()
+			logging.FromContext(ctx).Warn("api key rejected", "reason", reason)
+			return nil, status.Error(codes.Unauthenticated, utils.ErrUnauthorized)
+		}
+
+		ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("client_id", client.ID))
+		if !client.HasScope(auth.ScopeDeliveryRead) {
+			utils.APIAuthFailuresTotal.WithLabelValues("insufficient_scope").Inc()
+			logging.FromContext(ctx).Warn("api key lacks scope", "scope", auth.ScopeDeliveryRead)
+			return nil, status.Error(codes.PermissionDenied, utils.ErrForbidden)
+		}
+
+		utils.APIClientRequestsTotal.WithLabelValues(client.ID).Inc()
+		return next(auth.NewContext(ctx, client), req)
+	}
+}
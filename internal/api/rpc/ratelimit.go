@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"campaign/internal/api/rpc/deliverypb"
+	"campaign/internal/domain/auth"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimit limits delivery calls like utils.RateLimitMiddleware limits HTTP requests. Keys are built the
+// same way, so with the same Group and Store a client has one budget across the HTTP and gRPC APIs.
+type RateLimit struct {
+	Group string
+	Limit utils.RateLimit
+	Store utils.RateLimitStore
+	// Key is what calls are limited by: client, app_id or ip, as RATE_LIMIT_DELIVERY_KEY
+	Key string
+}
+
+// rateLimitInterceptor takes a token for every delivery call, answering ResourceExhausted with a
+// retry-after header when none is left. When the store fails, calls are let through. It must run after
+// authInterceptor for client keys to see the client.
+func rateLimitInterceptor(limit *RateLimit) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, healthServicePrefix) {
+			return next(ctx, req)
+		}
+
+		result, err := limit.Store.Take(ctx, limit.Group+":"+limit.key(ctx, req), limit.Limit)
+		if err != nil {
+			utils.RateLimitErrorsTotal.WithLabelValues(limit.Group).Inc()
+			logging.FromContext(ctx).Warn("rate limit store unavailable, call not limited", "group", limit.Group, "error", err)
+			return next(ctx, req)
+		}
+
+		if !result.Allowed {
+			utils.RateLimitRejectionsTotal.WithLabelValues(limit.Group).Inc()
+			retryAfter := max(int(math.Ceil(result.RetryAfter.Seconds())), 1)
+			if err := grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(retryAfter))); err != nil {
+				logging.FromContext(ctx).Warn("error setting retry-after header", "error", err)
+			}
+			return nil, status.Error(codes.ResourceExhausted, utils.ErrRateLimited)
+		}
+		return next(ctx, req)
+	}
+}
+
+// key returns the identity a call is limited by, matching the keys of utils.RateLimitByClient,
+// utils.RateLimitByAppID and utils.RateLimitByIP
+func (l *RateLimit) key(ctx context.Context, req interface{}) string {
+	if l.Key == "ip" {
+		return peerKey(ctx)
+	}
+
+	key := peerKey(ctx)
+	client, authenticated := auth.FromContext(ctx)
+	if authenticated {
+		key = "client:" + client.ID
+	}
+	var appID string
+	if l.Key == "app_id" {
+		appID = requestAppID(req)
+	}
+	switch {
+	case appID == "":
+		return key
+	case authenticated:
+		return key + ":app:" + appID
+	default:
+		return "app:" + appID
+	}
+}
+
+// peerKey limits by the address the call came from
+func peerKey(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "ip:"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+// requestAppID returns the app_id dimension of a delivery call, if any
+func requestAppID(req interface{}) string {
+	var dimensions map[string]*deliverypb.DimensionValues
+	switch req := req.(type) {
+	case *deliverypb.DeliverRequest:
+		dimensions = req.GetDimensions()
+	case *deliverypb.BatchDeliverRequest:
+		dimensions = req.GetDimensions()
+	}
+	if values := dimensions["app_id"].GetValues(); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+// Package rpc serves the delivery API over gRPC, backed by the same delivery code as the HTTP handlers.
+package rpc
+
+//go:generate protoc -I ../../../proto --go_out=../../.. --go_opt=module=campaign --go-grpc_out=../../.. --go-grpc_opt=module=campaign delivery/v1/delivery.proto
+
+import (
+	"campaign/internal/api/handler"
+	"campaign/internal/api/rpc/deliverypb"
+	"campaign/internal/domain/auth"
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/tenant"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements deliverypb.DeliveryServiceServer
+type Server struct {
+	deliverypb.UnimplementedDeliveryServiceServer
+	delivery *handler.DeliveryHandler
+}
+
+func NewServer(delivery *handler.DeliveryHandler) *Server {
+	return &Server{delivery: delivery}
+}
+
+// NewGRPCServer creates a gRPC server with the delivery service, the standard health service, tracing and
+// logging, recovery and metrics interceptors. With keys set, delivery calls require an API key with the
+// delivery:read scope. With tenants set, each call is served for the tenant of its API key or :authority
+// host; without, every call is served for the default tenant. With rateLimit set, delivery calls are rate
+// limited. The returned health server is flipped to NOT_SERVING on shutdown.
+func NewGRPCServer(delivery *handler.DeliveryHandler, logger *slog.Logger, keys *auth.Store, tenants *tenant.Resolver, rateLimit *RateLimit) (*grpc.Server, *health.Server) {
+	// Recovery comes right after logging and metrics, so that a panic in any later interceptor is recovered,
+	// and logged and counted as the Internal error it turns into
+	interceptors := []grpc.UnaryServerInterceptor{
+		utils.GRPCLoggingInterceptor(logger),
+		utils.GRPCPrometheusInterceptor(),
+		recoveryInterceptor,
+	}
+	if keys != nil {
+		interceptors = append(interceptors, authInterceptor(keys))
+	}
+	if tenants != nil {
+		interceptors = append(interceptors, tenantInterceptor(tenants))
+	}
+	if rateLimit != nil {
+		interceptors = append(interceptors, rateLimitInterceptor(rateLimit))
+	}
+
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	)
+
+	deliverypb.RegisterDeliveryServiceServer(server, NewServer(delivery))
+
+	healthServer := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+
+	return server, healthServer
+}
+
+func (s *Server) Deliver(ctx context.Context, req *deliverypb.DeliverRequest) (*deliverypb.DeliverResponse, error) {
+	start := time.Now()
+	defer func() {
+		utils.DeliveryAPILatency.Observe(time.Since(start).Seconds())
+	}()
+
+	page, err := s.delivery.Deliver(ctx, dimensionsFromProto(req.GetDimensions()), req.GetUserId(), req.GetCursor(), int(req.GetLimit()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &deliverypb.DeliverResponse{
+		Campaigns:  campaignsToProto(page.Campaigns),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+func (s *Server) BatchDeliver(ctx context.Context, req *deliverypb.BatchDeliverRequest) (*deliverypb.BatchDeliverResponse, error) {
+	start := time.Now()
+	defer func() {
+		utils.DeliveryAPILatency.Observe(time.Since(start).Seconds())
+	}()
+
+	placements := make([]models.Placement, 0, len(req.GetPlacements()))
+	for _, placement := range req.GetPlacements() {
+		placements = append(placements, models.Placement{
+			PlacementID: placement.GetPlacementId(),
+			Limit:       int(placement.GetLimit()),
+		})
+	}
+
+	batch, err := s.delivery.BatchDeliver(ctx, dimensionsFromProto(req.GetDimensions()), req.GetUserId(), placements)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	response := &deliverypb.BatchDeliverResponse{
+		Placements: make([]*deliverypb.PlacementCampaigns, 0, len(batch.Placements)),
+	}
+	for _, placement := range batch.Placements {
+		response.Placements = append(response.Placements, &deliverypb.PlacementCampaigns{
+			PlacementId: placement.PlacementID,
+			Campaigns:   campaignsToProto(placement.Campaigns),
+		})
+	}
+
+	return response, nil
+}
+
+func (s *Server) ListDimensions(ctx context.Context, req *deliverypb.ListDimensionsRequest) (*deliverypb.ListDimensionsResponse, error) {
+	dimensions, err := s.delivery.ListDimensions(ctx)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &deliverypb.ListDimensionsResponse{Dimensions: dimensions}, nil
+}
+
+func (s *Server) ListValues(ctx context.Context, req *deliverypb.ListValuesRequest) (*deliverypb.ListValuesResponse, error) {
+	values, nextCursor, err := s.delivery.ListValues(ctx, req.GetDimension(), req.GetCursor(), int(req.GetLimit()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &deliverypb.ListValuesResponse{Values: values, NextCursor: nextCursor}, nil
+}
+
+func dimensionsFromProto(dimensions map[string]*deliverypb.DimensionValues) map[string][]string {
+	result := make(map[string][]string, len(dimensions))
+	for dimension, values := range dimensions {
+		result[dimension] = values.GetValues()
+	}
+	return result
+}
+
+func campaignsToProto(campaigns []models.DeliveryResponse) []*deliverypb.Campaign {
+	result := make([]*deliverypb.Campaign, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		result = append(result, &deliverypb.Campaign{
+			CampaignId:   campaign.CampaignID,
+			ImageUrl:     campaign.ImageURL,
+			CallToAction: campaign.CallToAction,
+		})
+	}
+	return result
+}
+
+// toStatus maps request errors to InvalidArgument and hides the details of everything else
+func toStatus(ctx context.Context, err error) error {
+	var requestErr *handler.RequestError
+	if errors.As(err, &requestErr) {
+		return status.Error(codes.InvalidArgument, requestErr.Message)
+	}
+
+	logging.FromContext(ctx).Error("error serving grpc request", "error", err)
+	return status.Error(codes.Internal, utils.InternalServerError)
+}
+
+// recoveryInterceptor turns a panic in a handler or a later interceptor into an Internal error, like
+// gin.Recovery does for HTTP
+func recoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.FromContext(ctx).Error("panic serving grpc request", "method", info.FullMethod, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, utils.InternalServerError)
+		}
+	}()
+
+	return next(ctx, req)
+}
@@ -0,0 +1,287 @@
+package rpc
+
+import (
+	"bytes"
+	"campaign/internal/api/handler"
+	"campaign/internal/api/rpc/deliverypb"
+	"campaign/internal/domain/auth"
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
+	"campaign/internal/infrastructure/cache"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) deliverypb.DeliveryServiceClient {
+	return newTestClientWithLogger(t, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func newTestClientWithLogger(t *testing.T, logger *slog.Logger) deliverypb.DeliveryServiceClient {
+	return newTestClientWithAuth(t, logger, nil)
+}
+
+func newTestClientWithAuth(t *testing.T, logger *slog.Logger, keys *auth.Store) deliverypb.DeliveryServiceClient {
+	store := targeting.NewStore(slog.Default(), func() ([]models.Campaign, []models.TargetingRule, error) {
+		campaigns := []models.Campaign{
+			{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"},
+			{CampaignID: "camp_002", ImageURL: "b.jpg", CallToAction: "B"},
+			{CampaignID: "camp_003", ImageURL: "c.jpg", CallToAction: "C"},
+		}
+		rules := []models.TargetingRule{
+			{CampaignID: "camp_003", Dimension: "country", Type: "include", Operator: "eq", Value: "CA"},
+		}
+		return campaigns, rules, nil
+	})
+	delivery := handler.NewDeliveryHandlerWithStore(nil, cache.NewMemoryCache(), store, nil)
+
+	listener := bufconn.Listen(1 << 20)
+	server, _ := NewGRPCServer(delivery, logger, keys, nil, nil)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return deliverypb.NewDeliveryServiceClient(conn)
+}
+
+func dimensions(values map[string]string) map[string]*deliverypb.DimensionValues {
+	result := make(map[string]*deliverypb.DimensionValues, len(values))
+	for dimension, value := range values {
+		result[dimension] = &deliverypb.DimensionValues{Values: []string{value}}
+	}
+	return result
+}
+
+func campaignIDs(campaigns []*deliverypb.Campaign) []string {
+	var ids []string
+	for _, campaign := range campaigns {
+		ids = append(ids, campaign.GetCampaignId())
+	}
+	return ids
+}
+
+func TestDeliver(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	first, err := client.Deliver(ctx, &deliverypb.DeliverRequest{
+		Dimensions: dimensions(map[string]string{"app_id": "test_app", "country": "usa", "os": "android"}),
+		Limit:      1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"camp_001"}, campaignIDs(first.GetCampaigns()))
+	assert.NotEmpty(t, first.GetNextCursor())
+
+	second, err := client.Deliver(ctx, &deliverypb.DeliverRequest{
+		Dimensions: dimensions(map[string]string{"app_id": "test_app", "country": "usa", "os": "android"}),
+		Limit:      1,
+		Cursor:     first.GetNextCursor(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"camp_002"}, campaignIDs(second.GetCampaigns()))
+	assert.Empty(t, second.GetNextCursor())
+}
+
+func TestDeliver_InvalidArgument(t *testing.T) {
+	client := newTestClient(t)
+
+	_, err := client.Deliver(context.Background(), &deliverypb.DeliverRequest{
+		Dimensions: dimensions(map[string]string{"app_id": "test_app", "country": "US"}),
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "missing os parameter", status.Convert(err).Message())
+}
+
+func TestBatchDeliver(t *testing.T) {
+	client := newTestClient(t)
+
+	response, err := client.BatchDeliver(context.Background(), &deliverypb.BatchDeliverRequest{
+		Dimensions: dimensions(map[string]string{"app_id": "test_app", "country": "CA", "os": "ios"}),
+		Placements: []*deliverypb.Placement{{PlacementId: "banner", Limit: 1}, {PlacementId: "feed"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.GetPlacements(), 2)
+	assert.Equal(t, []string{"camp_001"}, campaignIDs(response.GetPlacements()[0].GetCampaigns()))
+	assert.Equal(t, []string{"camp_002", "camp_003"}, campaignIDs(response.GetPlacements()[1].GetCampaigns()))
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	reader, readerKey, err := auth.GenerateKey("reader", time.Now().Add(-time.Minute), nil)
+	require.NoError(t, err)
+	admin, adminKey, err := auth.GenerateKey("admin", time.Now().Add(-time.Minute), nil)
+	require.NoError(t, err)
+	keys := auth.NewStore(slog.Default(), func() ([]models.APIClient, []models.APIKey, error) {
+		clients := []models.APIClient{
+			{ClientID: "reader", Scopes: []string{auth.ScopeDeliveryRead}, Enabled: true},
+			{ClientID: "admin", Scopes: []string{auth.ScopeAdminWrite}, Enabled: true},
+		}
+		return clients, []models.APIKey{readerKey, adminKey}, nil
+	})
+	client := newTestClientWithAuth(t, slog.New(slog.NewTextHandler(io.Discard, nil)), keys)
+
+	deliver := func(key string) error {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, apiKeyMetadata, key)
+		}
+		_, err := client.Deliver(ctx, &deliverypb.DeliverRequest{
+			Dimensions: dimensions(map[string]string{"app_id": "app", "os": "android", "country": "US"}),
+		})
+		return err
+	}
+
+	assert.NoError(t, deliver(reader))
+	assert.Equal(t, codes.Unauthenticated, status.Code(deliver("")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(deliver(reader+"x")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(deliver(admin)))
+}
+
+func TestTenantInterceptor(t *testing.T) {
+	tenants := tenant.NewStore(slog.Default(), func() ([]models.Tenant, error) {
+		return []models.Tenant{
+			{TenantID: "games", Hosts: []string{"ads.games.example.com"}},
+			{TenantID: "news"},
+		}, nil
+	})
+	stores := tenant.NewStores(tenants, func(tenantID string) *targeting.Store {
+		return targeting.NewStore(slog.Default(), func() ([]models.Campaign, []models.TargetingRule, error) {
+			return []models.Campaign{{CampaignID: tenantID + "_camp", ImageURL: "a.jpg", CallToAction: "A"}}, nil, nil
+		})
+	})
+	delivery := handler.NewTenantDeliveryHandler(nil, cache.NewMemoryCache(), stores, nil)
+
+	listener := bufconn.Listen(1 << 20)
+	server, _ := NewGRPCServer(delivery, slog.New(slog.NewTextHandler(io.Discard, nil)), nil, tenant.NewResolver(tenants, ""), nil)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	deliver := func(authority string) ([]string, error) {
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithAuthority(authority),
+		)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		resp, err := deliverypb.NewDeliveryServiceClient(conn).Deliver(context.Background(), &deliverypb.DeliverRequest{
+			Dimensions: dimensions(map[string]string{"app_id": "app", "os": "android", "country": "US"}),
+		})
+		return campaignIDs(resp.GetCampaigns()), err
+	}
+
+	ids, err := deliver("ads.games.example.com:443")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"games_camp"}, ids)
+
+	_, err = deliver("unknown.example.com")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	store := targeting.NewStore(slog.Default(), func() ([]models.Campaign, []models.TargetingRule, error) {
+		return []models.Campaign{{CampaignID: "camp_001", ImageURL: "a.jpg", CallToAction: "A"}}, nil, nil
+	})
+	delivery := handler.NewDeliveryHandlerWithStore(nil, cache.NewMemoryCache(), store, nil)
+	rateLimit := &RateLimit{
+		Group: "grpc_test",
+		Limit: utils.RateLimit{Rate: 0.01, Burst: 1},
+		Store: utils.NewMemoryRateLimitStore(),
+		Key:   "app_id",
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server, _ := NewGRPCServer(delivery, slog.New(slog.NewTextHandler(io.Discard, nil)), nil, nil, rateLimit)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := deliverypb.NewDeliveryServiceClient(conn)
+
+	deliver := func(appID string) (metadata.MD, error) {
+		var header metadata.MD
+		_, err := client.Deliver(context.Background(), &deliverypb.DeliverRequest{
+			Dimensions: dimensions(map[string]string{"app_id": appID, "os": "android", "country": "US"}),
+		}, grpc.Header(&header))
+		return header, err
+	}
+
+	_, err = deliver("game")
+	require.NoError(t, err)
+
+	rejected := testutil.ToFloat64(utils.RateLimitRejectionsTotal.WithLabelValues("grpc_test"))
+	header, err := deliver("game")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.NotEmpty(t, header.Get("retry-after"))
+	assert.Equal(t, rejected+1, testutil.ToFloat64(utils.RateLimitRejectionsTotal.WithLabelValues("grpc_test")))
+
+	// Another app has its own bucket
+	_, err = deliver("news")
+	assert.NoError(t, err)
+}
+
+func TestRequestLogging(t *testing.T) {
+	buf := &lockedBuffer{}
+	client := newTestClientWithLogger(t, logging.New(buf, "info"))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req_grpc_1")
+	_, err := client.Deliver(ctx, &deliverypb.DeliverRequest{Dimensions: map[string]*deliverypb.DimensionValues{
+		"app_id": {Values: []string{"app"}},
+	}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(buf.String()), &record))
+	assert.Equal(t, "grpc request", record["msg"])
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "req_grpc_1", record["request_id"])
+	assert.Equal(t, "/campaign.delivery.v1.DeliveryService/Deliver", record["method"])
+	assert.Equal(t, "InvalidArgument", record["code"])
+}
+
+// lockedBuffer collects log output written by the server goroutines
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"campaign/internal/domain/auth"
+	"campaign/internal/domain/tenant"
+	"campaign/pkg/logging"
+	"campaign/pkg/utils"
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authorityMetadata carries the host a gRPC call was addressed to
+const authorityMetadata = ":authority"
+
+// tenantInterceptor resolves the tenant of calls like handler.TenantMiddleware does for HTTP requests,
+// from the API client or else the :authority host. It must run after authInterceptor.
+func tenantInterceptor(resolver *tenant.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, healthServicePrefix) {
+			return next(ctx, req)
+		}
+
+		var clientTenant, host string
+		if client, ok := auth.FromContext(ctx); ok {
+			clientTenant = client.TenantID
+		}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(authorityMetadata); len(values) > 0 {
+				host = values[0]
+			}
+		}
+
+		tenantID, err := resolver.Resolve(clientTenant, host)
+		if errors.Is(err, tenant.ErrUnresolved) {
+			logging.FromContext(ctx).Warn("call matches no tenant", "host", host)
+			return nil, status.Error(codes.InvalidArgument, utils.ErrUnknownTenant)
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("error resolving tenant", "error", err)
+			return nil, status.Error(codes.Unavailable, utils.InternalServerError)
+		}
+
+		ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("tenant_id", tenantID))
+		return next(tenant.NewContext(ctx, tenantID), req)
+	}
+}
@@ -0,0 +1,29 @@
+package auth
+
+import "context"
+
+type contextKey struct{}
+
+type userContextKey struct{}
+
+// NewContext returns a copy of ctx carrying the authenticated client
+func NewContext(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, contextKey{}, client)
+}
+
+// FromContext returns the authenticated client of the request, if any
+func FromContext(ctx context.Context) (*Client, bool) {
+	client, ok := ctx.Value(contextKey{}).(*Client)
+	return client, ok
+}
+
+// NewUserContext returns a copy of ctx carrying the authenticated staff user
+func NewUserContext(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// UserFromContext returns the authenticated staff user of the request, if any
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userContextKey{}).(*User)
+	return user, ok
+}
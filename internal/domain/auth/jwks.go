@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// jwksMaxBytes bounds the JWKS document read from a URL
+const jwksMaxBytes = 1 << 20
+
+// ErrUnknownKey is returned for a token signed with a key that is not in the key set, even after a refresh
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// ErrKeysUnavailable is returned while no key set has been loaded and a reload is not yet allowed
+var ErrKeysUnavailable = errors.New("signing keys unavailable")
+
+// KeySetLoader fetches the current signing keys by key ID
+type KeySetLoader func(ctx context.Context) (map[string]crypto.PublicKey, error)
+
+// jwk is one key of a JWKS document (RFC 7517); only signature keys of type RSA and EC are used
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS returns the RSA and EC signature keys of a JWKS document by key ID. Keys of other types or uses
+// are skipped, so that a provider adding new kinds of keys does not break verification.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(document.Keys))
+	for _, key := range document.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		var publicKey crypto.PublicKey
+		var err error
+		switch key.Kty {
+		case "RSA":
+			publicKey, err = key.rsaPublicKey()
+		case "EC":
+			publicKey, err = key.ecdsaPublicKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwks key %q: %w", key.Kid, err)
+		}
+		keys[key.Kid] = publicKey
+	}
+	return keys, nil
+}
+
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid rsa exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+func (k jwk) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	publicKey := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+		return nil, errors.New("point not on curve")
+	}
+	return publicKey, nil
+}
+
+// JWKSFromURL loads the key set from a JWKS endpoint, such as an OIDC provider's jwks_uri
+func JWKSFromURL(client *http.Client, url string) KeySetLoader {
+	return func(ctx context.Context) (map[string]crypto.PublicKey, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching jwks: status %d", resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, jwksMaxBytes))
+		if err != nil {
+			return nil, err
+		}
+		return ParseJWKS(data)
+	}
+}
+
+// JWKSFromFile loads the key set from a local JWKS file, re-read on every refresh
+func JWKSFromFile(path string) KeySetLoader {
+	return func(ctx context.Context) (map[string]crypto.PublicKey, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return ParseJWKS(data)
+	}
+}
+
+// KeySet caches the signing keys of the identity provider. Keys are reloaded every refresh interval, and
+// also when a token names an unknown key ID, since that is how a key rotation shows up first; such reloads
+// happen at most once per minRefresh so that tokens with made-up key IDs cannot flood the provider.
+type KeySet struct {
+	loader      KeySetLoader
+	logger      *slog.Logger
+	minRefresh  time.Duration
+	current     atomic.Pointer[map[string]crypto.PublicKey]
+	lastRefresh time.Time
+	now         func() time.Time
+	mutex       sync.Mutex
+}
+
+// NewKeySet creates a key set whose background refreshes log failures to logger
+func NewKeySet(logger *slog.Logger, loader KeySetLoader, minRefresh time.Duration) *KeySet {
+	return &KeySet{
+		loader:     loader,
+		logger:     logger,
+		minRefresh: minRefresh,
+		now:        time.Now,
+	}
+}
+
+// Key returns the key with the given ID. An empty ID is accepted when the set holds a single key.
+func (s *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := lookupKey(s.current.Load(), kid); ok {
+		return key, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Another caller may have reloaded the keys while we waited for the lock
+	keys := s.current.Load()
+	if key, ok := lookupKey(keys, kid); ok {
+		return key, nil
+	}
+	// Failed loads count as refreshes too, so an unreachable provider is not retried on every request
+	if s.now().Sub(s.lastRefresh) >= s.minRefresh {
+		var err error
+		if keys, err = s.refreshLocked(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if keys == nil {
+		return nil, ErrKeysUnavailable
+	}
+	if key, ok := lookupKey(keys, kid); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func lookupKey(keys *map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if keys == nil {
+		return nil, false
+	}
+	if kid == "" && len(*keys) == 1 {
+		for _, key := range *keys {
+			return key, true
+		}
+	}
+	key, ok := (*keys)[kid]
+	return key, ok
+}
+
+// Refresh reloads the keys. On failure the previous keys are kept.
+func (s *KeySet) Refresh(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err := s.refreshLocked(ctx)
+	return err
+}
+
+// StartRefresh reloads the keys in the background every interval
+func (s *KeySet) StartRefresh(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := s.Refresh(context.Background()); err != nil {
+				s.logger.Error("error refreshing jwks", "error", err)
+			}
+		}
+	}()
+}
+
+func (s *KeySet) refreshLocked(ctx context.Context) (*map[string]crypto.PublicKey, error) {
+	s.lastRefresh = s.now()
+	keys, err := s.loader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.current.Store(&keys)
+	return &keys, nil
+}
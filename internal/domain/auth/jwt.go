@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Staff roles, granted by the identity provider in the roles claim of a token
+const (
+	RoleViewer   = "viewer"
+	RoleEditor   = "editor"
+	RoleApprover = "approver"
+)
+
+// Permissions on admin endpoints
+const (
+	PermissionView    = "view"
+	PermissionEdit    = "edit"
+	PermissionApprove = "approve"
+)
+
+// rolePermissions maps each role to its permissions; each role includes the ones before it
+var rolePermissions = map[string][]string{
+	RoleViewer:   {PermissionView},
+	RoleEditor:   {PermissionView, PermissionEdit},
+	RoleApprover: {PermissionView, PermissionEdit, PermissionApprove},
+}
+
+// PermissionsOf returns the permissions granted by roles. Unknown roles grant nothing.
+func PermissionsOf(roles []string) []string {
+	var permissions []string
+	for _, role := range roles {
+		for _, permission := range rolePermissions[role] {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions
+}
+
+// signingMethods are the algorithms accepted; HMAC and "none" are never accepted from an identity provider
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// ErrInvalidToken is returned for every token that fails verification
+var ErrInvalidToken = errors.New("invalid token")
+
+// User is a staff member authenticated by a token
+type User struct {
+	Subject     string
+	Email       string
+	Roles       []string
+	Permissions []string
+	// Tenants are the tenants the user may administer
+	Tenants []string
+}
+
+// Can reports whether the user holds permission
+func (u *User) Can(permission string) bool {
+	return slices.Contains(u.Permissions, permission)
+}
+
+// InTenant reports whether the user may administer tenantID
+func (u *User) InTenant(tenantID string) bool {
+	return slices.Contains(u.Tenants, tenantID)
+}
+
+// VerifierConfig configures token verification
+type VerifierConfig struct {
+	Issuer   string
+	Audience string
+	// RolesClaim names the claim holding the roles, with dots for nested claims ("realm_access.roles")
+	RolesClaim string
+	// TenantsClaim names the claim holding the tenants the user may administer, like RolesClaim
+	TenantsClaim string
+	// Leeway tolerates clock skew on exp, nbf and iat
+	Leeway time.Duration
+}
+
+// Verifier verifies tokens of an OIDC identity provider against its key set
+type Verifier struct {
+	keys   *KeySet
+	config VerifierConfig
+	parser *jwt.Parser
+}
+
+func NewVerifier(keys *KeySet, config VerifierConfig) *Verifier {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	return &Verifier{
+		keys:   keys,
+		config: config,
+		parser: jwt.NewParser(options...),
+	}
+}
+
+// Verify checks the signature, expiry, issuer and audience of a token and returns its user. The cause of
+// a failure is wrapped for logging; callers should answer every failure alike.
+func (v *Verifier) Verify(ctx context.Context, raw string) (*User, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, errors.Join(ErrInvalidToken, errors.New("missing sub claim"))
+	}
+	email, _ := claims["email"].(string)
+	roles := stringsClaim(claims, v.config.RolesClaim)
+
+	return &User{
+		Subject:     subject,
+		Email:       email,
+		Roles:       roles,
+		Permissions: PermissionsOf(roles),
+		Tenants:     stringsClaim(claims, v.config.TenantsClaim),
+	}, nil
+}
+
+// stringsClaim reads a claim holding a list of strings or a space-separated string, following dots into
+// nested objects
+func stringsClaim(claims jwt.MapClaims, path string) []string {
+	var value interface{} = map[string]interface{}(claims)
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC", "kid": kid, "crv": "P-256",
+		"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func jwksJSON(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	require.NoError(t, err)
+	return raw
+}
+
+func staffClaims(roles ...string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":     "https://sso.example.com",
+		"aud":     "campaign-admin",
+		"sub":     "user-1",
+		"email":   "ada@example.com",
+		"iat":     now.Unix(),
+		"exp":     now.Add(time.Hour).Unix(),
+		"roles":   roles,
+		"tenants": []string{"games"},
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksJSON(t, rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey)), 0o600))
+	verifier := NewVerifier(NewKeySet(slog.Default(), JWKSFromFile(path), time.Minute), VerifierConfig{
+		Issuer:       "https://sso.example.com",
+		Audience:     "campaign-admin",
+		RolesClaim:   "roles",
+		TenantsClaim: "tenants",
+	})
+	ctx := context.Background()
+
+	user, err := verifier.Verify(ctx, signToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", staffClaims(RoleEditor, "unrelated")))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", user.Subject)
+	assert.Equal(t, "ada@example.com", user.Email)
+	assert.True(t, user.Can(PermissionView))
+	assert.True(t, user.Can(PermissionEdit))
+	assert.False(t, user.Can(PermissionApprove))
+	assert.True(t, user.InTenant("games"))
+	assert.False(t, user.InTenant("news"))
+
+	user, err = verifier.Verify(ctx, signToken(t, jwt.SigningMethodES256, ecKey, "ec-1", staffClaims(RoleApprover)))
+	require.NoError(t, err)
+	assert.True(t, user.Can(PermissionApprove))
+
+	expired := staffClaims(RoleViewer)
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	wrongAudience := staffClaims(RoleViewer)
+	wrongAudience["aud"] = "other-app"
+	wrongIssuer := staffClaims(RoleViewer)
+	wrongIssuer["iss"] = "https://evil.example.com"
+	noExpiry := staffClaims(RoleViewer)
+	delete(noExpiry, "exp")
+
+	for name, raw := range map[string]string{
+		"expired":        signToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", expired),
+		"wrong audience": signToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", wrongAudience),
+		"wrong issuer":   signToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", wrongIssuer),
+		"no expiry":      signToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", noExpiry),
+		"wrong key":      signToken(t, jwt.SigningMethodRS256, otherKey, "rsa-1", staffClaims(RoleViewer)),
+		"unknown kid":    signToken(t, jwt.SigningMethodRS256, otherKey, "rsa-2", staffClaims(RoleViewer)),
+		"hmac":           signToken(t, jwt.SigningMethodHS256, []byte("secret"), "rsa-1", staffClaims(RoleViewer)),
+		"none":           signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", staffClaims(RoleViewer)),
+		"garbage":        "not.a.token",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := verifier.Verify(ctx, raw)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// The provider starts publishing the new key after the first fetch
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			w.Write(jwksJSON(t, rsaJWK("old", &oldKey.PublicKey)))
+			return
+		}
+		w.Write(jwksJSON(t, rsaJWK("old", &oldKey.PublicKey), rsaJWK("new", &newKey.PublicKey)))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	keys := NewKeySet(slog.Default(), JWKSFromURL(server.Client(), server.URL), time.Minute)
+	keys.now = func() time.Time { return now }
+	verifier := NewVerifier(keys, VerifierConfig{Issuer: "https://sso.example.com", Audience: "campaign-admin", RolesClaim: "roles"})
+	ctx := context.Background()
+
+	_, err = verifier.Verify(ctx, signToken(t, jwt.SigningMethodRS256, oldKey, "old", staffClaims(RoleViewer)))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, fetches.Load(), "keys are cached")
+
+	// Once the minimum refresh interval has passed, a token with the new key ID reloads the keys
+	now = now.Add(time.Minute)
+	_, err = verifier.Verify(ctx, signToken(t, jwt.SigningMethodRS256, newKey, "new", staffClaims(RoleViewer)))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, fetches.Load())
+
+	// Unknown key IDs do not reload again within the minimum refresh interval
+	_, err = verifier.Verify(ctx, signToken(t, jwt.SigningMethodRS256, newKey, "forged", staffClaims(RoleViewer)))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.EqualValues(t, 2, fetches.Load())
+}
+
+func TestKeySet_FailedLoadIsThrottled(t *testing.T) {
+	var loads int
+	keys := NewKeySet(slog.Default(), func(ctx context.Context) (map[string]crypto.PublicKey, error) {
+		loads++
+		return nil, errors.New("provider down")
+	}, time.Minute)
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Without keys, only the first request reaches the provider within the minimum refresh interval
+	_, err := keys.Key(ctx, "k1")
+	assert.EqualError(t, err, "provider down")
+	_, err = keys.Key(ctx, "k1")
+	assert.ErrorIs(t, err, ErrKeysUnavailable)
+	assert.Equal(t, 1, loads)
+
+	now = now.Add(time.Minute)
+	_, err = keys.Key(ctx, "k1")
+	assert.EqualError(t, err, "provider down")
+	assert.Equal(t, 2, loads)
+}
+
+func TestStringsClaim(t *testing.T) {
+	claims := jwt.MapClaims{
+		"scope":        "viewer editor",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"approver", 42}},
+	}
+	assert.Equal(t, []string{"viewer", "editor"}, stringsClaim(claims, "scope"))
+	assert.Equal(t, []string{"approver"}, stringsClaim(claims, "realm_access.roles"))
+	assert.Nil(t, stringsClaim(claims, "missing.roles"))
+}
+
+func TestPermissionsOf(t *testing.T) {
+	assert.Equal(t, []string{PermissionView}, PermissionsOf([]string{RoleViewer}))
+	assert.Equal(t, []string{PermissionView, PermissionEdit, PermissionApprove}, PermissionsOf([]string{RoleViewer, RoleApprover}))
+	assert.Empty(t, PermissionsOf([]string{"admin"}))
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"campaign/internal/domain/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scopes a client can be granted
+const (
+	ScopeDeliveryRead = "delivery:read"
+	ScopeAdminWrite   = "admin:write"
+	ScopeTrackWrite   = "track:write"
+)
+
+var validScopes = map[string]bool{
+	ScopeDeliveryRead: true,
+	ScopeAdminWrite:   true,
+	ScopeTrackWrite:   true,
+}
+
+// keyPrefix starts every API key, so that leaked keys are easy to recognize in logs and secret scanners
+const keyPrefix = "ck_"
+
+// Authentication failures, distinguished for metrics and logs but answered alike to the caller
+var (
+	ErrMissingKey     = errors.New("missing api key")
+	ErrInvalidKey     = errors.New("invalid api key")
+	ErrKeyNotValidYet = errors.New("api key not valid yet")
+	ErrKeyExpired     = errors.New("api key expired")
+	ErrClientDisabled = errors.New("api client disabled")
+)
+
+// ValidateScopes checks that every scope is known
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !validScopes[scope] {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// GenerateKey creates a new random key for a client. The raw key is returned to be handed out once;
+// only the returned APIKey, which holds its hash, is stored.
+func GenerateKey(clientID string, notBefore time.Time, expiresAt *time.Time) (string, models.APIKey, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", models.APIKey{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	keyID := hex.EncodeToString(id)
+	raw := keyPrefix + keyID + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return raw, models.APIKey{
+		KeyID:     keyID,
+		ClientID:  clientID,
+		KeyHash:   HashKey(raw),
+		NotBefore: notBefore,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// HashKey returns the stored form of a key. Keys are long random secrets, so a fast hash is enough.
+func HashKey(raw string) []byte {
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
+
+// parseKeyID returns the key ID embedded in a raw key
+func parseKeyID(raw string) (string, bool) {
+	rest, ok := strings.CutPrefix(raw, keyPrefix)
+	if !ok {
+		return "", false
+	}
+	keyID, secret, ok := strings.Cut(rest, "_")
+	return keyID, ok && keyID != "" && secret != ""
+}
+
+// Client is an authenticated API client
+type Client struct {
+	ID       string
+	TenantID string
+	Name     string
+	Scopes   []string
+	KeyID    string
+}
+
+// HasScope reports whether the client was granted scope
+func (c *Client) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// Keyring is an immutable in-memory index of API keys and their clients
+type Keyring struct {
+	keys    map[string]models.APIKey
+	clients map[string]models.APIClient
+}
+
+// NewKeyring indexes the keys of the given clients; keys of unknown clients are ignored
+func NewKeyring(clients []models.APIClient, keys []models.APIKey) *Keyring {
+	k := &Keyring{
+		keys:    make(map[string]models.APIKey, len(keys)),
+		clients: make(map[string]models.APIClient, len(clients)),
+	}
+	for _, client := range clients {
+		k.clients[client.ClientID] = client
+	}
+	for _, key := range keys {
+		if _, ok := k.clients[key.ClientID]; ok {
+			k.keys[key.KeyID] = key
+		}
+	}
+	return k
+}
+
+// Authenticate returns the client owning the raw key, if the key is valid at now and the client enabled
+func (k *Keyring) Authenticate(raw string, now time.Time) (*Client, error) {
+	if raw == "" {
+		return nil, ErrMissingKey
+	}
+
+	keyID, ok := parseKeyID(raw)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	key, ok := k.keys[keyID]
+	if !ok || subtle.ConstantTimeCompare(key.KeyHash, HashKey(raw)) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	if now.Before(key.NotBefore) {
+		return nil, ErrKeyNotValidYet
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, ErrKeyExpired
+	}
+
+	client := k.clients[key.ClientID]
+	if !client.Enabled {
+		return nil, ErrClientDisabled
+	}
+
+	return &Client{ID: client.ClientID, TenantID: client.TenantID, Name: client.Name, Scopes: client.Scopes, KeyID: key.KeyID}, nil
+}
+
+// FailureReason returns the metric label of an authentication error
+func FailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingKey):
+		return "missing_key"
+	case errors.Is(err, ErrKeyNotValidYet):
+		return "not_valid_yet"
+	case errors.Is(err, ErrKeyExpired):
+		return "expired"
+	case errors.Is(err, ErrClientDisabled):
+		return "disabled"
+	default:
+		return "invalid_key"
+	}
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"campaign/internal/domain/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring_Authenticate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	valid, validKey, err := GenerateKey("sdk", earlier, nil)
+	require.NoError(t, err)
+	expired, expiredKey, err := GenerateKey("sdk", earlier.Add(-time.Hour), &earlier)
+	require.NoError(t, err)
+	future, futureKey, err := GenerateKey("sdk", later, nil)
+	require.NoError(t, err)
+	disabled, disabledKey, err := GenerateKey("old", earlier, nil)
+	require.NoError(t, err)
+	orphan, orphanKey, err := GenerateKey("unknown", earlier, nil)
+	require.NoError(t, err)
+
+	keyring := NewKeyring(
+		[]models.APIClient{
+			{ClientID: "sdk", TenantID: "games", Name: "SDK", Scopes: []string{ScopeDeliveryRead}, Enabled: true},
+			{ClientID: "old", Name: "Old", Scopes: []string{ScopeDeliveryRead}, Enabled: false},
+		},
+		[]models.APIKey{validKey, expiredKey, futureKey, disabledKey, orphanKey},
+	)
+
+	client, err := keyring.Authenticate(valid, now)
+	require.NoError(t, err)
+	assert.Equal(t, "sdk", client.ID)
+	assert.Equal(t, "games", client.TenantID)
+	assert.Equal(t, validKey.KeyID, client.KeyID)
+	assert.True(t, client.HasScope(ScopeDeliveryRead))
+	assert.False(t, client.HasScope(ScopeAdminWrite))
+
+	// A key with the right ID but another secret
+	prefix, _, _ := strings.Cut(strings.TrimPrefix(valid, keyPrefix), "_")
+	forged := keyPrefix + prefix + "_forged"
+
+	for name, tc := range map[string]struct {
+		raw string
+		err error
+	}{
+		"missing":       {"", ErrMissingKey},
+		"malformed":     {"not-a-key", ErrInvalidKey},
+		"wrong secret":  {forged, ErrInvalidKey},
+		"unknown":       {keyPrefix + "0000000000000000_secret", ErrInvalidKey},
+		"unknown owner": {orphan, ErrInvalidKey},
+		"expired":       {expired, ErrKeyExpired},
+		"not yet valid": {future, ErrKeyNotValidYet},
+		"disabled":      {disabled, ErrClientDisabled},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := keyring.Authenticate(tc.raw, now)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestKeyring_RotationOverlap(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	oldRaw, oldKey, err := GenerateKey("sdk", start, nil)
+	require.NoError(t, err)
+
+	// Rotation issues a new key and makes the old one expire after the overlap
+	rotatedAt := start.Add(24 * time.Hour)
+	overlapEnd := rotatedAt.Add(time.Hour)
+	newRaw, newKey, err := GenerateKey("sdk", rotatedAt, nil)
+	require.NoError(t, err)
+	oldKey.ExpiresAt = &overlapEnd
+
+	keyring := NewKeyring(
+		[]models.APIClient{{ClientID: "sdk", Scopes: []string{ScopeDeliveryRead}, Enabled: true}},
+		[]models.APIKey{oldKey, newKey},
+	)
+
+	during := rotatedAt.Add(30 * time.Minute)
+	for _, raw := range []string{oldRaw, newRaw} {
+		client, err := keyring.Authenticate(raw, during)
+		require.NoError(t, err)
+		assert.Equal(t, "sdk", client.ID)
+	}
+
+	after := overlapEnd
+	_, err = keyring.Authenticate(oldRaw, after)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+	_, err = keyring.Authenticate(newRaw, after)
+	assert.NoError(t, err)
+}
+
+func TestValidateScopes(t *testing.T) {
+	assert.NoError(t, ValidateScopes([]string{ScopeDeliveryRead, ScopeAdminWrite, ScopeTrackWrite}))
+	assert.Error(t, ValidateScopes([]string{"delivery:write"}))
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/pkg/refresh"
+	"log/slog"
+)
+
+// Loader fetches every API client and key
+type Loader func() ([]models.APIClient, []models.APIKey, error)
+
+// Store holds the current Keyring and swaps in a fresh one on every refresh, so that keys are checked
+// without a database round-trip. Revoked keys and disabled clients stop working at the next refresh.
+type Store struct {
+	*refresh.Store[Keyring]
+}
+
+func NewStore(logger *slog.Logger, loader Loader) *Store {
+	return &Store{
+		Store: refresh.NewStore("api keys", logger, func() (*Keyring, error) {
+			clients, keys, err := loader()
+			if err != nil {
+				return nil, err
+			}
+			return NewKeyring(clients, keys), nil
+		}),
+	}
+}
+
+// Keyring returns the current keyring, loading it on first use
+func (s *Store) Keyring() (*Keyring, error) {
+	return s.Get()
+}
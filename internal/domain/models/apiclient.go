@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type APIClient struct {
+	ClientID string   `json:"client_id"`
+	TenantID string   `json:"tenant_id"`
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes"`
+	Enabled  bool     `json:"enabled"`
+}
+
+// APIKey is one key of a client. Only the hash of the key is kept.
+type APIKey struct {
+	KeyID     string
+	ClientID  string
+	KeyHash   []byte
+	NotBefore time.Time
+	ExpiresAt *time.Time // nil when the key never expires
+}
@@ -1,28 +1,103 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 type Campaign struct {
-	CampaignID     string
-	CampaignName   string
-	ImageURL       string
-	CallToAction   string
-	CampaignStatus string
-	CDate          string
-	UDate          string
+	CampaignID          string
+	CampaignName        string
+	ImageURL            string
+	CallToAction        string
+	CampaignStatus      string
+	TargetingExpression string     // JSON expression AST, empty when the campaign has none
+	StartTime           *time.Time // flight start (inclusive), nil when unbounded
+	EndTime             *time.Time // flight end (exclusive), nil when unbounded
+	CDate               string
+	UDate               string
 }
 
 type TargetingRule struct {
 	CampaignID string
 	Dimension  string
 	Type       string
+	Operator   string
 	Value      string
 	CDate      string
 	UDate      string
 }
 
+// DeliveryRequest is the JSON body of POST /delivery. app_id, country and os may be given either as
+// top-level fields or in dimensions; any other targeting dimension goes in dimensions.
 type DeliveryRequest struct {
-	AppID   string `json:"app_id"`
-	Country string `json:"country"`
-	OS      string `json:"os"`
+	AppID      string                     `json:"app_id"`
+	Country    string                     `json:"country"`
+	OS         string                     `json:"os"`
+	Dimensions map[string]DimensionValues `json:"dimensions"`
+	User       *DeliveryUser              `json:"user"`
+	Placements []Placement                `json:"placements"`
+}
+
+// DimensionValues accepts a single string or an array of strings
+type DimensionValues []string
+
+func (v *DimensionValues) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*v = DimensionValues{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("dimension values must be a string or an array of strings")
+	}
+	*v = multiple
+	return nil
+}
+
+// DeliveryUser is the user context of a delivery request. user_id is resolved to audience segments
+// and lat/lon to the location dimension.
+type DeliveryUser struct {
+	UserID string   `json:"user_id"`
+	Lat    *float64 `json:"lat"`
+	Lon    *float64 `json:"lon"`
+}
+
+// Placement is an ad slot to fill; limit defaults to the API page limit
+type Placement struct {
+	PlacementID string `json:"placement_id"`
+	Limit       int    `json:"limit"`
+}
+
+// DeliveryEnvelope is the /api/v2/delivery response. total is only present when requested; a cursor
+// paginated response has next_cursor instead of page.
+type DeliveryEnvelope struct {
+	Campaigns  []DeliveryResponse `json:"campaigns"`
+	Page       int                `json:"page,omitempty"`
+	Limit      int                `json:"limit"`
+	HasMore    bool               `json:"has_more"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+	Total      *int               `json:"total,omitempty"`
+	RequestID  string             `json:"request_id"`
+	ServedAt   time.Time          `json:"served_at"`
+}
+
+// DeliveryPage is a cursor-paginated delivery response; next_cursor is omitted on the last page
+type DeliveryPage struct {
+	Campaigns  []DeliveryResponse `json:"campaigns"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+type PlacementResponse struct {
+	PlacementID string             `json:"placement_id"`
+	Campaigns   []DeliveryResponse `json:"campaigns"`
+}
+
+type BatchDeliveryResponse struct {
+	Placements []PlacementResponse `json:"placements"`
 }
 
 type DeliveryResponse struct {
@@ -2,7 +2,7 @@ package models
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
@@ -11,38 +11,134 @@ import (
 )
 
 type AppConfig struct {
-	DBHOST    string
-	DBPORT    string
-	DBUSER    string
-	DBPass    string
-	DBName    string
-	AppPort   string
-	RedisAddr string
-	RedisPass string
-	RedisDB   int
-	CacheSize int
-	LogLevel  string
+	DBHOST      string
+	DBPORT      string
+	DBUSER      string
+	DBPass      string
+	DBName      string
+	AppPort     string
+	GRPCPort    string
+	MetricsPort string
+	RedisAddr   string
+	RedisPass   string
+	RedisDB     int
+	CacheSize   int
+	LogLevel    string
+
+	TargetingRefreshSeconds int
+	SegmentRefreshSeconds   int
+
+	GeoIPDBPath    string
+	TrustedProxies []string
+
+	UserAgentEnrichment bool
+
+	RequestSampleRate        float64
+	RequestSampleSize        int
+	RequestSampleMaxAgeHours int
+	RequestLogPath           string
+
+	TracingExporter    string
+	TracingFile        string
+	TracingSampleRatio float64
+
+	MetricsTopK            int
+	MetricsDimensionsLabel bool
+
+	ReadinessTimeoutSeconds int
+	ShutdownDelaySeconds    int
+
+	AuthEnabled        bool
+	AuthRefreshSeconds int
+
+	JWTJWKSURL            string
+	JWTJWKSFile           string
+	JWTIssuer             string
+	JWTAudience           string
+	JWTRolesClaim         string
+	JWTTenantsClaim       string
+	JWTJWKSRefreshSeconds int
+	JWTLeewaySeconds      int
+
+	RateLimitStore         string
+	RateLimitDeliveryRPS   float64
+	RateLimitDeliveryBurst int
+	RateLimitDeliveryKey   string
+	RateLimitAdminRPS      float64
+	RateLimitAdminBurst    int
+	RateLimitAdminKey      string
+
+	TenantFallback       string
+	TenantRefreshSeconds int
 }
 
 // LoadConfig loads the application configuration from environment variables or a config file.
 func LoadConfig() (*AppConfig, error) {
 	// Load .env file if it exists
 	if err := godotenv.Load(".env"); err != nil {
-		log.Printf("Warning: .env file not found, using environment variables")
+		slog.Warn(".env file not found, using environment variables")
 	}
 
 	cfg := &AppConfig{
-		DBHOST:    getEnv("DB_HOST", "localhost"),
-		DBPORT:    getEnv("DB_PORT", "5432"),
-		DBUSER:    getEnv("DB_USER", "postgres"),
-		DBPass:    getEnv("DB_PASS", "password"),
-		DBName:    getEnv("DB_NAME", "campaign_service"),
-		AppPort:   getEnv("APP_PORT", "8080"),
-		RedisAddr: getEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPass: getEnv("REDIS_PASS", ""),
-		RedisDB:   getEnvAsInt("REDIS_DB", 0),
-		CacheSize: getEnvAsInt("CACHE_SIZE", 1000),
-		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		DBHOST:      getEnv("DB_HOST", "localhost"),
+		DBPORT:      getEnv("DB_PORT", "5432"),
+		DBUSER:      getEnv("DB_USER", "postgres"),
+		DBPass:      getEnv("DB_PASS", "password"),
+		DBName:      getEnv("DB_NAME", "campaign_service"),
+		AppPort:     getEnv("APP_PORT", "8080"),
+		GRPCPort:    getEnv("GRPC_PORT", "50051"),
+		MetricsPort: getEnv("METRICS_PORT", "9090"),
+		RedisAddr:   getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPass:   getEnv("REDIS_PASS", ""),
+		RedisDB:     getEnvAsInt("REDIS_DB", 0),
+		CacheSize:   getEnvAsInt("CACHE_SIZE", 1000),
+		LogLevel:    getEnv("LOG_LEVEL", "info"),
+
+		TargetingRefreshSeconds: getEnvAsInt("TARGETING_REFRESH_SECONDS", 30),
+		SegmentRefreshSeconds:   getEnvAsInt("SEGMENT_REFRESH_SECONDS", 300),
+
+		GeoIPDBPath:    getEnv("GEOIP_DB_PATH", ""),
+		TrustedProxies: getEnvAsList("TRUSTED_PROXIES", nil),
+
+		UserAgentEnrichment: getEnvAsBool("USER_AGENT_ENRICHMENT", false),
+
+		RequestSampleRate:        getEnvAsFloat("REQUEST_SAMPLE_RATE", 0.01),
+		RequestSampleSize:        getEnvAsInt("REQUEST_SAMPLE_SIZE", 10000),
+		RequestSampleMaxAgeHours: getEnvAsInt("REQUEST_SAMPLE_MAX_AGE_HOURS", 168),
+		RequestLogPath:           getEnv("REQUEST_LOG_PATH", ""),
+
+		TracingExporter:    strings.ToLower(getEnv("TRACING_EXPORTER", "none")),
+		TracingFile:        getEnv("TRACING_FILE", "traces.jsonl"),
+		TracingSampleRatio: getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
+
+		MetricsTopK:            getEnvAsInt("METRICS_TOP_K", 100),
+		MetricsDimensionsLabel: getEnvAsBool("METRICS_DIMENSIONS_LABEL", false),
+
+		ReadinessTimeoutSeconds: getEnvAsInt("READINESS_TIMEOUT_SECONDS", 2),
+		ShutdownDelaySeconds:    getEnvAsInt("SHUTDOWN_DELAY_SECONDS", 5),
+
+		AuthEnabled:        getEnvAsBool("AUTH_ENABLED", false),
+		AuthRefreshSeconds: getEnvAsInt("AUTH_REFRESH_SECONDS", 30),
+
+		JWTJWKSURL:            getEnv("JWT_JWKS_URL", ""),
+		JWTJWKSFile:           getEnv("JWT_JWKS_FILE", ""),
+		JWTIssuer:             getEnv("JWT_ISSUER", ""),
+		JWTAudience:           getEnv("JWT_AUDIENCE", ""),
+		JWTRolesClaim:         getEnv("JWT_ROLES_CLAIM", "roles"),
+		JWTTenantsClaim:       getEnv("JWT_TENANTS_CLAIM", "tenants"),
+		JWTJWKSRefreshSeconds: getEnvAsInt("JWT_JWKS_REFRESH_SECONDS", 300),
+		JWTLeewaySeconds:      getEnvAsInt("JWT_LEEWAY_SECONDS", 30),
+
+		RateLimitStore:         strings.ToLower(getEnv("RATE_LIMIT_STORE", "none")),
+		RateLimitDeliveryRPS:   getEnvAsFloat("RATE_LIMIT_DELIVERY_RPS", 100),
+		RateLimitDeliveryBurst: getEnvAsInt("RATE_LIMIT_DELIVERY_BURST", 200),
+		RateLimitDeliveryKey:   strings.ToLower(getEnv("RATE_LIMIT_DELIVERY_KEY", "client")),
+		RateLimitAdminRPS:      getEnvAsFloat("RATE_LIMIT_ADMIN_RPS", 5),
+		RateLimitAdminBurst:    getEnvAsInt("RATE_LIMIT_ADMIN_BURST", 20),
+		RateLimitAdminKey:      strings.ToLower(getEnv("RATE_LIMIT_ADMIN_KEY", "client")),
+
+		TenantFallback:       getEnv("TENANT_FALLBACK", "default"),
+		TenantRefreshSeconds: getEnvAsInt("TENANT_REFRESH_SECONDS", 60),
 	}
 
 	// Validate configuration
@@ -53,6 +149,11 @@ func LoadConfig() (*AppConfig, error) {
 	return cfg, nil
 }
 
+// JWTEnabled reports whether staff bearer tokens are accepted on admin endpoints
+func (cfg *AppConfig) JWTEnabled() bool {
+	return cfg.JWTJWKSURL != "" || cfg.JWTJWKSFile != ""
+}
+
 func (cfg *AppConfig) validate() error {
 	if cfg.DBHOST == "" {
 		return fmt.Errorf("DB_HOST cannot be empty")
@@ -66,6 +167,9 @@ func (cfg *AppConfig) validate() error {
 	if cfg.AppPort == "" {
 		return fmt.Errorf("APP_PORT cannot be empty")
 	}
+	if cfg.GRPCPort == "" {
+		return fmt.Errorf("GRPC_PORT cannot be empty")
+	}
 
 	// Validate port numbers
 	if _, err := strconv.Atoi(cfg.DBPORT); err != nil {
@@ -74,12 +178,123 @@ func (cfg *AppConfig) validate() error {
 	if _, err := strconv.Atoi(cfg.AppPort); err != nil {
 		return fmt.Errorf("APP_PORT must be a valid integer: %s", cfg.AppPort)
 	}
+	if _, err := strconv.Atoi(cfg.GRPCPort); err != nil {
+		return fmt.Errorf("GRPC_PORT must be a valid integer: %s", cfg.GRPCPort)
+	}
+	if _, err := strconv.Atoi(cfg.MetricsPort); err != nil {
+		return fmt.Errorf("METRICS_PORT must be a valid integer: %s", cfg.MetricsPort)
+	}
 
 	// Validate cache size
 	if cfg.CacheSize <= 0 {
 		return fmt.Errorf("CACHE_SIZE must be greater than 0: %d", cfg.CacheSize)
 	}
 
+	// Validate targeting snapshot refresh interval
+	if cfg.TargetingRefreshSeconds <= 0 {
+		return fmt.Errorf("TARGETING_REFRESH_SECONDS must be greater than 0: %d", cfg.TargetingRefreshSeconds)
+	}
+	if cfg.SegmentRefreshSeconds <= 0 {
+		return fmt.Errorf("SEGMENT_REFRESH_SECONDS must be greater than 0: %d", cfg.SegmentRefreshSeconds)
+	}
+
+	// Validate reach estimate request sampling
+	if cfg.RequestSampleRate < 0 || cfg.RequestSampleRate > 1 {
+		return fmt.Errorf("REQUEST_SAMPLE_RATE must be between 0 and 1: %g", cfg.RequestSampleRate)
+	}
+	if cfg.RequestSampleSize <= 0 {
+		return fmt.Errorf("REQUEST_SAMPLE_SIZE must be greater than 0: %d", cfg.RequestSampleSize)
+	}
+	if cfg.RequestSampleMaxAgeHours <= 0 {
+		return fmt.Errorf("REQUEST_SAMPLE_MAX_AGE_HOURS must be greater than 0: %d", cfg.RequestSampleMaxAgeHours)
+	}
+
+	// Validate tracing
+	validExporters := map[string]bool{"none": true, "otlp": true, "stdout": true, "file": true}
+	if !validExporters[cfg.TracingExporter] {
+		return fmt.Errorf("TRACING_EXPORTER must be one of: none, otlp, stdout, file")
+	}
+	if cfg.TracingExporter == "file" && cfg.TracingFile == "" {
+		return fmt.Errorf("TRACING_FILE cannot be empty with TRACING_EXPORTER=file")
+	}
+	if cfg.TracingSampleRatio < 0 || cfg.TracingSampleRatio > 1 {
+		return fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1: %g", cfg.TracingSampleRatio)
+	}
+
+	// Validate metric label cardinality
+	if cfg.MetricsTopK <= 0 {
+		return fmt.Errorf("METRICS_TOP_K must be greater than 0: %d", cfg.MetricsTopK)
+	}
+
+	// Validate health checks and shutdown
+	if cfg.ReadinessTimeoutSeconds <= 0 {
+		return fmt.Errorf("READINESS_TIMEOUT_SECONDS must be greater than 0: %d", cfg.ReadinessTimeoutSeconds)
+	}
+	if cfg.ShutdownDelaySeconds < 0 {
+		return fmt.Errorf("SHUTDOWN_DELAY_SECONDS cannot be negative: %d", cfg.ShutdownDelaySeconds)
+	}
+
+	// Validate API key refresh interval
+	if cfg.AuthRefreshSeconds <= 0 {
+		return fmt.Errorf("AUTH_REFRESH_SECONDS must be greater than 0: %d", cfg.AuthRefreshSeconds)
+	}
+
+	// Validate staff token verification, enabled by a JWKS source
+	if cfg.JWTJWKSURL != "" && cfg.JWTJWKSFile != "" {
+		return fmt.Errorf("only one of JWT_JWKS_URL and JWT_JWKS_FILE can be set")
+	}
+	if cfg.JWTEnabled() {
+		if cfg.JWTIssuer == "" || cfg.JWTAudience == "" {
+			return fmt.Errorf("JWT_ISSUER and JWT_AUDIENCE are required with a JWKS")
+		}
+		if cfg.JWTRolesClaim == "" {
+			return fmt.Errorf("JWT_ROLES_CLAIM cannot be empty")
+		}
+		if cfg.JWTTenantsClaim == "" {
+			return fmt.Errorf("JWT_TENANTS_CLAIM cannot be empty")
+		}
+	}
+	if cfg.JWTJWKSRefreshSeconds <= 0 {
+		return fmt.Errorf("JWT_JWKS_REFRESH_SECONDS must be greater than 0: %d", cfg.JWTJWKSRefreshSeconds)
+	}
+	if cfg.JWTLeewaySeconds < 0 {
+		return fmt.Errorf("JWT_LEEWAY_SECONDS cannot be negative: %d", cfg.JWTLeewaySeconds)
+	}
+
+	// Validate rate limits
+	validStores := map[string]bool{"none": true, "memory": true, "redis": true}
+	if !validStores[cfg.RateLimitStore] {
+		return fmt.Errorf("RATE_LIMIT_STORE must be one of: none, memory, redis")
+	}
+	validKeys := map[string]bool{"client": true, "app_id": true, "ip": true}
+	for _, limit := range []struct {
+		group string
+		rps   float64
+		burst int
+		key   string
+	}{
+		{"DELIVERY", cfg.RateLimitDeliveryRPS, cfg.RateLimitDeliveryBurst, cfg.RateLimitDeliveryKey},
+		{"ADMIN", cfg.RateLimitAdminRPS, cfg.RateLimitAdminBurst, cfg.RateLimitAdminKey},
+	} {
+		if limit.rps <= 0 {
+			return fmt.Errorf("RATE_LIMIT_%s_RPS must be greater than 0: %g", limit.group, limit.rps)
+		}
+		if limit.burst < 1 {
+			return fmt.Errorf("RATE_LIMIT_%s_BURST must be at least 1: %d", limit.group, limit.burst)
+		}
+		if !validKeys[limit.key] {
+			return fmt.Errorf("RATE_LIMIT_%s_KEY must be one of: client, app_id, ip", limit.group)
+		}
+	}
+
+	// Validate tenant resolution; an empty fallback rejects requests that resolve to no tenant
+	if cfg.TenantFallback != "" && !tenantIDPattern.MatchString(cfg.TenantFallback) {
+		return fmt.Errorf("TENANT_FALLBACK must be lower-case letters, digits, '_' or '-': %q", cfg.TenantFallback)
+	}
+	if cfg.TenantRefreshSeconds <= 0 {
+		return fmt.Errorf("TENANT_REFRESH_SECONDS must be greater than 0: %d", cfg.TenantRefreshSeconds)
+	}
+
 	// Validate log level
 	validLogLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
 	if !validLogLevels[strings.ToLower(cfg.LogLevel)] {
@@ -94,14 +309,44 @@ func getEnv(key, defaultValue string) string {
 		return strings.TrimSpace(value)
 	}
 
-	log.Printf("Environment variable %s not set, using default: %s", key, defaultValue)
+	slog.Debug("environment variable not set, using default", "key", key, "default", defaultValue)
+	return defaultValue
+}
+
+// getEnvAsList reads a comma-separated list, dropping empty entries
+func getEnvAsList(key string, defaultValue []string) []string {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, part := range strings.Split(valueStr, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+
+	slog.Warn("environment variable is not a valid boolean, using default", "key", key, "value", valueStr, "default", defaultValue)
 	return defaultValue
 }
 
 func getEnvAsInt(key string, defaultValue int) int {
 	valueStr := getEnv(key, "")
 	if valueStr == "" {
-		log.Printf("Environment variable %s not set, using default: %d", key, defaultValue)
+		slog.Debug("environment variable not set, using default", "key", key, "default", defaultValue)
 		return defaultValue
 	}
 
@@ -109,6 +354,20 @@ func getEnvAsInt(key string, defaultValue int) int {
 		return value
 	}
 
-	log.Printf("Warning: Environment variable %s (value: %s) is not a valid integer, using default: %d", key, valueStr, defaultValue)
+	slog.Warn("environment variable is not a valid integer, using default", "key", key, "value", valueStr, "default", defaultValue)
+	return defaultValue
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+
+	slog.Warn("environment variable is not a valid number, using default", "key", key, "value", valueStr, "default", defaultValue)
 	return defaultValue
 }
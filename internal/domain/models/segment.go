@@ -0,0 +1,10 @@
+package models
+
+type Segment struct {
+	SegmentID   string `json:"segment_id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MemberCount int64  `json:"member_count"`
+	CDate       string `json:"cdate,omitempty"`
+	UDate       string `json:"udate,omitempty"`
+}
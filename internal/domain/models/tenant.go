@@ -0,0 +1,14 @@
+package models
+
+import "regexp"
+
+// tenantIDPattern matches the tenant IDs accepted by the tenants table
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Tenant is a business unit whose campaigns, segments and API clients are isolated from other tenants.
+// Hosts are the request hosts served for the tenant when the caller has no API key.
+type Tenant struct {
+	TenantID string   `json:"tenant_id"`
+	Name     string   `json:"name"`
+	Hosts    []string `json:"hosts"`
+}
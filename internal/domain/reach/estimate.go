@@ -0,0 +1,195 @@
+package reach
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/targeting"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxBreakdownValues caps the values listed per dimension; the rest are folded into OtherValue
+	maxBreakdownValues = 50
+
+	// NoValue is the breakdown entry for requests that did not supply the dimension
+	NoValue = "(none)"
+	// OtherValue aggregates the values beyond the most frequent maxBreakdownValues
+	OtherValue = "(other)"
+
+	proposedCampaignID = "proposed"
+)
+
+// ValueReach is the reach of a rule set among the sampled requests carrying one dimension value
+type ValueReach struct {
+	Value    string  `json:"value"`
+	Requests int     `json:"requests"`
+	Matched  int     `json:"matched"`
+	Share    float64 `json:"share"`
+}
+
+// Estimate is the share of sampled delivery requests that a proposed rule set would qualify for
+type Estimate struct {
+	SampledRequests     int                     `json:"sampled_requests"`
+	MatchedRequests     int                     `json:"matched_requests"`
+	Share               float64                 `json:"share"`
+	SampledCombinations int                     `json:"sampled_combinations"`
+	MatchedCombinations int                     `json:"matched_combinations"`
+	WindowStart         time.Time               `json:"window_start"`
+	WindowEnd           time.Time               `json:"window_end"`
+	Breakdown           map[string][]ValueReach `json:"breakdown"`
+}
+
+// EstimateReach evaluates validated rules and an optional validated expression against the samples with
+// the same semantics as delivery, as if they belonged to one active campaign. The breakdown covers every
+// dimension the rules or expression refer to, plus the extra breakdown dimensions.
+func EstimateReach(samples []Sample, rules []models.TargetingRule, expression *targeting.Expression, breakdown []string) (*Estimate, error) {
+	campaign := models.Campaign{CampaignID: proposedCampaignID}
+	if expression != nil {
+		data, err := json.Marshal(expression)
+		if err != nil {
+			return nil, err
+		}
+		campaign.TargetingExpression = string(data)
+	}
+
+	dimensions := make(map[string]bool)
+	for i := range rules {
+		rules[i].CampaignID = proposedCampaignID
+		dimensions[rules[i].Dimension] = true
+	}
+	if expression != nil {
+		expressionDimensions(expression, dimensions)
+	}
+	for _, dimension := range breakdown {
+		dimensions[dimension] = true
+	}
+
+	snapshot := targeting.NewSnapshot([]models.Campaign{campaign}, rules)
+	if snapshot.Len() != 1 {
+		return nil, fmt.Errorf("proposed targeting does not compile")
+	}
+
+	estimate := &Estimate{Breakdown: make(map[string][]ValueReach, len(dimensions))}
+	counts := make(map[string]map[string]*ValueReach, len(dimensions))
+	for dimension := range dimensions {
+		counts[dimension] = make(map[string]*ValueReach)
+	}
+	combinations := make(map[string]bool)
+
+	for _, sample := range samples {
+		matched := len(snapshot.Match(sample.Dimensions)) > 0
+
+		estimate.SampledRequests++
+		if matched {
+			estimate.MatchedRequests++
+		}
+		if estimate.WindowStart.IsZero() || sample.Time.Before(estimate.WindowStart) {
+			estimate.WindowStart = sample.Time
+		}
+		if sample.Time.After(estimate.WindowEnd) {
+			estimate.WindowEnd = sample.Time
+		}
+
+		// Equal combinations always match alike
+		combinations[combinationKey(sample.Dimensions)] = matched
+
+		for dimension, byValue := range counts {
+			values := sample.Dimensions[dimension]
+			if len(values) == 0 {
+				values = []string{NoValue}
+			}
+			for _, value := range values {
+				entry, ok := byValue[value]
+				if !ok {
+					entry = &ValueReach{Value: value}
+					byValue[value] = entry
+				}
+				entry.Requests++
+				if matched {
+					entry.Matched++
+				}
+			}
+		}
+	}
+
+	estimate.Share = share(estimate.MatchedRequests, estimate.SampledRequests)
+	estimate.SampledCombinations = len(combinations)
+	for _, matched := range combinations {
+		if matched {
+			estimate.MatchedCombinations++
+		}
+	}
+	for dimension, byValue := range counts {
+		estimate.Breakdown[dimension] = rankValues(byValue)
+	}
+
+	return estimate, nil
+}
+
+// rankValues orders the values by request count, folding the tail into OtherValue
+func rankValues(byValue map[string]*ValueReach) []ValueReach {
+	values := make([]ValueReach, 0, len(byValue))
+	for _, entry := range byValue {
+		values = append(values, *entry)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Requests != values[j].Requests {
+			return values[i].Requests > values[j].Requests
+		}
+		return values[i].Value < values[j].Value
+	})
+
+	if len(values) > maxBreakdownValues {
+		other := ValueReach{Value: OtherValue}
+		for _, entry := range values[maxBreakdownValues:] {
+			other.Requests += entry.Requests
+			other.Matched += entry.Matched
+		}
+		values = append(values[:maxBreakdownValues], other)
+	}
+
+	for i := range values {
+		values[i].Share = share(values[i].Matched, values[i].Requests)
+	}
+	return values
+}
+
+// combinationKey renders the sample's dimensions in a canonical order, so that equal traffic
+// combinations collapse to one key
+func combinationKey(dimensions map[string][]string) string {
+	names := make([]string, 0, len(dimensions))
+	for name := range dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		values := append([]string(nil), dimensions[name]...)
+		sort.Strings(values)
+		fmt.Fprintf(&b, "%s=%s&", name, strings.Join(values, "|"))
+	}
+	return b.String()
+}
+
+func expressionDimensions(e *targeting.Expression, dimensions map[string]bool) {
+	for _, child := range append(append([]*targeting.Expression(nil), e.And...), e.Or...) {
+		expressionDimensions(child, dimensions)
+	}
+	if e.Not != nil {
+		expressionDimensions(e.Not, dimensions)
+	}
+	if e.Dimension != "" {
+		dimensions[e.Dimension] = true
+	}
+}
+
+func share(matched, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(matched) / float64(total)
+}
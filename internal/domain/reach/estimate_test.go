@@ -0,0 +1,162 @@
+package reach
+
+import (
+	"bytes"
+	"campaign/internal/domain/models"
+	"campaign/internal/domain/targeting"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRecord(t *testing.T) {
+	l := NewLog(2, 1, 0)
+	params := map[string][]string{"country": {"US"}}
+	l.Record("games", params)
+	params["country"][0] = "CA"
+	l.Record("games", map[string][]string{"country": {"DE"}})
+	l.Record("news", map[string][]string{"country": {"FR"}})
+
+	samples := l.Samples()
+	require.Len(t, samples, 2)
+	assert.Equal(t, []string{"DE"}, samples[0].Dimensions["country"])
+	assert.Equal(t, []string{"FR"}, samples[1].Dimensions["country"])
+
+	// Each tenant only sees its own traffic
+	require.Len(t, l.SamplesOf("news"), 1)
+	assert.Equal(t, []string{"FR"}, l.SamplesOf("news")[0].Dimensions["country"])
+	assert.Empty(t, l.SamplesOf("shop"))
+
+	assert.Empty(t, NewLog(2, 0, 0).Samples())
+}
+
+func TestLogPersistAndLoad(t *testing.T) {
+	buf := &lockedBuffer{}
+	source := NewLog(10, 1, 0)
+	source.PersistTo(buf)
+	source.Record("games", map[string][]string{"country": {"US"}})
+	source.Record("games", map[string][]string{"country": {"CA"}})
+	source.Close()
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"), "Close waits for queued samples")
+	source.Record("games", map[string][]string{"country": {"DE"}})
+
+	restored := NewLog(1, 1, 0)
+	loaded, err := restored.Load(strings.NewReader(buf.String()))
+	require.NoError(t, err)
+	assert.Equal(t, 2, loaded)
+	require.Len(t, restored.Samples(), 1)
+	assert.Equal(t, []string{"CA"}, restored.Samples()[0].Dimensions["country"])
+	assert.Equal(t, "games", restored.Samples()[0].TenantID)
+
+	// Samples logged before tenants existed belong to the default tenant
+	_, err = restored.Load(strings.NewReader(`{"ts":"2025-01-01T00:00:00Z","dimensions":{"country":["US"]}}` + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "default", restored.Samples()[0].TenantID)
+
+	_, err = restored.Load(strings.NewReader("not json\n"))
+	assert.Error(t, err)
+}
+
+func TestLogRetention(t *testing.T) {
+	l := NewLog(10, 1, time.Hour)
+
+	// Samples older than the window are dropped on load
+	old := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	recent := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	loaded, err := l.Load(strings.NewReader(
+		`{"ts":"` + old + `","tenant_id":"games","dimensions":{"country":["US"]}}` + "\n" +
+			`{"ts":"` + recent + `","tenant_id":"games","dimensions":{"location":["40.7128,-74.0060"]}}` + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, loaded)
+
+	// Locations are kept to about 10 km, whether loaded or recorded
+	l.Record("games", map[string][]string{"location": {"51.51,-0.13", "invalid"}})
+	samples := l.Samples()
+	require.Len(t, samples, 2)
+	assert.Equal(t, []string{"40.7,-74.0"}, samples[0].Dimensions["location"])
+	assert.Equal(t, []string{"51.5,-0.1"}, samples[1].Dimensions["location"])
+}
+
+func TestEstimateReach(t *testing.T) {
+	samples := []Sample{
+		{Dimensions: map[string][]string{"country": {"US"}, "os": {"android"}}},
+		{Dimensions: map[string][]string{"country": {"US"}, "os": {"android"}}},
+		{Dimensions: map[string][]string{"country": {"US"}, "os": {"ios"}}},
+		{Dimensions: map[string][]string{"country": {"CA"}, "os": {"android"}}},
+		{Dimensions: map[string][]string{"os": {"android"}}},
+	}
+	rules := []models.TargetingRule{
+		{Dimension: "country", Type: "include", Operator: "eq", Value: "US"},
+		{Dimension: "os", Type: "exclude", Operator: "eq", Value: "ios"},
+	}
+
+	estimate, err := EstimateReach(samples, rules, nil, []string{"app_id"})
+	require.NoError(t, err)
+
+	// Requests without a country are not evaluated on it, as in delivery
+	assert.Equal(t, 5, estimate.SampledRequests)
+	assert.Equal(t, 3, estimate.MatchedRequests)
+	assert.InDelta(t, 0.6, estimate.Share, 1e-9)
+	assert.Equal(t, 4, estimate.SampledCombinations)
+	assert.Equal(t, 2, estimate.MatchedCombinations)
+
+	assert.Equal(t, []ValueReach{
+		{Value: "US", Requests: 3, Matched: 2, Share: 2.0 / 3},
+		{Value: "(none)", Requests: 1, Matched: 1, Share: 1},
+		{Value: "CA", Requests: 1, Matched: 0, Share: 0},
+	}, estimate.Breakdown["country"])
+	assert.Equal(t, []ValueReach{{Value: "(none)", Requests: 5, Matched: 3, Share: 0.6}}, estimate.Breakdown["app_id"])
+	assert.Len(t, estimate.Breakdown, 3)
+}
+
+func TestEstimateReachExpression(t *testing.T) {
+	samples := []Sample{
+		{Dimensions: map[string][]string{"country": {"US"}, "device_type": {"tablet"}}},
+		{Dimensions: map[string][]string{"country": {"US"}}},
+	}
+	expression, err := targeting.ParseExpression([]byte(`{"dimension": "device_type", "value": "tablet"}`))
+	require.NoError(t, err)
+
+	estimate, err := EstimateReach(samples, nil, expression, nil)
+	require.NoError(t, err)
+
+	// Unlike rules, a missing dimension fails an expression condition
+	assert.Equal(t, 1, estimate.MatchedRequests)
+	assert.Contains(t, estimate.Breakdown, "device_type")
+}
+
+func TestRankValuesFoldsTail(t *testing.T) {
+	byValue := make(map[string]*ValueReach)
+	for i := 0; i < maxBreakdownValues+5; i++ {
+		value := string(rune('a'+i/26)) + string(rune('a'+i%26))
+		byValue[value] = &ValueReach{Value: value, Requests: 1, Matched: i % 2}
+	}
+
+	values := rankValues(byValue)
+	require.Len(t, values, maxBreakdownValues+1)
+	other := values[maxBreakdownValues]
+	assert.Equal(t, OtherValue, other.Value)
+	assert.Equal(t, 5, other.Requests)
+}
+
+// lockedBuffer lets the test read what the persisting goroutine writes
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,213 @@
+package reach
+
+import (
+	"bufio"
+	"campaign/internal/domain/targeting"
+	"campaign/internal/domain/tenant"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// persistQueueSize bounds the samples waiting to be appended to the log file; beyond it samples are
+// only kept in memory, so a slow disk never delays delivery
+const persistQueueSize = 1024
+
+// Sample is one delivery request as targeting saw it: normalized, enriched, with user_id replaced by segments
+type Sample struct {
+	Time       time.Time           `json:"ts"`
+	TenantID   string              `json:"tenant_id"`
+	Dimensions map[string][]string `json:"dimensions"`
+}
+
+// locationDimension is coarsened before a sample is kept, so that the log never pinpoints a user
+const locationDimension = "location"
+
+// Log keeps a uniformly sampled, bounded window of recent delivery requests. Samples can also be appended
+// to a JSONL file, one Sample per line, so that the window survives restarts.
+type Log struct {
+	rate      float64
+	maxAge    time.Duration
+	samples   []Sample // ring buffer, oldest at next once full
+	next      int
+	full      bool
+	mutex     sync.RWMutex
+	persist   chan Sample
+	persisted chan struct{}
+}
+
+// NewLog creates a log holding up to capacity samples no older than maxAge, recording each request with
+// probability rate. A maxAge of 0 keeps samples until they are overwritten.
+func NewLog(capacity int, rate float64, maxAge time.Duration) *Log {
+	return &Log{
+		rate:    rate,
+		maxAge:  maxAge,
+		samples: make([]Sample, capacity),
+	}
+}
+
+// Record samples the request dimensions of a tenant. The map is copied, so callers may keep modifying it.
+func (l *Log) Record(tenantID string, dimensions map[string][]string) {
+	if l.rate <= 0 || (l.rate < 1 && rand.Float64() >= l.rate) {
+		return
+	}
+
+	sample := Sample{Time: time.Now().UTC(), TenantID: tenantID, Dimensions: make(map[string][]string, len(dimensions))}
+	for dimension, values := range dimensions {
+		sample.Dimensions[dimension] = append([]string(nil), values...)
+	}
+	coarsen(sample)
+
+	l.add(sample)
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	if l.persist != nil {
+		select {
+		case l.persist <- sample:
+		default:
+		}
+	}
+}
+
+// Samples returns the retained samples within maxAge, oldest first
+func (l *Log) Samples() []Sample {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	var samples []Sample
+	if !l.full {
+		samples = append(samples, l.samples[:l.next]...)
+	} else {
+		samples = make([]Sample, 0, len(l.samples))
+		samples = append(samples, l.samples[l.next:]...)
+		samples = append(samples, l.samples[:l.next]...)
+	}
+
+	// Samples are in time order, so the expired ones are a prefix
+	for i, sample := range samples {
+		if !l.expired(sample) {
+			return samples[i:]
+		}
+	}
+	return nil
+}
+
+// SamplesOf returns the retained samples of one tenant, oldest first
+func (l *Log) SamplesOf(tenantID string) []Sample {
+	var samples []Sample
+	for _, sample := range l.Samples() {
+		if sample.TenantID == tenantID {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// Load reads JSONL samples, e.g. a previous run's log file, keeping the most recent ones that fit and are
+// no older than maxAge
+func (l *Log) Load(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	loaded := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var sample Sample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			return loaded, fmt.Errorf("line %d: %v", line, err)
+		}
+		if l.expired(sample) {
+			continue
+		}
+		// Logs written before tenants existed hold the default tenant's traffic
+		if sample.TenantID == "" {
+			sample.TenantID = tenant.DefaultID
+		}
+		coarsen(sample)
+		l.add(sample)
+		loaded++
+	}
+
+	return loaded, scanner.Err()
+}
+
+// PersistTo appends every sample recorded from now on to w in the background, until Close. Samples that
+// arrive faster than w accepts them are kept in memory only.
+func (l *Log) PersistTo(w io.Writer) {
+	persist := make(chan Sample, persistQueueSize)
+	persisted := make(chan struct{})
+
+	l.mutex.Lock()
+	l.persist, l.persisted = persist, persisted
+	l.mutex.Unlock()
+
+	go func() {
+		defer close(persisted)
+		encoder := json.NewEncoder(w)
+		for sample := range persist {
+			if err := encoder.Encode(sample); err != nil {
+				slog.Error("error appending to request log", "error", err)
+			}
+		}
+	}()
+}
+
+// Close stops persisting and returns once the queued samples are written, so that the writer can be closed
+func (l *Log) Close() {
+	l.mutex.Lock()
+	persist, persisted := l.persist, l.persisted
+	l.persist = nil
+	l.mutex.Unlock()
+
+	if persist != nil {
+		close(persist)
+		<-persisted
+	}
+}
+
+func (l *Log) expired(sample Sample) bool {
+	return l.maxAge > 0 && time.Since(sample.Time) > l.maxAge
+}
+
+// coarsen rounds the sample's locations, which reach estimates only need to within about 10 km
+func coarsen(sample Sample) {
+	locations, ok := sample.Dimensions[locationDimension]
+	if !ok {
+		return
+	}
+	coarse := make([]string, 0, len(locations))
+	for _, location := range locations {
+		if value := targeting.CoarsenLocation(location); value != "" {
+			coarse = append(coarse, value)
+		}
+	}
+	if len(coarse) == 0 {
+		delete(sample.Dimensions, locationDimension)
+		return
+	}
+	sample.Dimensions[locationDimension] = coarse
+}
+
+func (l *Log) add(sample Sample) {
+	if len(l.samples) == 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.samples[l.next] = sample
+	l.next++
+	if l.next == len(l.samples) {
+		l.next = 0
+		l.full = true
+	}
+}
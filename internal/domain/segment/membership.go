@@ -0,0 +1,78 @@
+package segment
+
+import (
+	"sort"
+	"time"
+)
+
+// Membership is an immutable user -> segments index. Segments are stored as indexes into an interned
+// table, so each membership costs a few bytes on top of the user ID. User IDs are kept in full rather than
+// hashed, since a hash collision would silently hand one user another user's segments.
+type Membership struct {
+	segments []string
+	members  map[string][]uint32
+	LoadedAt time.Time
+}
+
+// Builder accumulates memberships for a new Membership
+type Builder struct {
+	index map[string]uint32
+	m     *Membership
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		index: make(map[string]uint32),
+		m: &Membership{
+			members: make(map[string][]uint32),
+		},
+	}
+}
+
+// Add records that userID belongs to segmentID. Duplicate pairs are ignored.
+func (b *Builder) Add(segmentID, userID string) {
+	idx, ok := b.index[segmentID]
+	if !ok {
+		idx = uint32(len(b.m.segments))
+		b.index[segmentID] = idx
+		b.m.segments = append(b.m.segments, segmentID)
+	}
+
+	for _, existing := range b.m.members[userID] {
+		if existing == idx {
+			return
+		}
+	}
+	b.m.members[userID] = append(b.m.members[userID], idx)
+}
+
+// Build returns the finished Membership; the builder must not be used afterwards
+func (b *Builder) Build() *Membership {
+	b.m.LoadedAt = time.Now()
+	return b.m
+}
+
+// Lookup returns the sorted segment IDs userID belongs to
+func (m *Membership) Lookup(userID string) []string {
+	indexes := m.members[userID]
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	segments := make([]string, 0, len(indexes))
+	for _, idx := range indexes {
+		segments = append(segments, m.segments[idx])
+	}
+	sort.Strings(segments)
+	return segments
+}
+
+// Users returns the number of distinct users with at least one segment
+func (m *Membership) Users() int {
+	return len(m.members)
+}
+
+// Segments returns the number of segments with at least one member
+func (m *Membership) Segments() int {
+	return len(m.segments)
+}
@@ -0,0 +1,22 @@
+package segment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembershipLookup(t *testing.T) {
+	builder := NewBuilder()
+	builder.Add("high_value", "user-1")
+	builder.Add("churned", "user-1")
+	builder.Add("high_value", "user-1")
+	builder.Add("high_value", "user-2")
+	m := builder.Build()
+
+	assert.Equal(t, []string{"churned", "high_value"}, m.Lookup("user-1"))
+	assert.Equal(t, []string{"high_value"}, m.Lookup("user-2"))
+	assert.Nil(t, m.Lookup("user-3"))
+	assert.Equal(t, 2, m.Users())
+	assert.Equal(t, 2, m.Segments())
+}
@@ -0,0 +1,34 @@
+package segment
+
+import (
+	"campaign/pkg/refresh"
+	"log/slog"
+)
+
+// Loader streams every (segment, user) membership pair into add
+type Loader func(add func(segmentID, userID string)) error
+
+// Store holds the current Membership and swaps in a fresh one on every refresh
+type Store struct {
+	*refresh.Store[Membership]
+}
+
+func NewStore(logger *slog.Logger, loader Loader) *Store {
+	return &Store{
+		Store: refresh.NewStore("segment membership", logger, func() (*Membership, error) {
+			builder := NewBuilder()
+			if err := loader(builder.Add); err != nil {
+				return nil, err
+			}
+
+			m := builder.Build()
+			logger.Info("segment membership loaded", "users", m.Users(), "segments", m.Segments())
+			return m, nil
+		}),
+	}
+}
+
+// Membership returns the current membership index, loading it on first use
+func (s *Store) Membership() (*Membership, error) {
+	return s.Get()
+}
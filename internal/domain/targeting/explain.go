@@ -0,0 +1,182 @@
+package targeting
+
+import (
+	"campaign/internal/domain/models"
+	"sort"
+	"time"
+)
+
+// Verdict is the outcome of one step of a delivery decision
+type Verdict string
+
+const (
+	// Campaign verdicts; VerdictExcluded and VerdictIncludeMissed are also used for whole campaigns
+	VerdictDelivered        Verdict = "delivered"
+	VerdictInactive         Verdict = "inactive"
+	VerdictNotLoaded        Verdict = "not_loaded"
+	VerdictOutOfFlight      Verdict = "out_of_flight"
+	VerdictInvalidTargeting Verdict = "invalid_targeting"
+	VerdictExpressionMissed Verdict = "expression_missed"
+
+	// Dimension verdicts
+	VerdictNoRules        Verdict = "no_rules"
+	VerdictNotEvaluated   Verdict = "not_evaluated"
+	VerdictIncludeMatched Verdict = "include_matched"
+	VerdictIncludeMissed  Verdict = "include_missed"
+	VerdictNotExcluded    Verdict = "not_excluded"
+	VerdictExcluded       Verdict = "excluded"
+)
+
+// RuleRef identifies the targeting rule behind a dimension verdict
+type RuleRef struct {
+	Type     string `json:"type"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// DimensionExplanation is the verdict of one dimension for one campaign
+type DimensionExplanation struct {
+	Dimension    string   `json:"dimension"`
+	Verdict      Verdict  `json:"verdict"`
+	Values       []string `json:"values,omitempty"`
+	Rule         *RuleRef `json:"rule,omitempty"`
+	MatchedValue string   `json:"matched_value,omitempty"`
+}
+
+// Explanation tells why a campaign was or was not delivered for a request
+type Explanation struct {
+	CampaignID        string                 `json:"campaign_id"`
+	Delivered         bool                   `json:"delivered"`
+	Verdict           Verdict                `json:"verdict"`
+	Detail            string                 `json:"detail,omitempty"`
+	Dimensions        []DimensionExplanation `json:"dimensions,omitempty"`
+	ExpressionMatched *bool                  `json:"expression_matched,omitempty"`
+}
+
+// Explain evaluates every campaign in the snapshot against the request, using the same rules as Match.
+// Campaigns left out of the snapshot because of invalid targeting are included.
+func (s *Snapshot) Explain(request map[string][]string, now time.Time) []Explanation {
+	explanations := make([]Explanation, 0, len(s.campaigns)+len(s.invalid))
+	for _, ct := range s.campaigns {
+		explanations = append(explanations, ct.explain(request, now))
+	}
+	for campaignID, reason := range s.invalid {
+		explanations = append(explanations, invalidExplanation(campaignID, reason))
+	}
+
+	sort.Slice(explanations, func(i, j int) bool {
+		return explanations[i].CampaignID < explanations[j].CampaignID
+	})
+	return explanations
+}
+
+// ExplainCampaign explains a single campaign. It returns false when the snapshot does not know the
+// campaign, which means it is inactive or does not exist.
+func (s *Snapshot) ExplainCampaign(campaignID string, request map[string][]string, now time.Time) (Explanation, bool) {
+	i := sort.Search(len(s.campaigns), func(i int) bool {
+		return s.campaigns[i].campaign.CampaignID >= campaignID
+	})
+	if i < len(s.campaigns) && s.campaigns[i].campaign.CampaignID == campaignID {
+		return s.campaigns[i].explain(request, now), true
+	}
+
+	if reason, ok := s.invalid[campaignID]; ok {
+		return invalidExplanation(campaignID, reason), true
+	}
+	return Explanation{}, false
+}
+
+func invalidExplanation(campaignID, reason string) Explanation {
+	return Explanation{CampaignID: campaignID, Verdict: VerdictInvalidTargeting, Detail: reason}
+}
+
+func (ct *campaignTargeting) explain(request map[string][]string, now time.Time) Explanation {
+	explanation := Explanation{CampaignID: ct.campaign.CampaignID}
+
+	// Every dimension the campaign has rules on or the request supplies
+	dimensions := make([]string, 0, len(ct.rules)+len(request))
+	for dimension := range ct.rules {
+		dimensions = append(dimensions, dimension)
+	}
+	for dimension := range request {
+		if _, ok := ct.rules[dimension]; !ok {
+			dimensions = append(dimensions, dimension)
+		}
+	}
+	sort.Strings(dimensions)
+
+	excluded, includeMissed := false, false
+	for _, dimension := range dimensions {
+		de := explainDimension(dimension, ct.rules[dimension], request[dimension])
+		excluded = excluded || de.Verdict == VerdictExcluded
+		includeMissed = includeMissed || de.Verdict == VerdictIncludeMissed
+		explanation.Dimensions = append(explanation.Dimensions, de)
+	}
+
+	if ct.expression != nil {
+		matched := ct.expression(request)
+		explanation.ExpressionMatched = &matched
+	}
+
+	switch {
+	case !ct.inFlight(now):
+		explanation.Verdict = VerdictOutOfFlight
+		explanation.Detail = flightWindow(ct.campaign.StartTime, ct.campaign.EndTime)
+	case excluded:
+		explanation.Verdict = VerdictExcluded
+	case includeMissed:
+		explanation.Verdict = VerdictIncludeMissed
+	case explanation.ExpressionMatched != nil && !*explanation.ExpressionMatched:
+		explanation.Verdict = VerdictExpressionMissed
+	default:
+		explanation.Verdict = VerdictDelivered
+		explanation.Delivered = true
+	}
+
+	return explanation
+}
+
+func explainDimension(dimension string, rules *dimensionRules, values []string) DimensionExplanation {
+	de := DimensionExplanation{Dimension: dimension, Values: values}
+
+	switch {
+	case rules == nil:
+		de.Verdict = VerdictNoRules
+		return de
+	case len(values) == 0 && !alwaysEvaluated[dimension]:
+		de.Verdict = VerdictNotEvaluated
+		return de
+	}
+
+	if rule, value, ok := firstMatch(rules.excludes, values); ok {
+		de.Verdict, de.Rule, de.MatchedValue = VerdictExcluded, ruleRef(rule), value
+		return de
+	}
+
+	if len(rules.includes) == 0 {
+		de.Verdict = VerdictNotExcluded
+		return de
+	}
+
+	if rule, value, ok := firstMatch(rules.includes, values); ok {
+		de.Verdict, de.Rule, de.MatchedValue = VerdictIncludeMatched, ruleRef(rule), value
+		return de
+	}
+
+	de.Verdict = VerdictIncludeMissed
+	return de
+}
+
+func ruleRef(rule models.TargetingRule) *RuleRef {
+	return &RuleRef{Type: rule.Type, Operator: rule.Operator, Value: rule.Value}
+}
+
+func flightWindow(start, end *time.Time) string {
+	format := func(t *time.Time) string {
+		if t == nil {
+			return "unbounded"
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return "flight " + format(start) + " to " + format(end)
+}
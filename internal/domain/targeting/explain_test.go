@@ -0,0 +1,100 @@
+package targeting
+
+import (
+	"campaign/internal/domain/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotExplain(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	ended := now.Add(-time.Hour)
+
+	campaigns := []models.Campaign{
+		{CampaignID: "camp_001"},
+		{CampaignID: "camp_002"},
+		{CampaignID: "camp_003", EndTime: &ended},
+		{CampaignID: "camp_004", TargetingExpression: `{"dimension": "os", "value": "ios"}`},
+		{CampaignID: "camp_bad"},
+	}
+	rules := []models.TargetingRule{
+		{CampaignID: "camp_001", Dimension: "country", Type: "include", Operator: "in", Value: "US,CA"},
+		{CampaignID: "camp_001", Dimension: "language", Type: "exclude", Operator: "eq", Value: "es"},
+		{CampaignID: "camp_002", Dimension: "country", Type: "include", Operator: "eq", Value: "GB"},
+		{CampaignID: "camp_002", Dimension: "age", Type: "include", Operator: "range", Value: "[18,34]"},
+		{CampaignID: "camp_bad", Dimension: "age", Type: "include", Operator: "range", Value: "oops"},
+	}
+	snapshot := NewSnapshot(campaigns, rules)
+	request := map[string][]string{"country": {"US"}, "os": {"android"}, "language": {"en"}}
+
+	explanations := snapshot.Explain(request, now)
+	verdicts := make(map[string]Verdict)
+	for _, e := range explanations {
+		verdicts[e.CampaignID] = e.Verdict
+	}
+	assert.Equal(t, map[string]Verdict{
+		"camp_001": VerdictDelivered,
+		"camp_002": VerdictIncludeMissed,
+		"camp_003": VerdictOutOfFlight,
+		"camp_004": VerdictExpressionMissed,
+		"camp_bad": VerdictInvalidTargeting,
+	}, verdicts)
+
+	// Explain agrees with Match
+	for _, e := range explanations {
+		assert.Equal(t, e.Verdict == VerdictDelivered, e.Delivered, e.CampaignID)
+	}
+
+	explanation, ok := snapshot.ExplainCampaign("camp_001", request, now)
+	assert.True(t, ok)
+	assert.Equal(t, []DimensionExplanation{
+		{Dimension: "country", Verdict: VerdictIncludeMatched, Values: []string{"US"},
+			Rule: &RuleRef{Type: "include", Operator: "in", Value: "US,CA"}, MatchedValue: "US"},
+		{Dimension: "language", Verdict: VerdictNotExcluded, Values: []string{"en"}},
+		{Dimension: "os", Verdict: VerdictNoRules, Values: []string{"android"}},
+	}, explanation.Dimensions)
+
+	explanation, _ = snapshot.ExplainCampaign("camp_002", request, now)
+	assert.Equal(t, []DimensionExplanation{
+		{Dimension: "age", Verdict: VerdictNotEvaluated},
+		{Dimension: "country", Verdict: VerdictIncludeMissed, Values: []string{"US"}},
+		{Dimension: "language", Verdict: VerdictNoRules, Values: []string{"en"}},
+		{Dimension: "os", Verdict: VerdictNoRules, Values: []string{"android"}},
+	}, explanation.Dimensions)
+
+	explanation, _ = snapshot.ExplainCampaign("camp_001", map[string][]string{"country": {"CA"}, "language": {"es"}}, now)
+	assert.Equal(t, VerdictExcluded, explanation.Verdict)
+	assert.Equal(t, "es", explanation.Dimensions[1].MatchedValue)
+
+	_, ok = snapshot.ExplainCampaign("camp_999", request, now)
+	assert.False(t, ok)
+}
+
+func TestSnapshotMatchFlight(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	snapshot := NewSnapshot([]models.Campaign{
+		{CampaignID: "camp_001", StartTime: &past, EndTime: &future},
+		{CampaignID: "camp_002", StartTime: &future},
+		{CampaignID: "camp_003", EndTime: &past},
+	}, nil)
+
+	matched := snapshot.Match(map[string][]string{"country": {"US"}})
+	assert.Len(t, matched, 1)
+	assert.Equal(t, "camp_001", matched[0].CampaignID)
+}
+
+func TestSnapshotNextFlightChange(t *testing.T) {
+	now := time.Now()
+	past, soon, later := now.Add(-time.Hour), now.Add(time.Minute), now.Add(time.Hour)
+
+	snapshot := NewSnapshot([]models.Campaign{
+		{CampaignID: "camp_001", StartTime: &past, EndTime: &later},
+		{CampaignID: "camp_002", StartTime: &soon},
+		{CampaignID: "camp_003", EndTime: &past},
+	}, nil)
+	assert.Equal(t, soon, snapshot.NextFlightChange(now))
+	assert.Equal(t, later, snapshot.NextFlightChange(soon))
+	assert.True(t, snapshot.NextFlightChange(later).IsZero())
+}
@@ -0,0 +1,221 @@
+package targeting
+
+import (
+	"bytes"
+	"campaign/internal/domain/models"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const maxExpressionDepth = 16
+
+// Expression is a boolean targeting expression stored as a JSON AST. Each node is exactly one of
+// an "and" list, an "or" list, a "not" node, or a condition on a single dimension, for example:
+//
+//	{"or": [
+//	  {"and": [{"dimension": "country", "value": "US"}, {"dimension": "os", "value": "ios"}]},
+//	  {"dimension": "country", "value": "CA"}
+//	]}
+//
+// A condition uses the same operators as targeting rules (eq when omitted) and is true when any of the
+// request's values for the dimension match. Unlike legacy rules, a dimension missing from the request
+// makes its condition false.
+type Expression struct {
+	And       []*Expression `json:"and,omitempty"`
+	Or        []*Expression `json:"or,omitempty"`
+	Not       *Expression   `json:"not,omitempty"`
+	Dimension string        `json:"dimension,omitempty"`
+	Operator  string        `json:"operator,omitempty"`
+	Value     string        `json:"value,omitempty"`
+}
+
+// Predicate evaluates a compiled expression against the request dimensions
+type Predicate func(request map[string][]string) bool
+
+// ParseExpression decodes and validates an expression, normalizing condition values in place
+func ParseExpression(data []byte) (*Expression, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var expr Expression
+	if err := decoder.Decode(&expr); err != nil {
+		return nil, fmt.Errorf("invalid targeting expression: %w", err)
+	}
+
+	if err := expr.validate(1); err != nil {
+		return nil, fmt.Errorf("invalid targeting expression: %w", err)
+	}
+
+	return &expr, nil
+}
+
+func (e *Expression) validate(depth int) error {
+	if depth > maxExpressionDepth {
+		return fmt.Errorf("expression is nested deeper than %d levels", maxExpressionDepth)
+	}
+
+	kinds := 0
+	if e.And != nil {
+		kinds++
+	}
+	if e.Or != nil {
+		kinds++
+	}
+	if e.Not != nil {
+		kinds++
+	}
+	if e.Dimension != "" || e.Operator != "" || e.Value != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return fmt.Errorf("each node must have exactly one of and, or, not or a dimension condition")
+	}
+
+	switch {
+	case e.And != nil || e.Or != nil:
+		children := e.And
+		if e.Or != nil {
+			children = e.Or
+		}
+		if len(children) == 0 {
+			return fmt.Errorf("and/or must have at least one operand")
+		}
+		for _, child := range children {
+			if child == nil {
+				return fmt.Errorf("and/or operands cannot be null")
+			}
+			if err := child.validate(depth + 1); err != nil {
+				return err
+			}
+		}
+	case e.Not != nil:
+		return e.Not.validate(depth + 1)
+	default:
+		// Conditions are validated and normalized exactly like stored rules
+		rule, err := PrepareRule(models.TargetingRule{
+			Dimension: e.Dimension,
+			Type:      RuleTypeInclude,
+			Operator:  e.Operator,
+			Value:     e.Value,
+		})
+		if err != nil {
+			return err
+		}
+		e.Dimension, e.Operator, e.Value = rule.Dimension, rule.Operator, rule.Value
+	}
+
+	return nil
+}
+
+// Compile builds the predicate for a validated expression
+func (e *Expression) Compile() (Predicate, error) {
+	switch {
+	case e.And != nil || e.Or != nil:
+		isAnd := e.And != nil
+		children := e.And
+		if !isAnd {
+			children = e.Or
+		}
+
+		predicates := make([]Predicate, 0, len(children))
+		for _, child := range children {
+			predicate, err := child.Compile()
+			if err != nil {
+				return nil, err
+			}
+			predicates = append(predicates, predicate)
+		}
+
+		return func(request map[string][]string) bool {
+			for _, predicate := range predicates {
+				if predicate(request) != isAnd {
+					return !isAnd
+				}
+			}
+			return isAnd
+		}, nil
+
+	case e.Not != nil:
+		predicate, err := e.Not.Compile()
+		if err != nil {
+			return nil, err
+		}
+		return func(request map[string][]string) bool { return !predicate(request) }, nil
+
+	default:
+		matcher, err := CompileRule(models.TargetingRule{Dimension: e.Dimension, Operator: e.Operator, Value: e.Value})
+		if err != nil {
+			return nil, err
+		}
+		dimension := e.Dimension
+		return func(request map[string][]string) bool {
+			for _, value := range request[dimension] {
+				if matcher(value) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+}
+
+// conditions returns the condition nodes of the expression, depth first
+func (e *Expression) conditions() []*Expression {
+	if e.Dimension != "" {
+		return []*Expression{e}
+	}
+
+	var conditions []*Expression
+	for _, child := range e.And {
+		conditions = append(conditions, child.conditions()...)
+	}
+	for _, child := range e.Or {
+		conditions = append(conditions, child.conditions()...)
+	}
+	if e.Not != nil {
+		conditions = append(conditions, e.Not.conditions()...)
+	}
+	return conditions
+}
+
+// Dimensions returns the dimensions the expression has conditions on, without duplicates
+func (e *Expression) Dimensions() []string {
+	var dimensions []string
+	for _, condition := range e.conditions() {
+		if !slices.Contains(dimensions, condition.Dimension) {
+			dimensions = append(dimensions, condition.Dimension)
+		}
+	}
+	return dimensions
+}
+
+// Values returns the literal values the eq and in conditions on dimension compare against, without
+// duplicates. Values of other operators are patterns or bounds rather than values and are left out.
+func (e *Expression) Values(dimension string) []string {
+	var values []string
+	for _, condition := range e.conditions() {
+		if condition.Dimension != dimension {
+			continue
+		}
+		switch Operator(condition.Operator) {
+		case OperatorEq, OperatorIn:
+			for _, value := range strings.Split(condition.Value, ",") {
+				if !slices.Contains(values, value) {
+					values = append(values, value)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// CompileExpression parses and compiles a stored expression in one step
+func CompileExpression(data []byte) (Predicate, error) {
+	expr, err := ParseExpression(data)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Compile()
+}
@@ -0,0 +1,112 @@
+package targeting
+
+import (
+	"campaign/internal/domain/models"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpression(t *testing.T) {
+	predicate, err := CompileExpression([]byte(`{"or": [
+		{"and": [{"dimension": "country", "value": "usa"}, {"dimension": "os", "value": "iOS"}]},
+		{"dimension": "country", "value": "CA"}
+	]}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		request map[string][]string
+		want    bool
+	}{
+		{name: "US on ios", request: map[string][]string{"country": {"US"}, "os": {"ios"}}, want: true},
+		{name: "US on android", request: map[string][]string{"country": {"US"}, "os": {"android"}}, want: false},
+		{name: "CA on any os", request: map[string][]string{"country": {"CA"}, "os": {"android"}}, want: true},
+		{name: "missing dimension is false", request: map[string][]string{"os": {"ios"}}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, predicate(tt.request))
+		})
+	}
+}
+
+func TestExpressionNotAndOperators(t *testing.T) {
+	predicate, err := CompileExpression([]byte(`{"and": [
+		{"dimension": "app_version", "operator": "semver_gte", "value": "5.2.0"},
+		{"not": {"dimension": "country", "operator": "in", "value": "RU,CN"}}
+	]}`))
+	require.NoError(t, err)
+
+	assert.True(t, predicate(map[string][]string{"app_version": {"5.3"}, "country": {"US"}}))
+	assert.False(t, predicate(map[string][]string{"app_version": {"5.3"}, "country": {"CN"}}))
+	assert.False(t, predicate(map[string][]string{"app_version": {"5.1"}, "country": {"US"}}))
+}
+
+func TestParseExpressionNormalizesAndRejectsInvalid(t *testing.T) {
+	expr, err := ParseExpression([]byte(`{"dimension": "Country", "value": "united states"}`))
+	require.NoError(t, err)
+	normalized, _ := json.Marshal(expr)
+	assert.JSONEq(t, `{"dimension": "country", "operator": "eq", "value": "US"}`, string(normalized))
+
+	invalid := []string{
+		`not json`,
+		`{}`,
+		`{"and": []}`,
+		`{"and": [null]}`,
+		`{"or": [{"dimension": "country", "value": "US"}], "dimension": "os", "value": "ios"}`,
+		`{"dimension": "age", "operator": "range", "value": "18-34"}`,
+		`{"dimension": "country", "value": "US", "extra": true}`,
+	}
+	for _, data := range invalid {
+		_, err := ParseExpression([]byte(data))
+		assert.Error(t, err, "expected %s to be rejected", data)
+	}
+}
+
+func TestSnapshotEvaluatesExpressionAfterRules(t *testing.T) {
+	campaigns := []models.Campaign{
+		{CampaignID: "camp_001", TargetingExpression: `{"or": [{"dimension": "country", "value": "US"}, {"dimension": "country", "value": "CA"}]}`},
+		{CampaignID: "camp_002", TargetingExpression: `{"dimension": "country"}`},
+	}
+	rules := []models.TargetingRule{
+		{CampaignID: "camp_001", Dimension: "os", Type: "exclude", Operator: "eq", Value: "android"},
+	}
+	snapshot := NewSnapshot(campaigns, rules)
+	assert.Equal(t, 1, snapshot.Len(), "campaign with an invalid expression is skipped")
+
+	assert.Len(t, snapshot.Match(map[string][]string{"country": {"CA"}, "os": {"ios"}}), 1)
+	assert.Empty(t, snapshot.Match(map[string][]string{"country": {"CA"}, "os": {"android"}}))
+	assert.Empty(t, snapshot.Match(map[string][]string{"country": {"GB"}, "os": {"ios"}}))
+}
+
+func TestSnapshotTargets(t *testing.T) {
+	campaigns := []models.Campaign{
+		{CampaignID: "camp_001", TargetingExpression: `{"and": [{"dimension": "country", "value": "US"}, {"not": {"dimension": "browser", "value": "safari"}}]}`},
+	}
+	rules := []models.TargetingRule{
+		{CampaignID: "camp_001", Dimension: "os", Type: "include", Operator: "eq", Value: "ios"},
+	}
+	snapshot := NewSnapshot(campaigns, rules)
+
+	assert.True(t, snapshot.Targets("os"))
+	assert.True(t, snapshot.Targets("country"))
+	assert.True(t, snapshot.Targets("browser"))
+	assert.False(t, snapshot.Targets("os_version"))
+}
+
+func TestExpressionValues(t *testing.T) {
+	expr, err := ParseExpression([]byte(`{"or": [
+		{"dimension": "segment", "value": "high_value"},
+		{"not": {"dimension": "segment", "operator": "in", "value": "churned,high_value"}},
+		{"dimension": "segment", "operator": "prefix", "value": "vip_"},
+		{"dimension": "country", "value": "US"}
+	]}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"high_value", "churned"}, expr.Values("segment"))
+	assert.Equal(t, []string{"segment", "country"}, expr.Dimensions())
+}
@@ -0,0 +1,85 @@
+package targeting
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusKm = 6371.0088
+
+// parseCoordinates parses "lat,lon" and validates the ranges
+func parseCoordinates(value string) (lat, lon float64, ok bool) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+
+	return lat, lon, true
+}
+
+// normalizeLocation rounds "lat,lon" to 2 decimals (~1 km), coarse enough that nearby users share a cache
+// key and fine enough for radius rules measured in kilometres.
+// Invalid coordinates normalize to an empty value and are dropped from the request.
+func normalizeLocation(value string) string {
+	lat, lon, ok := parseCoordinates(value)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%.2f,%.2f", lat, lon)
+}
+
+// CoarsenLocation rounds "lat,lon" to 1 decimal (~10 km), for keeping where requests came from without
+// pinpointing users. Invalid coordinates give an empty value.
+func CoarsenLocation(value string) string {
+	lat, lon, ok := parseCoordinates(value)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%.1f,%.1f", lat, lon)
+}
+
+// compileRadius parses "lat,lon,radius_km" and matches request locations within that distance
+func compileRadius(raw string) (Matcher, error) {
+	i := strings.LastIndex(raw, ",")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid radius %q: expected lat,lon,radius_km", raw)
+	}
+
+	centerLat, centerLon, ok := parseCoordinates(raw[:i])
+	if !ok {
+		return nil, fmt.Errorf("invalid radius %q: expected lat,lon,radius_km", raw)
+	}
+	radiusKm, err := strconv.ParseFloat(strings.TrimSpace(raw[i+1:]), 64)
+	if err != nil || radiusKm <= 0 {
+		return nil, fmt.Errorf("invalid radius %q: radius_km must be a positive number", raw)
+	}
+
+	return func(value string) bool {
+		lat, lon, ok := parseCoordinates(value)
+		return ok && distanceKm(centerLat, centerLon, lat, lon) <= radiusKm
+	}, nil
+}
+
+// distanceKm returns the great-circle (haversine) distance between two coordinates in kilometres
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,144 @@
+package targeting
+
+import (
+	"strings"
+)
+
+// Normalizer converts a raw targeting value into its canonical form for a dimension
+type Normalizer func(value string) string
+
+// normalizers maps each dimension to its normalizer. Dimensions not listed here
+// (e.g. app_id, timezone) are only trimmed, since their values are case-sensitive identifiers.
+var normalizers = map[string]Normalizer{
+	"country":     NormalizeCountry,
+	"os":          NormalizeOS,
+	"device_type": strings.ToLower,
+	"language":    normalizeLanguage,
+	"locale":      normalizeLanguage,
+	"age_group":   strings.ToLower,
+	"gender":      strings.ToLower,
+	"region":      strings.ToUpper,
+	"city":        strings.ToLower,
+	"location":    normalizeLocation,
+	"browser":     strings.ToLower,
+}
+
+// countryLookup indexes every alpha-2 code, alpha-3 code and name (lowercased) to its alpha-2 code
+var countryLookup = buildCountryLookup()
+
+// countryAliases covers common non-ISO spellings seen in client requests and legacy rules
+var countryAliases = map[string]string{
+	"uk":                       "GB",
+	"great britain":            "GB",
+	"england":                  "GB",
+	"britain":                  "GB",
+	"usa":                      "US",
+	"united states of america": "US",
+	"america":                  "US",
+	"south korea":              "KR",
+	"korea":                    "KR",
+	"north korea":              "KP",
+	"russia":                   "RU",
+	"vietnam":                  "VN",
+	"iran":                     "IR",
+	"syria":                    "SY",
+	"taiwan":                   "TW",
+	"bolivia":                  "BO",
+	"venezuela":                "VE",
+	"tanzania":                 "TZ",
+	"moldova":                  "MD",
+	"laos":                     "LA",
+	"czech republic":           "CZ",
+	"turkey":                   "TR",
+}
+
+// osAliases maps lowercased operating system spellings to their canonical name
+var osAliases = map[string]string{
+	"ios":          "ios",
+	"iphone os":    "ios",
+	"iphoneos":     "ios",
+	"ipados":       "ios",
+	"ipad os":      "ios",
+	"android":      "android",
+	"android os":   "android",
+	"windows":      "windows",
+	"win":          "windows",
+	"win32":        "windows",
+	"win64":        "windows",
+	"windows nt":   "windows",
+	"macos":        "macos",
+	"mac os":       "macos",
+	"mac os x":     "macos",
+	"osx":          "macos",
+	"os x":         "macos",
+	"macintosh":    "macos",
+	"darwin":       "macos",
+	"linux":        "linux",
+	"chromeos":     "chromeos",
+	"chrome os":    "chromeos",
+	"cros":         "chromeos",
+	"tvos":         "tvos",
+	"watchos":      "watchos",
+	"harmonyos":    "harmonyos",
+	"harmony os":   "harmonyos",
+	"kaios":        "kaios",
+	"tizen":        "tizen",
+	"webos":        "webos",
+	"blackberry":   "blackberry",
+	"firefoxos":    "firefoxos",
+	"firefox os":   "firefoxos",
+	"ubuntu":       "ubuntu",
+	"symbian":      "symbian",
+	"symbianos":    "symbian",
+	"windowsphone": "windowsphone",
+}
+
+func buildCountryLookup() map[string]string {
+	lookup := make(map[string]string, len(iso3166)*3+len(countryAliases))
+	for _, c := range iso3166 {
+		lookup[strings.ToLower(c[0])] = c[0]
+		lookup[strings.ToLower(c[1])] = c[0]
+		lookup[strings.ToLower(c[2])] = c[0]
+	}
+	for alias, code := range countryAliases {
+		lookup[alias] = code
+	}
+	return lookup
+}
+
+// Normalize returns the canonical form of value for the given dimension
+func Normalize(dimension, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+
+	if normalizer, ok := normalizers[dimension]; ok {
+		return normalizer(value)
+	}
+	return value
+}
+
+// NormalizeCountry maps ISO 3166-1 alpha-2, alpha-3 codes and English names to the upper-case alpha-2 code.
+// Unknown values are upper-cased so that they still compare consistently.
+func NormalizeCountry(value string) string {
+	key := strings.ToLower(strings.TrimSpace(value))
+	if code, ok := countryLookup[key]; ok {
+		return code
+	}
+	return strings.ToUpper(key)
+}
+
+// NormalizeOS maps known operating system aliases to their canonical lower-case name
+func NormalizeOS(value string) string {
+	key := strings.Join(strings.Fields(strings.ToLower(value)), " ")
+	if os, ok := osAliases[key]; ok {
+		return os
+	}
+	return key
+}
+
+// normalizeLanguage lower-cases language tags and uses '-' as the subtag separator (en_US -> en-us)
+func normalizeLanguage(value string) string {
+	return strings.ReplaceAll(strings.ToLower(value), "_", "-")
+}
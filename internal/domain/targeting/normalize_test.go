@@ -0,0 +1,36 @@
+package targeting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		dimension string
+		value     string
+		want      string
+	}{
+		{name: "country alpha-2 lower case", dimension: "country", value: "us", want: "US"},
+		{name: "country alpha-3", dimension: "country", value: "CAN", want: "CA"},
+		{name: "country name", dimension: "country", value: "United Kingdom", want: "GB"},
+		{name: "country alias", dimension: "country", value: "UK", want: "GB"},
+		{name: "unknown country", dimension: "country", value: "xx", want: "XX"},
+		{name: "os case", dimension: "os", value: "Android", want: "android"},
+		{name: "os alias", dimension: "os", value: "iPadOS", want: "ios"},
+		{name: "os extra whitespace", dimension: "os", value: "  Mac   OS X ", want: "macos"},
+		{name: "language tag", dimension: "language", value: "en_US", want: "en-us"},
+		{name: "app_id kept as-is", dimension: "app_id", value: " Test_App ", want: "Test_App"},
+		{name: "location rounded", dimension: "location", value: "40.712776,-74.005974", want: "40.71,-74.01"},
+		{name: "location out of range", dimension: "location", value: "95,10", want: ""},
+		{name: "empty value", dimension: "country", value: "   ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Normalize(tt.dimension, tt.value))
+		})
+	}
+}
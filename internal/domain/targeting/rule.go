@@ -0,0 +1,257 @@
+package targeting
+
+import (
+	"campaign/internal/domain/models"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Operator is the comparison a targeting rule applies to the request values of its dimension
+type Operator string
+
+const (
+	// OperatorEq matches a value equal to the rule value
+	OperatorEq Operator = "eq"
+	// OperatorIn matches any of the comma-separated rule values
+	OperatorIn Operator = "in"
+	// OperatorPrefix matches values starting with the rule value (e.g. locale "en-")
+	OperatorPrefix Operator = "prefix"
+	// OperatorRange matches values inside an interval such as "[18,34]" or "[,14)"
+	OperatorRange Operator = "range"
+	// OperatorSemverGTE matches versions greater than or equal to the rule value
+	OperatorSemverGTE Operator = "semver_gte"
+	// OperatorSemverLTE matches versions less than or equal to the rule value
+	OperatorSemverLTE Operator = "semver_lte"
+	// OperatorRegex matches values against an RE2 regular expression (unanchored)
+	OperatorRegex Operator = "regex"
+	// OperatorRadius matches "lat,lon" locations within "lat,lon,radius_km" of a point
+	OperatorRadius Operator = "radius"
+)
+
+const (
+	RuleTypeInclude = "include"
+	RuleTypeExclude = "exclude"
+)
+
+var validOperators = map[Operator]bool{
+	OperatorEq:        true,
+	OperatorIn:        true,
+	OperatorPrefix:    true,
+	OperatorRange:     true,
+	OperatorSemverGTE: true,
+	OperatorSemverLTE: true,
+	OperatorRegex:     true,
+	OperatorRadius:    true,
+}
+
+var dimensionPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Matcher reports whether a single request value satisfies a rule
+type Matcher func(value string) bool
+
+// PrepareRule validates a rule and normalizes its value for storage.
+// An empty operator defaults to eq so that legacy rules keep their meaning.
+func PrepareRule(rule models.TargetingRule) (models.TargetingRule, error) {
+	rule.Dimension = strings.ToLower(strings.TrimSpace(rule.Dimension))
+	rule.Type = strings.ToLower(strings.TrimSpace(rule.Type))
+	rule.Operator = strings.ToLower(strings.TrimSpace(rule.Operator))
+	if rule.Operator == "" {
+		rule.Operator = string(OperatorEq)
+	}
+
+	if !dimensionPattern.MatchString(rule.Dimension) {
+		return rule, fmt.Errorf("invalid dimension %q", rule.Dimension)
+	}
+	if rule.Type != RuleTypeInclude && rule.Type != RuleTypeExclude {
+		return rule, fmt.Errorf("invalid rule type %q: must be include or exclude", rule.Type)
+	}
+	if !validOperators[Operator(rule.Operator)] {
+		return rule, fmt.Errorf("invalid operator %q", rule.Operator)
+	}
+
+	switch Operator(rule.Operator) {
+	case OperatorEq, OperatorPrefix:
+		rule.Value = Normalize(rule.Dimension, rule.Value)
+	case OperatorIn:
+		var values []string
+		for _, part := range strings.Split(rule.Value, ",") {
+			if value := Normalize(rule.Dimension, part); value != "" {
+				values = append(values, value)
+			}
+		}
+		rule.Value = strings.Join(values, ",")
+	default:
+		rule.Value = strings.TrimSpace(rule.Value)
+	}
+
+	if rule.Value == "" {
+		return rule, fmt.Errorf("targeting rule value cannot be empty for dimension %s", rule.Dimension)
+	}
+
+	if _, err := CompileRule(rule); err != nil {
+		return rule, err
+	}
+
+	return rule, nil
+}
+
+// CompileRule builds the matcher for a stored rule
+func CompileRule(rule models.TargetingRule) (Matcher, error) {
+	operator := Operator(rule.Operator)
+	if operator == "" {
+		operator = OperatorEq
+	}
+
+	switch operator {
+	case OperatorEq:
+		expected := rule.Value
+		return func(value string) bool { return value == expected }, nil
+
+	case OperatorIn:
+		set := make(map[string]struct{})
+		for _, part := range strings.Split(rule.Value, ",") {
+			set[strings.TrimSpace(part)] = struct{}{}
+		}
+		return func(value string) bool {
+			_, ok := set[value]
+			return ok
+		}, nil
+
+	case OperatorPrefix:
+		prefix := rule.Value
+		return func(value string) bool { return strings.HasPrefix(value, prefix) }, nil
+
+	case OperatorRange:
+		return compileRange(rule.Dimension, rule.Value)
+
+	case OperatorSemverGTE, OperatorSemverLTE:
+		target, ok := parseVersion(rule.Value)
+		if !ok {
+			return nil, fmt.Errorf("invalid version %q for operator %s", rule.Value, operator)
+		}
+		return func(value string) bool {
+			v, ok := parseVersion(value)
+			if !ok {
+				return false
+			}
+			if operator == OperatorSemverGTE {
+				return v.compare(target) >= 0
+			}
+			return v.compare(target) <= 0
+		}, nil
+
+	case OperatorRegex:
+		re, err := regexp.Compile(rule.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", rule.Value, err)
+		}
+		return re.MatchString, nil
+
+	case OperatorRadius:
+		return compileRadius(rule.Value)
+	}
+
+	return nil, fmt.Errorf("invalid operator %q", rule.Operator)
+}
+
+// rangeBound is one end of an interval; a nil bound is unbounded
+type rangeBound struct {
+	number    float64
+	version   version
+	inclusive bool
+}
+
+// compileRange parses interval notation: "[18,34]" (inclusive), "[5,10)" (half-open), "[,14)" (no lower bound).
+// Bounds compare numerically, except for version dimensions (app_version, os_version, ...) and bounds
+// with more than one dot, which compare as versions so that "13.4.1" is below "14".
+func compileRange(dimension, raw string) (Matcher, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) < 3 {
+		return nil, fmt.Errorf("invalid range %q", raw)
+	}
+
+	lowerBracket, upperBracket := raw[0], raw[len(raw)-1]
+	if (lowerBracket != '[' && lowerBracket != '(') || (upperBracket != ']' && upperBracket != ')') {
+		return nil, fmt.Errorf("invalid range %q: expected [min,max], (min,max) or a mix", raw)
+	}
+
+	parts := strings.Split(raw[1:len(raw)-1], ",")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid range %q: expected exactly two bounds", raw)
+	}
+
+	lowerRaw, upperRaw := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	if lowerRaw == "" && upperRaw == "" {
+		return nil, fmt.Errorf("invalid range %q: at least one bound is required", raw)
+	}
+
+	asVersion := isVersionDimension(dimension) || strings.Count(lowerRaw, ".") > 1 || strings.Count(upperRaw, ".") > 1
+
+	parseBound := func(s string, inclusive bool) (*rangeBound, error) {
+		if s == "" {
+			return nil, nil
+		}
+		if asVersion {
+			v, ok := parseVersion(s)
+			if !ok {
+				return nil, fmt.Errorf("invalid version bound %q in range %q", s, raw)
+			}
+			return &rangeBound{version: v, inclusive: inclusive}, nil
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid numeric bound %q in range %q", s, raw)
+		}
+		return &rangeBound{number: n, inclusive: inclusive}, nil
+	}
+
+	lower, err := parseBound(lowerRaw, lowerBracket == '[')
+	if err != nil {
+		return nil, err
+	}
+	upper, err := parseBound(upperRaw, upperBracket == ']')
+	if err != nil {
+		return nil, err
+	}
+
+	// compareTo returns the ordering of value relative to a bound, or false when value is not comparable
+	compareTo := func(value string, b *rangeBound) (int, bool) {
+		if asVersion {
+			v, ok := parseVersion(value)
+			if !ok {
+				return 0, false
+			}
+			return v.compare(b.version), true
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case n < b.number:
+			return -1, true
+		case n > b.number:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	return func(value string) bool {
+		if lower != nil {
+			cmp, ok := compareTo(value, lower)
+			if !ok || cmp < 0 || (cmp == 0 && !lower.inclusive) {
+				return false
+			}
+		}
+		if upper != nil {
+			cmp, ok := compareTo(value, upper)
+			if !ok || cmp > 0 || (cmp == 0 && !upper.inclusive) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
@@ -0,0 +1,102 @@
+package targeting
+
+import (
+	"campaign/internal/domain/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		dimension string
+		operator  Operator
+		value     string
+		matches   []string
+		misses    []string
+	}{
+		{name: "eq", dimension: "country", operator: OperatorEq, value: "US", matches: []string{"US"}, misses: []string{"CA", "us"}},
+		{name: "in", dimension: "country", operator: OperatorIn, value: "US,CA", matches: []string{"US", "CA"}, misses: []string{"GB"}},
+		{name: "prefix", dimension: "locale", operator: OperatorPrefix, value: "en-", matches: []string{"en-us", "en-gb"}, misses: []string{"es-es", "en"}},
+		{name: "numeric range inclusive", dimension: "age", operator: OperatorRange, value: "[18,34]", matches: []string{"18", "25", "34"}, misses: []string{"17", "35", "abc"}},
+		{name: "numeric range half-open", dimension: "age", operator: OperatorRange, value: "[18,34)", matches: []string{"18", "33.9"}, misses: []string{"34"}},
+		{name: "version range upper only", dimension: "os_version", operator: OperatorRange, value: "[,14)", matches: []string{"13", "13.4.1", "9"}, misses: []string{"14", "14.0.1", "17.2"}},
+		{name: "semver gte", dimension: "app_version", operator: OperatorSemverGTE, value: "5.2.0", matches: []string{"5.2.0", "5.2", "5.10.0", "v6"}, misses: []string{"5.1.9", "5.2.0-beta", "latest"}},
+		{name: "semver lte", dimension: "app_version", operator: OperatorSemverLTE, value: "5.2.0", matches: []string{"5.2.0", "5.1.9", "5.2.0-rc1"}, misses: []string{"5.2.1", "5.10"}},
+		{name: "radius", dimension: "location", operator: OperatorRadius, value: "40.7128,-74.0060,25", matches: []string{"40.7306,-73.9352"}, misses: []string{"42.3601,-71.0589", "91,0", "nowhere"}},
+		{name: "regex", dimension: "device_model", operator: OperatorRegex, value: "^Pixel [6-8]", matches: []string{"Pixel 7 Pro"}, misses: []string{"Pixel 5", "Galaxy S23"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := CompileRule(models.TargetingRule{Dimension: tt.dimension, Operator: string(tt.operator), Value: tt.value})
+			require.NoError(t, err)
+
+			for _, value := range tt.matches {
+				assert.True(t, matcher(value), "expected %q to match", value)
+			}
+			for _, value := range tt.misses {
+				assert.False(t, matcher(value), "expected %q not to match", value)
+			}
+		})
+	}
+}
+
+func TestPrepareRule(t *testing.T) {
+	rule, err := PrepareRule(models.TargetingRule{CampaignID: "camp_001", Dimension: "country", Type: "include", Operator: "in", Value: "usa, Canada ,uk"})
+	require.NoError(t, err)
+	assert.Equal(t, "US,CA,GB", rule.Value)
+
+	rule, err = PrepareRule(models.TargetingRule{CampaignID: "camp_001", Dimension: "os", Type: "exclude", Value: "iPadOS"})
+	require.NoError(t, err)
+	assert.Equal(t, "eq", rule.Operator)
+	assert.Equal(t, "ios", rule.Value)
+
+	invalid := []models.TargetingRule{
+		{Dimension: "country", Type: "maybe", Value: "US"},
+		{Dimension: "country", Type: "include", Operator: "like", Value: "US"},
+		{Dimension: "Country Code", Type: "include", Value: "US"},
+		{Dimension: "age", Type: "include", Operator: "range", Value: "18-34"},
+		{Dimension: "app_version", Type: "include", Operator: "semver_gte", Value: "latest"},
+		{Dimension: "device_model", Type: "include", Operator: "regex", Value: "Pixel ["},
+		{Dimension: "country", Type: "include", Value: "  "},
+	}
+	for _, r := range invalid {
+		_, err := PrepareRule(r)
+		assert.Error(t, err, "expected %+v to be rejected", r)
+	}
+}
+
+func TestSnapshotMatch(t *testing.T) {
+	campaigns := []models.Campaign{
+		{CampaignID: "camp_003"},
+		{CampaignID: "camp_001"},
+		{CampaignID: "camp_002"},
+		{CampaignID: "camp_bad"},
+	}
+	rules := []models.TargetingRule{
+		{CampaignID: "camp_001", Dimension: "country", Type: "include", Operator: "eq", Value: "US"},
+		{CampaignID: "camp_001", Dimension: "app_version", Type: "include", Operator: "semver_gte", Value: "5.2.0"},
+		{CampaignID: "camp_002", Dimension: "language", Type: "exclude", Operator: "eq", Value: "es"},
+		{CampaignID: "camp_bad", Dimension: "age", Type: "include", Operator: "range", Value: "oops"},
+	}
+	snapshot := NewSnapshot(campaigns, rules)
+	assert.Equal(t, 3, snapshot.Len())
+
+	ids := func(cs []models.Campaign) []string {
+		var out []string
+		for _, c := range cs {
+			out = append(out, c.CampaignID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"camp_001", "camp_002", "camp_003"},
+		ids(snapshot.Match(map[string][]string{"country": {"US"}, "app_version": {"5.3"}})))
+	assert.Equal(t, []string{"camp_002", "camp_003"},
+		ids(snapshot.Match(map[string][]string{"country": {"US"}, "app_version": {"5.1"}})))
+	assert.Equal(t, []string{"camp_001", "camp_003"},
+		ids(snapshot.Match(map[string][]string{"country": {"CA", "US"}, "language": {"en", "es"}})))
+}
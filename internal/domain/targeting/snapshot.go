@@ -0,0 +1,220 @@
+package targeting
+
+import (
+	"campaign/internal/domain/models"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SegmentDimension holds the audience segments resolved for the request's user
+const SegmentDimension = "segment"
+
+// alwaysEvaluated lists dimensions whose rules apply even when the request has no values for them:
+// a user in no segment must not receive campaigns that include a segment.
+var alwaysEvaluated = map[string]bool{
+	SegmentDimension: true,
+}
+
+// compiledRule is a targeting rule together with its matcher
+type compiledRule struct {
+	rule  models.TargetingRule
+	match Matcher
+}
+
+// dimensionRules holds the compiled include and exclude rules of one campaign for one dimension
+type dimensionRules struct {
+	includes []compiledRule
+	excludes []compiledRule
+}
+
+// campaignTargeting is an active campaign together with its compiled rules, keyed by dimension,
+// and its optional compiled targeting expression
+type campaignTargeting struct {
+	campaign   models.Campaign
+	rules      map[string]*dimensionRules
+	expression Predicate
+}
+
+// Snapshot is an immutable in-memory view of the active campaigns and their compiled targeting rules
+type Snapshot struct {
+	campaigns []*campaignTargeting // ordered by campaign_id
+	invalid   map[string]string    // campaign_id -> why its targeting failed to compile
+	targeted  map[string]bool      // dimensions some rule or expression has conditions on
+	LoadedAt  time.Time
+}
+
+// NewSnapshot compiles the rules and expressions of the given campaigns. A campaign with a rule or
+// expression that fails to compile is left out of the snapshot, so bad targeting can never widen delivery.
+func NewSnapshot(campaigns []models.Campaign, rules []models.TargetingRule) *Snapshot {
+	byID := make(map[string]*campaignTargeting, len(campaigns))
+	invalid := make(map[string]string)
+	targeted := make(map[string]bool)
+	for _, campaign := range campaigns {
+		ct := &campaignTargeting{
+			campaign: campaign,
+			rules:    make(map[string]*dimensionRules),
+		}
+
+		if campaign.TargetingExpression != "" {
+			predicate, err := compileCampaignExpression(campaign.TargetingExpression, targeted)
+			if err != nil {
+				invalid[campaign.CampaignID] = err.Error()
+			}
+			ct.expression = predicate
+		}
+
+		byID[campaign.CampaignID] = ct
+	}
+
+	for _, rule := range rules {
+		ct, ok := byID[rule.CampaignID]
+		if !ok {
+			continue
+		}
+
+		matcher, err := CompileRule(rule)
+		if err != nil {
+			reason := fmt.Sprintf("invalid %s rule on %s: %v", rule.Type, rule.Dimension, err)
+			invalid[rule.CampaignID] = reason
+			continue
+		}
+
+		targeted[rule.Dimension] = true
+		dr, ok := ct.rules[rule.Dimension]
+		if !ok {
+			dr = &dimensionRules{}
+			ct.rules[rule.Dimension] = dr
+		}
+
+		compiled := compiledRule{rule: rule, match: matcher}
+		if rule.Type == RuleTypeExclude {
+			dr.excludes = append(dr.excludes, compiled)
+		} else {
+			dr.includes = append(dr.includes, compiled)
+		}
+	}
+
+	snapshot := &Snapshot{
+		campaigns: make([]*campaignTargeting, 0, len(byID)),
+		invalid:   invalid,
+		targeted:  targeted,
+		LoadedAt:  time.Now(),
+	}
+	for id, ct := range byID {
+		if _, skipped := invalid[id]; !skipped {
+			snapshot.campaigns = append(snapshot.campaigns, ct)
+		}
+	}
+	sort.Slice(snapshot.campaigns, func(i, j int) bool {
+		return snapshot.campaigns[i].campaign.CampaignID < snapshot.campaigns[j].campaign.CampaignID
+	})
+
+	return snapshot
+}
+
+// compileCampaignExpression compiles a stored expression and marks the dimensions it has conditions on
+func compileCampaignExpression(data string, targeted map[string]bool) (Predicate, error) {
+	expr, err := ParseExpression([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	for _, dimension := range expr.Dimensions() {
+		targeted[dimension] = true
+	}
+	return expr.Compile()
+}
+
+// Targets reports whether any campaign has a rule or expression condition on dimension. Request values of
+// other dimensions cannot change which campaigns match.
+func (s *Snapshot) Targets(dimension string) bool {
+	return s.targeted[dimension]
+}
+
+// Len returns the number of campaigns in the snapshot
+func (s *Snapshot) Len() int {
+	return len(s.campaigns)
+}
+
+// Match returns the campaigns targeted by the request, ordered by campaign_id.
+//
+// Legacy targeting rules are evaluated first: for every dimension a campaign has rules on and the request
+// supplies values for, at least one value must satisfy an include rule (when the campaign has includes)
+// and no value may satisfy an exclude rule. Dimensions the request does not supply are not evaluated,
+// except segment, where no values means no include can match.
+// A campaign passing its rules must then also satisfy its targeting expression, if it has one.
+// Campaigns outside their flight window are never matched.
+func (s *Snapshot) Match(request map[string][]string) []models.Campaign {
+	now := time.Now()
+
+	var campaigns []models.Campaign
+	for _, ct := range s.campaigns {
+		if ct.inFlight(now) && ct.matches(request) {
+			campaigns = append(campaigns, ct.campaign)
+		}
+	}
+	return campaigns
+}
+
+// NextFlightChange returns the earliest flight start or end after now, when the campaigns matched by Match
+// next change without any targeting change. It is zero when no flight window bounds lie ahead.
+func (s *Snapshot) NextFlightChange(now time.Time) time.Time {
+	var next time.Time
+	for _, ct := range s.campaigns {
+		for _, bound := range []*time.Time{ct.campaign.StartTime, ct.campaign.EndTime} {
+			if bound != nil && bound.After(now) && (next.IsZero() || bound.Before(next)) {
+				next = *bound
+			}
+		}
+	}
+	return next
+}
+
+// inFlight reports whether now is within the campaign's flight window
+func (ct *campaignTargeting) inFlight(now time.Time) bool {
+	if start := ct.campaign.StartTime; start != nil && now.Before(*start) {
+		return false
+	}
+	if end := ct.campaign.EndTime; end != nil && !now.Before(*end) {
+		return false
+	}
+	return true
+}
+
+func (ct *campaignTargeting) matches(request map[string][]string) bool {
+	for dimension, rules := range ct.rules {
+		values := request[dimension]
+		if len(values) == 0 && !alwaysEvaluated[dimension] {
+			continue
+		}
+
+		if len(rules.includes) > 0 && !anyMatch(rules.includes, values) {
+			return false
+		}
+		if anyMatch(rules.excludes, values) {
+			return false
+		}
+	}
+
+	if ct.expression != nil {
+		return ct.expression(request)
+	}
+	return true
+}
+
+func anyMatch(rules []compiledRule, values []string) bool {
+	_, _, ok := firstMatch(rules, values)
+	return ok
+}
+
+// firstMatch returns the first rule matched by any of the values, and the value that matched it
+func firstMatch(rules []compiledRule, values []string) (models.TargetingRule, string, bool) {
+	for _, rule := range rules {
+		for _, value := range values {
+			if rule.match(value) {
+				return rule.rule, value, true
+			}
+		}
+	}
+	return models.TargetingRule{}, "", false
+}
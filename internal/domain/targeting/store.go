@@ -0,0 +1,37 @@
+package targeting
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/pkg/refresh"
+	"log/slog"
+)
+
+// Loader fetches the active campaigns and their targeting rules
+type Loader func() ([]models.Campaign, []models.TargetingRule, error)
+
+// Store holds the current targeting snapshot and swaps in a fresh one on every refresh
+type Store struct {
+	*refresh.Store[Snapshot]
+}
+
+func NewStore(logger *slog.Logger, loader Loader) *Store {
+	return &Store{
+		Store: refresh.NewStore("targeting snapshot", logger, func() (*Snapshot, error) {
+			campaigns, rules, err := loader()
+			if err != nil {
+				return nil, err
+			}
+
+			snapshot := NewSnapshot(campaigns, rules)
+			for campaignID, reason := range snapshot.invalid {
+				logger.Warn("skipping campaign with invalid targeting", "campaign_id", campaignID, "error", reason)
+			}
+			return snapshot, nil
+		}),
+	}
+}
+
+// Snapshot returns the current snapshot, loading it on first use
+func (s *Store) Snapshot() (*Snapshot, error) {
+	return s.Get()
+}
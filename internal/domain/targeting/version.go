@@ -0,0 +1,77 @@
+package targeting
+
+import (
+	"strconv"
+	"strings"
+)
+
+// version is a parsed dotted version such as 5.2.0, 14 or 13.4.1-beta
+type version struct {
+	segments   []int
+	prerelease string
+}
+
+// parseVersion parses dotted numeric versions. A leading "v" and build metadata ("+...") are ignored,
+// a pre-release suffix ("-beta") is kept and sorts before the matching release.
+func parseVersion(raw string) (version, bool) {
+	raw = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(raw)), "v")
+	if i := strings.IndexByte(raw, '+'); i >= 0 {
+		raw = raw[:i]
+	}
+
+	var v version
+	if i := strings.IndexByte(raw, '-'); i >= 0 {
+		v.prerelease = raw[i+1:]
+		raw = raw[:i]
+	}
+	if raw == "" {
+		return version{}, false
+	}
+
+	for _, part := range strings.Split(raw, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return version{}, false
+		}
+		v.segments = append(v.segments, n)
+	}
+
+	return v, true
+}
+
+// compare returns -1, 0 or 1. Missing segments count as zero, so 14 == 14.0.0.
+func (v version) compare(other version) int {
+	for i := 0; i < len(v.segments) || i < len(other.segments); i++ {
+		a, b := 0, 0
+		if i < len(v.segments) {
+			a = v.segments[i]
+		}
+		if i < len(other.segments) {
+			b = other.segments[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	case v.prerelease < other.prerelease:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// isVersionDimension reports whether range bounds for dimension should be compared as versions
+func isVersionDimension(dimension string) bool {
+	return dimension == "version" || strings.HasSuffix(dimension, "_version")
+}
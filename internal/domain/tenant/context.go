@@ -0,0 +1,22 @@
+package tenant
+
+import "context"
+
+// DefaultID is the tenant that data created before multi-tenancy belongs to. Requests carry it when no
+// tenant was resolved, e.g. in tests or when the tenant middleware is not installed.
+const DefaultID = "default"
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the tenant of the request
+func NewContext(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext returns the tenant of the request, or DefaultID if none was set
+func FromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(contextKey{}).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return DefaultID
+}
@@ -0,0 +1,84 @@
+package tenant
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/pkg/refresh"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Directory indexes the tenants by ID and by host
+type Directory struct {
+	tenants map[string]models.Tenant
+	hosts   map[string]string
+}
+
+func NewDirectory(tenants []models.Tenant) *Directory {
+	d := &Directory{
+		tenants: make(map[string]models.Tenant, len(tenants)),
+		hosts:   make(map[string]string),
+	}
+	for _, t := range tenants {
+		d.tenants[t.TenantID] = t
+		for _, host := range t.Hosts {
+			d.hosts[normalizeHost(host)] = t.TenantID
+		}
+	}
+	return d
+}
+
+// Lookup returns the tenant with the given ID
+func (d *Directory) Lookup(tenantID string) (models.Tenant, bool) {
+	t, ok := d.tenants[tenantID]
+	return t, ok
+}
+
+// ForHost returns the tenant serving a request host. The port, if any, is ignored.
+func (d *Directory) ForHost(host string) (string, bool) {
+	tenantID, ok := d.hosts[normalizeHost(host)]
+	return tenantID, ok
+}
+
+// IDs returns the IDs of every tenant, sorted
+func (d *Directory) IDs() []string {
+	ids := make([]string, 0, len(d.tenants))
+	for id := range d.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// Loader fetches every tenant
+type Loader func() ([]models.Tenant, error)
+
+// Store holds the current Directory and swaps in a fresh one on every refresh
+type Store struct {
+	*refresh.Store[Directory]
+}
+
+func NewStore(logger *slog.Logger, loader Loader) *Store {
+	return &Store{
+		Store: refresh.NewStore("tenants", logger, func() (*Directory, error) {
+			tenants, err := loader()
+			if err != nil {
+				return nil, err
+			}
+			return NewDirectory(tenants), nil
+		}),
+	}
+}
+
+// Directory returns the current directory, loading it on first use
+func (s *Store) Directory() (*Directory, error) {
+	return s.Get()
+}
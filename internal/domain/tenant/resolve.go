@@ -0,0 +1,60 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnresolved is returned for a request that neither its API key nor its host assigns to a tenant,
+// when there is no fallback tenant, and for credentials naming a tenant that does not exist
+var ErrUnresolved = errors.New("tenant could not be resolved")
+
+// Resolver picks the tenant of a request
+type Resolver struct {
+	directory *Store
+	fallback  string
+}
+
+// NewResolver resolves hosts through directory, which may be nil to skip host lookups. Requests that
+// resolve to no tenant get fallback, or ErrUnresolved if fallback is empty.
+func NewResolver(directory *Store, fallback string) *Resolver {
+	return &Resolver{
+		directory: directory,
+		fallback:  fallback,
+	}
+}
+
+// WithoutFallback returns a resolver over the same directory that rejects unresolved requests
+func (r *Resolver) WithoutFallback() *Resolver {
+	return NewResolver(r.directory, "")
+}
+
+// Resolve returns the tenant of the caller's credentials when the request is authenticated, since they
+// are the stronger proof, and otherwise the tenant serving the request host. A credential's tenant missing
+// from the directory is not resolved.
+func (r *Resolver) Resolve(clientTenant, host string) (string, error) {
+	if r.directory == nil {
+		if clientTenant != "" {
+			return clientTenant, nil
+		}
+	} else if clientTenant != "" || host != "" {
+		directory, err := r.directory.Directory()
+		if err != nil {
+			return "", fmt.Errorf("loading tenants: %w", err)
+		}
+		if clientTenant != "" {
+			if _, ok := directory.Lookup(clientTenant); !ok {
+				return "", ErrUnresolved
+			}
+			return clientTenant, nil
+		}
+		if tenantID, ok := directory.ForHost(host); ok {
+			return tenantID, nil
+		}
+	}
+
+	if r.fallback == "" {
+		return "", ErrUnresolved
+	}
+	return r.fallback, nil
+}
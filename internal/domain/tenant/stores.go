@@ -0,0 +1,88 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownTenant is returned for a tenant that is not in the directory
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// Stores keeps one store per tenant, such as a targeting snapshot store, creating each on first use so
+// that tenants without traffic cost nothing
+type Stores[S any] struct {
+	directory *Store
+	newStore  func(tenantID string) S
+	stores    map[string]S
+	mutex     sync.RWMutex
+}
+
+// NewStores creates stores with newStore, which is called at most once per tenant. With a directory,
+// stores are only created for its tenants, so that a bad tenant ID cannot start a store and its refreshes;
+// directory may be nil to accept any tenant.
+func NewStores[S any](directory *Store, newStore func(tenantID string) S) *Stores[S] {
+	return &Stores[S]{
+		directory: directory,
+		newStore:  newStore,
+		stores:    make(map[string]S),
+	}
+}
+
+// Shared serves every tenant from the same store. It is meant for tests and single-tenant setups. The
+// default tenant's entry exists from the start, so that Each visits the store.
+func Shared[S any](store S) *Stores[S] {
+	stores := NewStores(nil, func(string) S { return store })
+	stores.Get(DefaultID)
+	return stores
+}
+
+// Get returns the store of a tenant, creating it on first use. Tenants missing from the directory get
+// ErrUnknownTenant.
+func (s *Stores[S]) Get(tenantID string) (S, error) {
+	s.mutex.RLock()
+	store, ok := s.stores[tenantID]
+	s.mutex.RUnlock()
+	if ok {
+		return store, nil
+	}
+
+	if s.directory != nil {
+		directory, err := s.directory.Directory()
+		if err != nil {
+			return store, fmt.Errorf("loading tenants: %w", err)
+		}
+		if _, ok := directory.Lookup(tenantID); !ok {
+			return store, fmt.Errorf("%w: %q", ErrUnknownTenant, tenantID)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Another caller may have created it while we waited for the lock
+	if store, ok := s.stores[tenantID]; ok {
+		return store, nil
+	}
+	store = s.newStore(tenantID)
+	s.stores[tenantID] = store
+	return store, nil
+}
+
+// Each calls fn with every store created so far, in tenant order
+func (s *Stores[S]) Each(fn func(tenantID string, store S)) {
+	s.mutex.RLock()
+	stores := maps.Clone(s.stores)
+	s.mutex.RUnlock()
+
+	ids := make([]string, 0, len(stores))
+	for id := range stores {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fn(id, stores[id])
+	}
+}
@@ -0,0 +1,136 @@
+package tenant
+
+import (
+	"campaign/internal/domain/models"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, DefaultID, FromContext(context.Background()))
+	assert.Equal(t, "games", FromContext(NewContext(context.Background(), "games")))
+}
+
+func TestDirectory(t *testing.T) {
+	directory := NewDirectory([]models.Tenant{
+		{TenantID: "default", Name: "Default"},
+		{TenantID: "games", Name: "Games", Hosts: []string{"ads.games.example.com", "Games.Example.NET"}},
+	})
+
+	for host, want := range map[string]string{
+		"ads.games.example.com":      "games",
+		"ads.games.example.com:8080": "games",
+		"games.example.net.":         "games",
+		"GAMES.example.net":          "games",
+	} {
+		tenantID, ok := directory.ForHost(host)
+		assert.True(t, ok, host)
+		assert.Equal(t, want, tenantID, host)
+	}
+
+	_, ok := directory.ForHost("news.example.com")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"default", "games"}, directory.IDs())
+	games, ok := directory.Lookup("games")
+	require.True(t, ok)
+	assert.Equal(t, "Games", games.Name)
+}
+
+func TestResolver(t *testing.T) {
+	var loadErr error
+	loader := func() ([]models.Tenant, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return []models.Tenant{{TenantID: "games", Hosts: []string{"games.example.com"}}, {TenantID: "news"}}, nil
+	}
+	store := NewStore(slog.Default(), loader)
+
+	withFallback := NewResolver(store, DefaultID)
+	strict := NewResolver(store, "")
+
+	// The API client's tenant wins over the host
+	tenantID, err := strict.Resolve("news", "games.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "news", tenantID)
+
+	tenantID, err = strict.Resolve("", "games.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "games", tenantID)
+
+	tenantID, err = withFallback.Resolve("", "unknown.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultID, tenantID)
+
+	_, err = strict.Resolve("", "unknown.example.com")
+	assert.ErrorIs(t, err, ErrUnresolved)
+	_, err = withFallback.WithoutFallback().Resolve("", "unknown.example.com")
+	assert.ErrorIs(t, err, ErrUnresolved)
+
+	// Credentials naming a tenant missing from the directory resolve to nothing, not even the fallback
+	_, err = withFallback.Resolve("gmaes", "games.example.com")
+	assert.ErrorIs(t, err, ErrUnresolved)
+
+	// While the directory cannot be loaded, hosts fail to resolve rather than falling back
+	loadErr = errors.New("database down")
+	_, err = NewResolver(NewStore(slog.Default(), loader), DefaultID).Resolve("", "games.example.com")
+	assert.ErrorIs(t, err, loadErr)
+}
+
+func TestStores(t *testing.T) {
+	created := map[string]int{}
+	stores := NewStores(nil, func(tenantID string) *string {
+		created[tenantID]++
+		return &tenantID
+	})
+
+	games, err := stores.Get("games")
+	require.NoError(t, err)
+	assert.Equal(t, "games", *games)
+	again, _ := stores.Get("games")
+	assert.Same(t, games, again)
+	stores.Get("default")
+	assert.Equal(t, map[string]int{"games": 1, "default": 1}, created)
+
+	var visited []string
+	stores.Each(func(tenantID string, store *string) {
+		visited = append(visited, tenantID)
+		assert.Equal(t, tenantID, *store)
+	})
+	assert.Equal(t, []string{"default", "games"}, visited)
+
+	// A shared store serves every tenant and is visited from the start
+	shared := "shared"
+	sharedStores := Shared(&shared)
+	news, err := sharedStores.Get("news")
+	require.NoError(t, err)
+	assert.Same(t, &shared, news)
+	visited = nil
+	sharedStores.Each(func(tenantID string, _ *string) { visited = append(visited, tenantID) })
+	assert.Contains(t, visited, DefaultID)
+}
+
+func TestStores_OnlyDirectoryTenants(t *testing.T) {
+	directory := NewStore(slog.Default(), func() ([]models.Tenant, error) {
+		return []models.Tenant{{TenantID: "games"}}, nil
+	})
+	created := map[string]int{}
+	stores := NewStores(directory, func(tenantID string) *string {
+		created[tenantID]++
+		return &tenantID
+	})
+
+	_, err := stores.Get("games")
+	require.NoError(t, err)
+
+	// Unknown tenants get no store, and so no refreshes
+	_, err = stores.Get("gmaes")
+	assert.ErrorIs(t, err, ErrUnknownTenant)
+	assert.Equal(t, map[string]int{"games": 1}, created)
+}
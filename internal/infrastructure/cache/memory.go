@@ -1,21 +1,26 @@
 package cache
 
 import (
+	"strings"
 	"sync"
 	"time"
 )
 
 type CacheItem struct {
-	Data      []byte
+	Data []byte
+	// Meta is stored next to Data for callers that need facts about it without decoding it, such as the
+	// campaign IDs of a cached response
+	Meta      []string
 	ExpiresAt time.Time
 	CreatedAt time.Time
 }
 
 type MemoryCache struct {
-	store       sync.Map
-	maxSize     int
-	currentSize int
-	mutex       sync.RWMutex
+	store        sync.Map
+	maxSize      int
+	currentSize  int
+	currentBytes int64
+	mutex        sync.RWMutex
 }
 
 func NewMemoryCache() *MemoryCache {
@@ -41,23 +46,30 @@ func NewMemoryCacheWithSize(maxSize int) *MemoryCache {
 }
 
 func (mc *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	mc.SetWithMeta(key, value, nil, ttl)
+}
+
+// SetWithMeta is like Set but also stores meta next to the value, returned by GetWithMeta
+func (mc *MemoryCache) SetWithMeta(key string, value []byte, meta []string, ttl time.Duration) {
 	mc.mutex.Lock()
 	defer mc.mutex.Unlock()
 
-	// Check if we need to evict items
-	if mc.currentSize >= mc.maxSize {
-		mc.evictOldest()
-	}
-
 	expiresAt := time.Now().Add(ttl)
 
-	// Check if key already exists
-	if _, exists := mc.store.Load(key); !exists {
+	// Check if key already exists; only new keys need room
+	if existing, exists := mc.store.Load(key); !exists {
+		if mc.currentSize >= mc.maxSize {
+			mc.evictOldest()
+		}
 		mc.currentSize++
+	} else if item, ok := existing.(CacheItem); ok {
+		mc.currentBytes -= int64(len(item.Data))
 	}
+	mc.currentBytes += int64(len(value))
 
 	mc.store.Store(key, CacheItem{
 		Data:      value,
+		Meta:      meta,
 		ExpiresAt: expiresAt,
 		CreatedAt: time.Now(),
 	})
@@ -74,27 +86,52 @@ func (mc *MemoryCache) Get(key string) ([]byte, bool) {
 	cacheItem, ok := item.(CacheItem)
 
 	if !ok {
-		mc.store.Delete(key) //Clean up malformed entry
+		mc.removeExpired(key) //Clean up malformed entry
 		return nil, false
 	}
 
 	if time.Now().After(cacheItem.ExpiresAt) {
-		mc.store.Delete(key)
+		mc.removeExpired(key)
 		return nil, false
 	}
 
 	return cacheItem.Data, true
 }
 
+// GetWithTTL is like Get but also returns how long the entry stays cached
+func (mc *MemoryCache) GetWithTTL(key string) ([]byte, time.Duration, bool) {
+	data, _, remaining, found := mc.GetWithMeta(key)
+	return data, remaining, found
+}
+
+// GetWithMeta is like GetWithTTL but also returns the meta stored with SetWithMeta
+func (mc *MemoryCache) GetWithMeta(key string) ([]byte, []string, time.Duration, bool) {
+	item, ok := mc.store.Load(key)
+	if !ok {
+		return nil, nil, 0, false
+	}
+
+	cacheItem, ok := item.(CacheItem)
+	if !ok {
+		mc.removeExpired(key)
+		return nil, nil, 0, false
+	}
+
+	remaining := time.Until(cacheItem.ExpiresAt)
+	if remaining <= 0 {
+		mc.removeExpired(key)
+		return nil, nil, 0, false
+	}
+
+	return cacheItem.Data, cacheItem.Meta, remaining, true
+}
+
 // Delete removes item from the cache
 func (mc *MemoryCache) Delete(key string) {
 	mc.mutex.Lock()
 	defer mc.mutex.Unlock()
 
-	if _, exists := mc.store.Load(key); exists {
-		mc.currentSize--
-	}
-	mc.store.Delete(key)
+	mc.remove(key)
 }
 
 // Size returns the current number of items in cache
@@ -104,13 +141,38 @@ func (mc *MemoryCache) Size() int {
 	return mc.currentSize
 }
 
+// Bytes returns the total size of the cached values
+func (mc *MemoryCache) Bytes() int64 {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+	return mc.currentBytes
+}
+
 // Clear removes all items from cache
 func (mc *MemoryCache) Clear() {
 	mc.mutex.Lock()
 	defer mc.mutex.Unlock()
 
-	mc.store = sync.Map{}
+	// Delete in place rather than replacing the map, since Get reads the store without the mutex
+	mc.store.Range(func(key, _ interface{}) bool {
+		mc.store.Delete(key)
+		return true
+	})
 	mc.currentSize = 0
+	mc.currentBytes = 0
+}
+
+// DeletePrefix removes every item whose key starts with prefix, e.g. all responses of one tenant
+func (mc *MemoryCache) DeletePrefix(prefix string) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.store.Range(func(key, _ interface{}) bool {
+		if k := key.(string); strings.HasPrefix(k, prefix) {
+			mc.remove(k)
+		}
+		return true
+	})
 }
 
 func (mc *MemoryCache) evictOldest() {
@@ -130,8 +192,33 @@ func (mc *MemoryCache) evictOldest() {
 	})
 
 	if found {
-		mc.store.Delete(oldestKey)
-		mc.currentSize--
+		mc.remove(oldestKey)
+	}
+}
+
+// removeExpired removes an entry found expired or malformed by a lock-free read, unless it has been
+// replaced in the meantime
+func (mc *MemoryCache) removeExpired(key string) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if item, ok := mc.store.Load(key); ok {
+		if cacheItem, valid := item.(CacheItem); valid && !time.Now().After(cacheItem.ExpiresAt) {
+			return
+		}
+		mc.remove(key)
+	}
+}
+
+// remove deletes an entry and updates the size counters; the caller holds the mutex
+func (mc *MemoryCache) remove(key string) {
+	item, exists := mc.store.LoadAndDelete(key)
+	if !exists {
+		return
+	}
+	mc.currentSize--
+	if cacheItem, ok := item.(CacheItem); ok {
+		mc.currentBytes -= int64(len(cacheItem.Data))
 	}
 }
 
@@ -144,8 +231,7 @@ func (mc *MemoryCache) cleanupExpired() {
 		mc.store.Range(func(key, value interface{}) bool {
 			if item, ok := value.(CacheItem); ok {
 				if time.Now().After(item.ExpiresAt) {
-					mc.store.Delete(key)
-					mc.currentSize--
+					mc.remove(key.(string))
 				}
 			}
 			return true
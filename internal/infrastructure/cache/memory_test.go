@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_SizeAndBytes(t *testing.T) {
+	mc := NewMemoryCacheWithSize(2)
+
+	mc.Set("a", []byte("1234"), time.Minute)
+	mc.Set("b", []byte("12"), time.Minute)
+	assert.Equal(t, 2, mc.Size())
+	assert.Equal(t, int64(6), mc.Bytes())
+
+	// Replacing a value counts only the new bytes
+	mc.Set("b", []byte("123456"), time.Minute)
+	assert.Equal(t, 2, mc.Size())
+	assert.Equal(t, int64(10), mc.Bytes())
+
+	// A full cache evicts the oldest entry
+	mc.Set("c", []byte("1"), time.Minute)
+	assert.Equal(t, 2, mc.Size())
+	assert.Equal(t, int64(7), mc.Bytes())
+
+	// Expired entries are removed when read
+	mc.Set("c", []byte("1"), -time.Second)
+	_, found := mc.Get("c")
+	assert.False(t, found)
+	assert.Equal(t, 1, mc.Size())
+	assert.Equal(t, int64(6), mc.Bytes())
+
+	mc.Delete("b")
+	assert.Equal(t, 0, mc.Size())
+	assert.Equal(t, int64(0), mc.Bytes())
+}
+
+func TestMemoryCache_DeletePrefix(t *testing.T) {
+	mc := NewMemoryCache()
+
+	mc.Set("tenant:games:delivery:a", []byte("12"), time.Minute)
+	mc.Set("tenant:games:dimensions", []byte("3"), time.Minute)
+	mc.Set("tenant:news:delivery:a", []byte("456"), time.Minute)
+
+	mc.DeletePrefix("tenant:games:")
+	assert.Equal(t, 1, mc.Size())
+	assert.Equal(t, int64(3), mc.Bytes())
+
+	_, found := mc.Get("tenant:news:delivery:a")
+	assert.True(t, found)
+}
+
+func TestMemoryCache_Meta(t *testing.T) {
+	mc := NewMemoryCache()
+
+	mc.SetWithMeta("a", []byte("12"), []string{"c1", "c2"}, time.Minute)
+	data, meta, ttl, found := mc.GetWithMeta("a")
+	assert.True(t, found)
+	assert.Equal(t, []byte("12"), data)
+	assert.Equal(t, []string{"c1", "c2"}, meta)
+	assert.Greater(t, ttl, time.Duration(0))
+
+	mc.Set("b", []byte("3"), time.Minute)
+	_, meta, _, found = mc.GetWithMeta("b")
+	assert.True(t, found)
+	assert.Nil(t, meta)
+}
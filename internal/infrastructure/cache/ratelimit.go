@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"campaign/pkg/utils"
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeTokenScript refills and takes from a token bucket atomically, on the Redis clock so that instances
+// with skewed clocks agree. Buckets expire once they would be full again, as a missing bucket starts full.
+// Returns whether a token was taken and the tokens left, as a string since Lua numbers become integers.
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000))
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateLimitStore keeps token buckets in Redis, so that every instance shares the same limits
+type RedisRateLimitStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisRateLimitStore stores buckets under keys starting with prefix
+func NewRedisRateLimitStore(client redis.Scripter, prefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Take takes one token from the bucket of key, if there is one
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, limit utils.RateLimit) (utils.RateLimitResult, error) {
+	values, err := takeTokenScript.Run(ctx, s.client, []string{s.prefix + key}, limit.Rate, limit.Burst).Slice()
+	if err != nil {
+		return utils.RateLimitResult{}, err
+	}
+
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		return utils.RateLimitResult{}, err
+	}
+	return utils.NewRateLimitResult(limit, tokens, allowed == 1), nil
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("campaign/internal/infrastructure/cache")
+
+// GetContext is Get recorded as a child span of the request in ctx
+func (mc *MemoryCache) GetContext(ctx context.Context, key string) ([]byte, bool) {
+	_, span := startSpan(ctx, "MemoryCache.Get", key)
+	defer span.End()
+
+	data, found := mc.Get(key)
+	span.SetAttributes(attribute.Bool("cache.hit", found))
+	return data, found
+}
+
+// GetWithTTLContext is GetWithTTL recorded as a child span of the request in ctx
+func (mc *MemoryCache) GetWithTTLContext(ctx context.Context, key string) ([]byte, time.Duration, bool) {
+	_, span := startSpan(ctx, "MemoryCache.Get", key)
+	defer span.End()
+
+	data, ttl, found := mc.GetWithTTL(key)
+	span.SetAttributes(attribute.Bool("cache.hit", found))
+	return data, ttl, found
+}
+
+// GetWithMetaContext is GetWithMeta recorded as a child span of the request in ctx
+func (mc *MemoryCache) GetWithMetaContext(ctx context.Context, key string) ([]byte, []string, time.Duration, bool) {
+	_, span := startSpan(ctx, "MemoryCache.Get", key)
+	defer span.End()
+
+	data, meta, ttl, found := mc.GetWithMeta(key)
+	span.SetAttributes(attribute.Bool("cache.hit", found))
+	return data, meta, ttl, found
+}
+
+// SetContext is Set recorded as a child span of the request in ctx
+func (mc *MemoryCache) SetContext(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	_, span := startSpan(ctx, "MemoryCache.Set", key)
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("cache.value_bytes", len(value)))
+	mc.Set(key, value, ttl)
+}
+
+// SetWithMetaContext is SetWithMeta recorded as a child span of the request in ctx
+func (mc *MemoryCache) SetWithMetaContext(ctx context.Context, key string, value []byte, meta []string, ttl time.Duration) {
+	_, span := startSpan(ctx, "MemoryCache.Set", key)
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("cache.value_bytes", len(value)))
+	mc.SetWithMeta(key, value, meta, ttl)
+}
+
+func startSpan(ctx context.Context, name, key string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("cache.key", key)),
+	)
+}
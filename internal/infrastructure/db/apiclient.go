@@ -0,0 +1,234 @@
+package db
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/pkg/utils"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CreateAPIClient inserts a new API client of a tenant. Client IDs are unique across tenants, since a key
+// identifies its tenant. Returns an error wrapping ErrAlreadyExists if the ID is taken.
+func CreateAPIClient(ctx context.Context, db *sql.DB, client models.APIClient) error {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("CreateAPIClient"))
+	defer timer.ObserveDuration()
+
+	query := `
+		INSERT INTO api_clients (client_id, tenant_id, name, scopes, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (client_id) DO NOTHING;
+	`
+
+	ctx, span := startSpan(ctx, "CreateAPIClient", query)
+	defer span.End()
+
+	result, err := db.ExecContext(ctx, query, client.ClientID, client.TenantID, client.Name, pq.Array(client.Scopes), client.Enabled)
+	if err != nil {
+		queryError(ctx, "db query failed", "CreateAPIClient", err)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("api client %s: %w", client.ClientID, ErrAlreadyExists)
+	}
+
+	return nil
+}
+
+// SetAPIClientEnabled enables or disables every key of a client. Returns sql.ErrNoRows if the client
+// does not exist.
+func SetAPIClientEnabled(ctx context.Context, db *sql.DB, clientID string, enabled bool) error {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("SetAPIClientEnabled"))
+	defer timer.ObserveDuration()
+
+	query := `
+		UPDATE api_clients
+		SET enabled = $2,
+		    udate = NOW()
+		WHERE client_id = $1;
+	`
+
+	ctx, span := startSpan(ctx, "SetAPIClientEnabled", query)
+	defer span.End()
+
+	result, err := db.ExecContext(ctx, query, clientID, enabled)
+	if err != nil {
+		queryError(ctx, "db query failed", "SetAPIClientEnabled", err)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// RotateAPIKey adds a key to a client and, with expireOthersAt set, makes the client's other keys that
+// would outlive it expire then, so that old and new key overlap until callers have switched.
+// Returns sql.ErrNoRows if the client does not exist.
+func RotateAPIKey(ctx context.Context, db *sql.DB, key models.APIKey, expireOthersAt *time.Time) error {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("RotateAPIKey"))
+	defer timer.ObserveDuration()
+
+	insert := `
+		INSERT INTO api_client_keys (key_id, client_id, key_hash, not_before, expires_at)
+		SELECT $1, client_id, $3, $4, $5
+		FROM api_clients
+		WHERE client_id = $2;
+	`
+	expire := `
+		UPDATE api_client_keys
+		SET expires_at = GREATEST($3, not_before + interval '1 microsecond')
+		WHERE client_id = $1
+		  AND key_id <> $2
+		  AND (expires_at IS NULL OR expires_at > $3);
+	`
+
+	ctx, span := startSpan(ctx, "RotateAPIKey", insert)
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		queryError(ctx, "error starting transaction", "RotateAPIKey", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, insert, key.KeyID, key.ClientID, key.KeyHash, key.NotBefore, key.ExpiresAt)
+	if err != nil {
+		queryError(ctx, "db query failed", "RotateAPIKey", err)
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if expireOthersAt != nil {
+		if _, err := tx.ExecContext(ctx, expire, key.ClientID, key.KeyID, *expireOthersAt); err != nil {
+			queryError(ctx, "db query failed", "RotateAPIKey", err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		queryError(ctx, "error committing transaction", "RotateAPIKey", err)
+		return err
+	}
+	return nil
+}
+
+// RevokeAPIKey expires a key immediately. Returns sql.ErrNoRows if the key does not exist.
+func RevokeAPIKey(ctx context.Context, db *sql.DB, keyID string) error {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("RevokeAPIKey"))
+	defer timer.ObserveDuration()
+
+	query := `
+		UPDATE api_client_keys
+		SET not_before = LEAST(not_before, NOW() - interval '1 microsecond'),
+		    expires_at = NOW()
+		WHERE key_id = $1;
+	`
+
+	ctx, span := startSpan(ctx, "RevokeAPIKey", query)
+	defer span.End()
+
+	result, err := db.ExecContext(ctx, query, keyID)
+	if err != nil {
+		queryError(ctx, "db query failed", "RevokeAPIKey", err)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// LoadAPIKeys returns every API client and every key that has not expired yet, across all tenants, since
+// the key of a request is what resolves its tenant
+func LoadAPIKeys(ctx context.Context, db *sql.DB) ([]models.APIClient, []models.APIKey, error) {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("LoadAPIKeys"))
+	defer timer.ObserveDuration()
+
+	clientsQuery := `
+		SELECT client_id, tenant_id, name, scopes, enabled
+		FROM api_clients;
+	`
+	keysQuery := `
+		SELECT key_id, client_id, key_hash, not_before, expires_at
+		FROM api_client_keys
+		WHERE expires_at IS NULL OR expires_at > NOW();
+	`
+
+	ctx, span := startSpan(ctx, "LoadAPIKeys", keysQuery)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, clientsQuery)
+	if err != nil {
+		queryError(ctx, "db query failed", "LoadAPIKeys", err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var clients []models.APIClient
+	for rows.Next() {
+		var client models.APIClient
+		if err := rows.Scan(&client.ClientID, &client.TenantID, &client.Name, pq.Array(&client.Scopes), &client.Enabled); err != nil {
+			queryError(ctx, "error scanning row", "LoadAPIKeys", err)
+			return nil, nil, err
+		}
+		clients = append(clients, client)
+	}
+	if err := rows.Err(); err != nil {
+		queryError(ctx, "error iterating rows", "LoadAPIKeys", err)
+		return nil, nil, err
+	}
+
+	rows, err = db.QueryContext(ctx, keysQuery)
+	if err != nil {
+		queryError(ctx, "db query failed", "LoadAPIKeys", err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var key models.APIKey
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&key.KeyID, &key.ClientID, &key.KeyHash, &key.NotBefore, &expiresAt); err != nil {
+			queryError(ctx, "error scanning row", "LoadAPIKeys", err)
+			return nil, nil, err
+		}
+		key.ExpiresAt = nullTimePtr(expiresAt)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		queryError(ctx, "error iterating rows", "LoadAPIKeys", err)
+		return nil, nil, err
+	}
+
+	return clients, keys, nil
+}
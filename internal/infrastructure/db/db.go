@@ -2,11 +2,14 @@ package db
 
 import (
 	"campaign/internal/domain/models"
+	"campaign/internal/domain/targeting"
+	"campaign/pkg/logging"
 	"campaign/pkg/utils"
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
-	"strings"
+	"log/slog"
+	"time"
 
 	_ "github.com/lib/pq" // PostgreSQL driver
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,47 +19,38 @@ func Connect(connStr string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", connStr)
 
 	if err != nil {
-		log.Printf("failed to open DB connection: %v", err)
+		slog.Error("failed to open DB connection", "error", err)
 		return nil, err
 	}
 	if err = db.Ping(); err != nil {
-		log.Printf("DB not reachable: %v", err)
+		slog.Error("DB not reachable", "error", err)
 		return nil, err
 	}
 
-	log.Println("DB connection established successfully")
+	slog.Info("DB connection established")
 	return db, nil
 }
 
-// TargetingDimension represents a targeting dimension with its value
-type TargetingDimension struct {
-	Dimension string
-	Value     string
-}
-
-// GetTargetedCampaignsDynamic is a scalable version that supports any number of targeting dimensions
-func GetTargetedCampaignsDynamic(db *sql.DB, dimensions []TargetingDimension, limit int, offset int) ([]models.Campaign, error) {
-	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("GetTargetedCampaignsDynamic"))
+// GetActiveCampaigns returns all active campaigns of a tenant ordered by campaign_id
+func GetActiveCampaigns(ctx context.Context, db *sql.DB, tenantID string) ([]models.Campaign, error) {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("GetActiveCampaigns"))
 	defer timer.ObserveDuration()
 
-	if limit <= 0 {
-		limit = 10
-	}
-	if offset < 0 {
-		offset = 0
-	}
-
-	if len(dimensions) == 0 {
-		// If no dimensions provided, return all active campaigns
-		return getAllActiveCampaigns(db, limit, offset)
-	}
+	query := `
+		SELECT campaign_id, campaign_name, image_url, call_to_action, campaign_status,
+		       COALESCE(targeting_expression::text, ''), start_time, end_time
+		FROM campaigns
+		WHERE tenant_id = $1
+		  AND campaign_status = 'ACTIVE'
+		ORDER BY campaign_id;
+	`
 
-	// Build dynamic query
-	query, args := buildDynamicTargetingQuery(dimensions, limit, offset)
+	ctx, span := startSpan(ctx, "GetActiveCampaigns", query)
+	defer span.End()
 
-	rows, err := db.Query(query, args...)
+	rows, err := db.QueryContext(ctx, query, tenantID)
 	if err != nil {
-		log.Printf("DB query failed for GetTargetedCampaignsDynamic: %v\nQuery: %s\n Args:%v", err, query, args)
+		queryError(ctx, "db query failed", "GetActiveCampaigns", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -64,137 +58,206 @@ func GetTargetedCampaignsDynamic(db *sql.DB, dimensions []TargetingDimension, li
 	var campaigns []models.Campaign
 	for rows.Next() {
 		var campaign models.Campaign
-		err := rows.Scan(&campaign.CampaignID, &campaign.CampaignName, &campaign.ImageURL, &campaign.CallToAction)
+		var startTime, endTime sql.NullTime
+		err := rows.Scan(&campaign.CampaignID, &campaign.CampaignName, &campaign.ImageURL, &campaign.CallToAction, &campaign.CampaignStatus,
+			&campaign.TargetingExpression, &startTime, &endTime)
 		if err != nil {
-			log.Printf("Error scanning campaign row: %v", err)
+			queryError(ctx, "error scanning row", "GetActiveCampaigns", err)
 			return nil, err
 		}
+		campaign.StartTime, campaign.EndTime = nullTimePtr(startTime), nullTimePtr(endTime)
 		campaigns = append(campaigns, campaign)
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Printf("Error after iterating campaign row: %v", err)
+		queryError(ctx, "error iterating rows", "GetActiveCampaigns", err)
 		return nil, err
 	}
 
-	// Log dimensions for debugging
-	dimStrs := make([]string, len(dimensions))
-	for i, dim := range dimensions {
-		dimStrs[i] = fmt.Sprintf("%s=%s", dim.Dimension, dim.Value)
+	logging.FromContext(ctx).Debug("active campaigns loaded", "count", len(campaigns))
+	return campaigns, nil
+}
+
+// GetCampaign returns a single campaign of a tenant regardless of status, or sql.ErrNoRows if the tenant
+// has no such campaign
+func GetCampaign(ctx context.Context, db *sql.DB, tenantID, campaignID string) (*models.Campaign, error) {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("GetCampaign"))
+	defer timer.ObserveDuration()
+
+	query := `
+		SELECT campaign_id, campaign_name, image_url, call_to_action, campaign_status,
+		       COALESCE(targeting_expression::text, ''), start_time, end_time
+		FROM campaigns
+		WHERE tenant_id = $1
+		  AND campaign_id = $2;
+	`
+
+	ctx, span := startSpan(ctx, "GetCampaign", query)
+	defer span.End()
+
+	var campaign models.Campaign
+	var startTime, endTime sql.NullTime
+	err := db.QueryRowContext(ctx, query, tenantID, campaignID).Scan(&campaign.CampaignID, &campaign.CampaignName, &campaign.ImageURL,
+		&campaign.CallToAction, &campaign.CampaignStatus, &campaign.TargetingExpression, &startTime, &endTime)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			queryError(ctx, "db query failed", "GetCampaign", err)
+		}
+		return nil, err
 	}
-	log.Printf("GetTargetedCampaign found %d campaigns for dimensions: %s", len(campaigns), strings.Join(dimStrs, ", "))
+	campaign.StartTime, campaign.EndTime = nullTimePtr(startTime), nullTimePtr(endTime)
 
-	return campaigns, nil
+	return &campaign, nil
 }
 
-// GetTargetedCampaigns is the original function for backward compatibility
-func GetTargetedCampaigns(db *sql.DB, appID, country, os string, limit int, offset int) ([]models.Campaign, error) {
-	dimensions := []TargetingDimension{
-		{Dimension: "app_id", Value: appID},
-		{Dimension: "country", Value: country},
-		{Dimension: "os", Value: os},
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
 	}
-	return GetTargetedCampaignsDynamic(db, dimensions, limit, offset)
+	return &t.Time
 }
 
-// buildDynamicTargetingQuery builds a dynamic SQL query based on provided dimensions
-func buildDynamicTargetingQuery(dimensions []TargetingDimension, limit, offset int) (string, []interface{}) {
-	var cteParts []string
-	var joinParts []string
-	var whereParts []string
-	var args []interface{}
-	argIndex := 1
-
-	// Build CTEs for each dimension
-	for _, dim := range dimensions {
-		cteName := fmt.Sprintf("%s_targeting", dim.Dimension)
-		cteParts = append(cteParts, fmt.Sprintf(`
-		%s AS (
-			SELECT campaign_id, 
-				   bool_or(type = 'include' AND value = $%d) as has_include,
-				   bool_or(type = 'exclude' AND value = $%d) as has_exclude,
-				   count(*) FILTER (WHERE type = 'include') as include_count
-			FROM targeting_rules 
-			WHERE dimension = '%s' 
-			GROUP BY campaign_id
-		)`, cteName, argIndex, argIndex, dim.Dimension))
+// UpdateCampaignTargetingExpression stores a validated targeting expression for a campaign; an empty
+// expression removes it. Returns sql.ErrNoRows if the tenant has no such campaign.
+func UpdateCampaignTargetingExpression(ctx context.Context, db *sql.DB, tenantID, campaignID string, expression string) error {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("UpdateCampaignTargetingExpression"))
+	defer timer.ObserveDuration()
 
-		joinParts = append(joinParts, fmt.Sprintf("LEFT JOIN %s %s ON c.campaign_id = %s.campaign_id",
-			cteName, cteName, cteName))
+	query := `
+		UPDATE campaigns
+		SET targeting_expression = NULLIF($3, '')::jsonb,
+		    udate = NOW()
+		WHERE tenant_id = $1
+		  AND campaign_id = $2;
+	`
 
-		whereParts = append(whereParts, fmt.Sprintf("(%s.campaign_id IS NULL OR (%s.include_count = 0 OR %s.has_include) AND NOT %s.has_exclude)",
-			cteName, cteName, cteName, cteName))
+	ctx, span := startSpan(ctx, "UpdateCampaignTargetingExpression", query)
+	defer span.End()
 
-		args = append(args, dim.Value)
-		argIndex++
+	result, err := db.ExecContext(ctx, query, tenantID, campaignID, expression)
+	if err != nil {
+		queryError(ctx, "db query failed", "UpdateCampaignTargetingExpression", err)
+		return err
 	}
 
-	// Add limit and offset
-	args = append(args, limit, offset)
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// UpdateCampaignStatus sets a campaign ACTIVE or INACTIVE. Returns sql.ErrNoRows if the tenant has no such
+// campaign.
+func UpdateCampaignStatus(ctx context.Context, db *sql.DB, tenantID, campaignID string, status string) error {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("UpdateCampaignStatus"))
+	defer timer.ObserveDuration()
+
+	query := `
+		UPDATE campaigns
+		SET campaign_status = $3,
+		    udate = NOW()
+		WHERE tenant_id = $1
+		  AND campaign_id = $2;
+	`
 
-	// Build the complete query
-	query := fmt.Sprintf(`
-		WITH %s
-		SELECT DISTINCT c.campaign_id, c.campaign_name, c.image_url, c.call_to_action
-		FROM campaigns c
-		%s
-		WHERE c.campaign_status = 'ACTIVE'
-		  AND %s
-		ORDER BY c.campaign_id
-		LIMIT $%d OFFSET $%d;
-	`, strings.Join(cteParts, ","), strings.Join(joinParts, "\n\t\t"), strings.Join(whereParts, "\n\t\t  AND "), argIndex, argIndex+1)
+	ctx, span := startSpan(ctx, "UpdateCampaignStatus", query)
+	defer span.End()
+
+	result, err := db.ExecContext(ctx, query, tenantID, campaignID, status)
+	if err != nil {
+		queryError(ctx, "db query failed", "UpdateCampaignStatus", err)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
 
-	return query, args
+	return nil
 }
 
-// getAllActiveCampaigns returns all active campaigns when no targeting dimensions are provided
-func getAllActiveCampaigns(db *sql.DB, limit, offset int) ([]models.Campaign, error) {
+// GetActiveTargetingRules returns the targeting rules of all active campaigns of a tenant
+func GetActiveTargetingRules(ctx context.Context, db *sql.DB, tenantID string) ([]models.TargetingRule, error) {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("GetActiveTargetingRules"))
+	defer timer.ObserveDuration()
+
 	query := `
-		SELECT campaign_id, campaign_name, image_url, call_to_action
-		FROM campaigns
-		WHERE campaign_status = 'ACTIVE'
-		ORDER BY campaign_id
-		LIMIT $1 OFFSET $2;
+		SELECT t.campaign_id, t.dimension, t.type, t.operator, t.value
+		FROM targeting_rules t
+		JOIN campaigns c ON c.tenant_id = t.tenant_id AND c.campaign_id = t.campaign_id
+		WHERE t.tenant_id = $1
+		  AND c.campaign_status = 'ACTIVE'
+		ORDER BY t.campaign_id, t.dimension;
 	`
 
-	rows, err := db.Query(query, limit, offset)
+	ctx, span := startSpan(ctx, "GetActiveTargetingRules", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, tenantID)
 	if err != nil {
-		log.Printf("DB query failed for getAllActiveCampaigns: %v", err)
+		queryError(ctx, "db query failed", "GetActiveTargetingRules", err)
 		return nil, err
 	}
 	defer rows.Close()
 
-	var campaigns []models.Campaign
+	var rules []models.TargetingRule
 	for rows.Next() {
-		var campaign models.Campaign
-		err := rows.Scan(&campaign.CampaignID, &campaign.CampaignName, &campaign.ImageURL, &campaign.CallToAction)
+		var rule models.TargetingRule
+		err := rows.Scan(&rule.CampaignID, &rule.Dimension, &rule.Type, &rule.Operator, &rule.Value)
 		if err != nil {
-			log.Printf("Error scanning campaign row: %v", err)
+			queryError(ctx, "error scanning row", "GetActiveTargetingRules", err)
 			return nil, err
 		}
-		campaigns = append(campaigns, campaign)
+		rules = append(rules, rule)
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Printf("Error after iterating campaign row: %v", err)
+		queryError(ctx, "error iterating rows", "GetActiveTargetingRules", err)
 		return nil, err
 	}
 
-	log.Printf("GetAllActiveCampaigns found %d campaigns", len(campaigns))
-	return campaigns, nil
+	return rules, nil
 }
 
-// GetAvailableDimensions returns all available targeting dimensions from the database
-func GetAvailableDimensions(db *sql.DB) ([]string, error) {
+// LoadTargetingData loads the active campaigns of a tenant and their rules for a targeting snapshot
+func LoadTargetingData(ctx context.Context, db *sql.DB, tenantID string) ([]models.Campaign, []models.TargetingRule, error) {
+	campaigns, err := GetActiveCampaigns(ctx, db, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rules, err := GetActiveTargetingRules(ctx, db, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return campaigns, rules, nil
+}
+
+// GetAvailableDimensions returns all targeting dimensions used by a tenant's rules
+func GetAvailableDimensions(ctx context.Context, db *sql.DB, tenantID string) ([]string, error) {
 	query := `
 		SELECT DISTINCT dimension 
 		FROM targeting_rules 
+		WHERE tenant_id = $1
 		ORDER BY dimension;
 	`
 
-	rows, err := db.Query(query)
+	ctx, span := startSpan(ctx, "GetAvailableDimensions", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, tenantID)
 	if err != nil {
-		log.Printf("DB query failed for GetAvailableDimensions: %v", err)
+		queryError(ctx, "db query failed", "GetAvailableDimensions", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -204,32 +267,44 @@ func GetAvailableDimensions(db *sql.DB) ([]string, error) {
 		var dimension string
 		err := rows.Scan(&dimension)
 		if err != nil {
-			log.Printf("Error scanning dimension row: %v", err)
+			queryError(ctx, "error scanning row", "GetAvailableDimensions", err)
 			return nil, err
 		}
 		dimensions = append(dimensions, dimension)
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Printf("Error after iterating dimension row: %v", err)
+		queryError(ctx, "error iterating rows", "GetAvailableDimensions", err)
 		return nil, err
 	}
 
 	return dimensions, nil
 }
 
-// GetAvailableValuesForDimension returns all available values for a specific dimension
-func GetAvailableValuesForDimension(db *sql.DB, dimension string) ([]string, error) {
+// GetAvailableValuesForDimension returns up to limit values of a tenant's dimension that sort after the given
+// value, in order; pass an empty after to start from the first value, and a limit of 0 for every value.
+// Only eq and in rules contribute, since ranges, prefixes and patterns are not values a client can send.
+func GetAvailableValuesForDimension(ctx context.Context, db *sql.DB, tenantID, dimension, after string, limit int) ([]string, error) {
 	query := `
-		SELECT DISTINCT value 
-		FROM targeting_rules 
-		WHERE dimension = $1 
-		ORDER BY value;
+		SELECT value
+		FROM (
+			SELECT DISTINCT unnest(CASE WHEN operator = 'in' THEN string_to_array(value, ',') ELSE ARRAY[value] END) AS value
+			FROM targeting_rules 
+			WHERE tenant_id = $1
+			  AND dimension = $2 
+			  AND operator IN ('eq', 'in')
+		) AS dimension_values
+		WHERE value > $3
+		ORDER BY value
+		LIMIT NULLIF($4, 0);
 	`
 
-	rows, err := db.Query(query, dimension)
+	ctx, span := startSpan(ctx, "GetAvailableValuesForDimension", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, tenantID, dimension, after, limit)
 	if err != nil {
-		log.Printf("DB query failed for GetAvailableValuesForDimension: %v", err)
+		queryError(ctx, "db query failed", "GetAvailableValuesForDimension", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -239,16 +314,151 @@ func GetAvailableValuesForDimension(db *sql.DB, dimension string) ([]string, err
 		var value string
 		err := rows.Scan(&value)
 		if err != nil {
-			log.Printf("Error scanning value row: %v", err)
+			queryError(ctx, "error scanning row", "GetAvailableValuesForDimension", err)
 			return nil, err
 		}
 		values = append(values, value)
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Printf("Error after iterating value row: %v", err)
+		queryError(ctx, "error iterating rows", "GetAvailableValuesForDimension", err)
 		return nil, err
 	}
 
 	return values, nil
 }
+
+// UpsertTargetingRule validates and inserts or refreshes a targeting rule of a tenant's campaign. The value
+// is normalized so that it matches the normalized values produced for delivery requests.
+func UpsertTargetingRule(ctx context.Context, db *sql.DB, tenantID string, rule models.TargetingRule) error {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("UpsertTargetingRule"))
+	defer timer.ObserveDuration()
+
+	rule, err := targeting.PrepareRule(rule)
+	if err != nil {
+		return fmt.Errorf("invalid targeting rule for campaign %s: %w", rule.CampaignID, err)
+	}
+
+	query := `
+		INSERT INTO targeting_rules (tenant_id, campaign_id, dimension, type, operator, value)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant_id, campaign_id, dimension, type, operator, value) DO UPDATE SET
+			udate = NOW()
+	`
+
+	ctx, span := startSpan(ctx, "UpsertTargetingRule", query)
+	defer span.End()
+
+	if _, err := db.ExecContext(ctx, query, tenantID, rule.CampaignID, rule.Dimension, rule.Type, rule.Operator, rule.Value); err != nil {
+		queryError(ctx, "db query failed", "UpsertTargetingRule", err)
+		return err
+	}
+
+	return nil
+}
+
+// NormalizeTargetingRules rewrites the stored rules of every tenant whose values predate normalization, with
+// the same targeting.PrepareRule applied on write, so that stored and request values agree. Rules whose
+// normalized form already exists, including one rewritten earlier in the same run, are deleted instead.
+// Rules that fail validation are left as they are and counted as skipped.
+func NormalizeTargetingRules(ctx context.Context, db *sql.DB) (updated, deleted, skipped int, err error) {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("NormalizeTargetingRules"))
+	defer timer.ObserveDuration()
+
+	selectQuery := `
+		SELECT tenant_id, campaign_id, dimension, type, operator, value
+		FROM targeting_rules
+		ORDER BY tenant_id, campaign_id, dimension, type, operator, value
+		FOR UPDATE;
+	`
+	updateQuery := `
+		UPDATE targeting_rules
+		SET value = $6,
+		    udate = NOW()
+		WHERE tenant_id = $1 AND campaign_id = $2 AND dimension = $3 AND type = $4 AND operator = $5 AND value = $7
+		  AND NOT EXISTS (
+			SELECT 1 FROM targeting_rules
+			WHERE tenant_id = $1 AND campaign_id = $2 AND dimension = $3 AND type = $4 AND operator = $5 AND value = $6
+		  );
+	`
+	deleteQuery := `
+		DELETE FROM targeting_rules
+		WHERE tenant_id = $1 AND campaign_id = $2 AND dimension = $3 AND type = $4 AND operator = $5 AND value = $6;
+	`
+
+	ctx, span := startSpan(ctx, "NormalizeTargetingRules", updateQuery)
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		queryError(ctx, "error starting transaction", "NormalizeTargetingRules", err)
+		return 0, 0, 0, err
+	}
+	defer tx.Rollback()
+
+	type storedRule struct {
+		tenantID string
+		models.TargetingRule
+	}
+
+	rows, err := tx.QueryContext(ctx, selectQuery)
+	if err != nil {
+		queryError(ctx, "db query failed", "NormalizeTargetingRules", err)
+		return 0, 0, 0, err
+	}
+	var rules []storedRule
+	for rows.Next() {
+		var rule storedRule
+		if err := rows.Scan(&rule.tenantID, &rule.CampaignID, &rule.Dimension, &rule.Type, &rule.Operator, &rule.Value); err != nil {
+			rows.Close()
+			queryError(ctx, "error scanning row", "NormalizeTargetingRules", err)
+			return 0, 0, 0, err
+		}
+		rules = append(rules, rule)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		queryError(ctx, "error iterating rows", "NormalizeTargetingRules", err)
+		return 0, 0, 0, err
+	}
+
+	for _, rule := range rules {
+		prepared, err := targeting.PrepareRule(rule.TargetingRule)
+		if err != nil {
+			logging.FromContext(ctx).Warn("targeting rule left unnormalized", "tenant_id", rule.tenantID,
+				"campaign_id", rule.CampaignID, "dimension", rule.Dimension, "value", rule.Value, "error", err)
+			skipped++
+			continue
+		}
+		if prepared.Value == rule.Value {
+			continue
+		}
+
+		key := []interface{}{rule.tenantID, rule.CampaignID, rule.Dimension, rule.Type, rule.Operator}
+		result, err := tx.ExecContext(ctx, updateQuery, append(key, prepared.Value, rule.Value)...)
+		if err != nil {
+			queryError(ctx, "db query failed", "NormalizeTargetingRules", err)
+			return 0, 0, 0, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if affected > 0 {
+			updated++
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, deleteQuery, append(key, rule.Value)...); err != nil {
+			queryError(ctx, "db query failed", "NormalizeTargetingRules", err)
+			return 0, 0, 0, err
+		}
+		deleted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		queryError(ctx, "error committing transaction", "NormalizeTargetingRules", err)
+		return 0, 0, 0, err
+	}
+	return updated, deleted, skipped, nil
+}
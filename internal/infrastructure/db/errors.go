@@ -0,0 +1,6 @@
+package db
+
+import "errors"
+
+// ErrAlreadyExists is returned when creating a record whose key is already taken
+var ErrAlreadyExists = errors.New("already exists")
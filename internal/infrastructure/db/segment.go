@@ -0,0 +1,248 @@
+package db
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/pkg/utils"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CreateSegment inserts a new segment for a tenant. Returns an error wrapping ErrAlreadyExists if the tenant
+// already uses the ID.
+func CreateSegment(ctx context.Context, db *sql.DB, tenantID string, segment models.Segment) error {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("CreateSegment"))
+	defer timer.ObserveDuration()
+
+	query := `
+		INSERT INTO segments (tenant_id, segment_id, name, description)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id, segment_id) DO NOTHING;
+	`
+
+	ctx, span := startSpan(ctx, "CreateSegment", query)
+	defer span.End()
+
+	result, err := db.ExecContext(ctx, query, tenantID, segment.SegmentID, segment.Name, segment.Description)
+	if err != nil {
+		queryError(ctx, "db query failed", "CreateSegment", err)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("segment %s: %w", segment.SegmentID, ErrAlreadyExists)
+	}
+
+	return nil
+}
+
+// GetSegments returns all segments of a tenant with their member counts
+func GetSegments(ctx context.Context, db *sql.DB, tenantID string) ([]models.Segment, error) {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("GetSegments"))
+	defer timer.ObserveDuration()
+
+	query := `
+		SELECT s.segment_id, s.name, s.description, count(m.user_id), s.cdate::text, s.udate::text
+		FROM segments s
+		LEFT JOIN segment_members m ON m.tenant_id = s.tenant_id AND m.segment_id = s.segment_id
+		WHERE s.tenant_id = $1
+		GROUP BY s.tenant_id, s.segment_id
+		ORDER BY s.segment_id;
+	`
+
+	ctx, span := startSpan(ctx, "GetSegments", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		queryError(ctx, "db query failed", "GetSegments", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var segments []models.Segment
+	for rows.Next() {
+		var segment models.Segment
+		err := rows.Scan(&segment.SegmentID, &segment.Name, &segment.Description, &segment.MemberCount, &segment.CDate, &segment.UDate)
+		if err != nil {
+			queryError(ctx, "error scanning row", "GetSegments", err)
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+
+	if err = rows.Err(); err != nil {
+		queryError(ctx, "error iterating rows", "GetSegments", err)
+		return nil, err
+	}
+
+	return segments, nil
+}
+
+// MissingSegments returns the IDs among segmentIDs that the tenant has no segment for, in sorted order
+func MissingSegments(ctx context.Context, db *sql.DB, tenantID string, segmentIDs []string) ([]string, error) {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("MissingSegments"))
+	defer timer.ObserveDuration()
+
+	query := `
+		SELECT requested.segment_id
+		FROM unnest($2::text[]) AS requested(segment_id)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM segments s
+			WHERE s.tenant_id = $1
+			  AND s.segment_id = requested.segment_id
+		)
+		ORDER BY requested.segment_id;
+	`
+
+	ctx, span := startSpan(ctx, "MissingSegments", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, tenantID, pq.Array(segmentIDs))
+	if err != nil {
+		queryError(ctx, "db query failed", "MissingSegments", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var segmentID string
+		if err := rows.Scan(&segmentID); err != nil {
+			queryError(ctx, "error scanning row", "MissingSegments", err)
+			return nil, err
+		}
+		missing = append(missing, segmentID)
+	}
+
+	if err = rows.Err(); err != nil {
+		queryError(ctx, "error iterating rows", "MissingSegments", err)
+		return nil, err
+	}
+
+	return missing, nil
+}
+
+// AddSegmentMembers bulk-loads user IDs into a segment with COPY, ignoring users already present.
+// With replace set, existing members not in userIDs are removed in the same transaction.
+// Returns the number of members added, or sql.ErrNoRows if the tenant has no such segment.
+func AddSegmentMembers(ctx context.Context, db *sql.DB, tenantID, segmentID string, userIDs []string, replace bool) (int64, error) {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("AddSegmentMembers"))
+	defer timer.ObserveDuration()
+
+	// One span covers the whole upload transaction
+	ctx, span := startSpan(ctx, "AddSegmentMembers", "")
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the segment row so concurrent uploads to the same segment serialize
+	var locked int
+	if err := tx.QueryRowContext(ctx, `SELECT 1 FROM segments WHERE tenant_id = $1 AND segment_id = $2 FOR UPDATE`, tenantID, segmentID).Scan(&locked); err != nil {
+		if err != sql.ErrNoRows {
+			queryError(ctx, "db query failed", "AddSegmentMembers", err)
+		}
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TEMP TABLE segment_upload (user_id TEXT NOT NULL) ON COMMIT DROP`); err != nil {
+		queryError(ctx, "db query failed", "AddSegmentMembers", err)
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("segment_upload", "user_id"))
+	if err != nil {
+		return 0, err
+	}
+	for _, userID := range userIDs {
+		if _, err := stmt.Exec(userID); err != nil {
+			stmt.Close()
+			return 0, err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return 0, err
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, err
+	}
+
+	if replace {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM segment_members m
+			WHERE m.tenant_id = $1
+			  AND m.segment_id = $2
+			  AND NOT EXISTS (SELECT 1 FROM segment_upload u WHERE u.user_id = m.user_id)
+		`, tenantID, segmentID)
+		if err != nil {
+			queryError(ctx, "db query failed", "AddSegmentMembers", err)
+			return 0, err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO segment_members (tenant_id, segment_id, user_id)
+		SELECT DISTINCT $1, $2, user_id FROM segment_upload
+		ON CONFLICT (tenant_id, segment_id, user_id) DO NOTHING
+	`, tenantID, segmentID)
+	if err != nil {
+		queryError(ctx, "db query failed", "AddSegmentMembers", err)
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE segments SET udate = NOW() WHERE tenant_id = $1 AND segment_id = $2`, tenantID, segmentID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// LoadSegmentMemberships streams every (segment, user) pair of a tenant into add
+func LoadSegmentMemberships(ctx context.Context, db *sql.DB, tenantID string, add func(segmentID, userID string)) error {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("LoadSegmentMemberships"))
+	defer timer.ObserveDuration()
+
+	query := `SELECT segment_id, user_id FROM segment_members WHERE tenant_id = $1`
+
+	ctx, span := startSpan(ctx, "LoadSegmentMemberships", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		queryError(ctx, "db query failed", "LoadSegmentMemberships", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var segmentID, userID string
+		if err := rows.Scan(&segmentID, &userID); err != nil {
+			queryError(ctx, "error scanning row", "LoadSegmentMemberships", err)
+			return err
+		}
+		add(segmentID, userID)
+	}
+
+	if err = rows.Err(); err != nil {
+		queryError(ctx, "error iterating rows", "LoadSegmentMemberships", err)
+		return err
+	}
+
+	return nil
+}
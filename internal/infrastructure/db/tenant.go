@@ -0,0 +1,50 @@
+package db
+
+import (
+	"campaign/internal/domain/models"
+	"campaign/pkg/utils"
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LoadTenants returns every tenant with the hosts it is served on
+func LoadTenants(ctx context.Context, db *sql.DB) ([]models.Tenant, error) {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("LoadTenants"))
+	defer timer.ObserveDuration()
+
+	query := `
+		SELECT tenant_id, name, hosts
+		FROM tenants
+		ORDER BY tenant_id;
+	`
+
+	ctx, span := startSpan(ctx, "LoadTenants", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		queryError(ctx, "db query failed", "LoadTenants", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []models.Tenant
+	for rows.Next() {
+		var tenant models.Tenant
+		if err := rows.Scan(&tenant.TenantID, &tenant.Name, pq.Array(&tenant.Hosts)); err != nil {
+			queryError(ctx, "error scanning row", "LoadTenants", err)
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+
+	if err = rows.Err(); err != nil {
+		queryError(ctx, "error iterating rows", "LoadTenants", err)
+		return nil, err
+	}
+
+	return tenants, nil
+}
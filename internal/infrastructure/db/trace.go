@@ -0,0 +1,49 @@
+package db
+
+import (
+	"campaign/pkg/logging"
+	"context"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("campaign/internal/infrastructure/db")
+
+// sqlLiteral matches placeholders, which are kept, and string and numeric literals, which are masked
+var sqlLiteral = regexp.MustCompile(`\$\d+|'(?:[^']|'')*'|\b\d+(?:\.\d+)?\b`)
+
+// startSpan starts a client span for one DB operation. The query, if any, is recorded sanitized.
+func startSpan(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(operation))
+	if query != "" {
+		span.SetAttributes(semconv.DBQueryText(sanitizeSQL(query)))
+	}
+	return ctx, span
+}
+
+// queryError logs a failed DB operation and marks its span as failed
+func queryError(ctx context.Context, msg, operation string, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, msg)
+
+	logging.FromContext(ctx).Error(msg, "operation", operation, "error", err)
+}
+
+// sanitizeSQL masks literal values and collapses whitespace, so that statements can be exported in spans
+// without leaking data. Bind placeholders ($1, $2, ...) are kept.
+func sanitizeSQL(query string) string {
+	masked := sqlLiteral.ReplaceAllStringFunc(query, func(token string) string {
+		if strings.HasPrefix(token, "$") {
+			return token
+		}
+		return "?"
+	})
+	return strings.Join(strings.Fields(masked), " ")
+}
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeSQL(t *testing.T) {
+	query := `
+		SELECT campaign_id, COALESCE(targeting_expression::text, '')
+		FROM campaigns
+		WHERE campaign_status = 'ACTIVE' AND name = 'it''s' AND version >= 2.5
+		  AND campaign_id = $1
+		LIMIT 10;
+	`
+
+	assert.Equal(t,
+		"SELECT campaign_id, COALESCE(targeting_expression::text, ?) FROM campaigns WHERE campaign_status = ? AND name = ? AND version >= ? AND campaign_id = $1 LIMIT ?;",
+		sanitizeSQL(query))
+}
@@ -0,0 +1,81 @@
+package geo
+
+import (
+	"campaign/pkg/utils"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Location is the geographic information resolved for an IP address
+type Location struct {
+	Country string // ISO 3166-1 alpha-2
+	Region  string // ISO 3166-2, e.g. US-CA
+	City    string // English name
+}
+
+// Reader resolves IP addresses against a local MaxMind-format (GeoIP2/GeoLite2) database file
+type Reader struct {
+	db     *geoip2.Reader
+	isCity bool
+}
+
+// Open opens a Country or City database. The file is memory-mapped and safe for concurrent lookups.
+func Open(path string) (*Reader, error) {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening geoip database %s: %w", path, err)
+	}
+
+	dbType := db.Metadata().DatabaseType
+	slog.Info("geoip database loaded", "path", path, "type", dbType)
+
+	return &Reader{
+		db:     db,
+		isCity: strings.Contains(dbType, "City") || strings.Contains(dbType, "Enterprise"),
+	}, nil
+}
+
+// Lookup returns the location of ip. A nil location without error means the address is not in the database.
+func (r *Reader) Lookup(ip net.IP) (*Location, error) {
+	timer := prometheus.NewTimer(utils.DBOperationDuration.WithLabelValues("GeoIPLookup"))
+	defer timer.ObserveDuration()
+
+	if !r.isCity {
+		record, err := r.db.Country(ip)
+		if err != nil {
+			return nil, err
+		}
+		if record.Country.IsoCode == "" {
+			return nil, nil
+		}
+		return &Location{Country: record.Country.IsoCode}, nil
+	}
+
+	record, err := r.db.City(ip)
+	if err != nil {
+		return nil, err
+	}
+	if record.Country.IsoCode == "" {
+		return nil, nil
+	}
+
+	location := &Location{
+		Country: record.Country.IsoCode,
+		City:    record.City.Names["en"],
+	}
+	if len(record.Subdivisions) > 0 && record.Subdivisions[0].IsoCode != "" {
+		location.Region = record.Country.IsoCode + "-" + record.Subdivisions[0].IsoCode
+	}
+
+	return location, nil
+}
+
+// Close unmaps the database file
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,2 @@
+-- Normalization is lossy (aliases are folded together), so there is nothing to restore.
+SELECT 1;
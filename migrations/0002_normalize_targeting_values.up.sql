@@ -0,0 +1,4 @@
+-- Existing targeting values are normalized by cmd/normalize (go run ./cmd/normalize) once the migrations are
+-- applied, with the same Go normalizers used on write and on delivery, so that the alias tables live in one
+-- place. Rows that collide after normalization are deduplicated there as well.
+SELECT 1;
@@ -0,0 +1,10 @@
+DELETE FROM targeting_rules WHERE operator <> 'eq' OR dimension NOT IN ('country', 'os', 'app_id');
+
+ALTER TABLE targeting_rules DROP CONSTRAINT targeting_rules_pkey;
+ALTER TABLE targeting_rules ADD PRIMARY KEY (campaign_id, dimension, type, value);
+
+ALTER TABLE targeting_rules DROP CONSTRAINT IF EXISTS targeting_rules_dimension_check;
+ALTER TABLE targeting_rules
+    ADD CONSTRAINT targeting_rules_dimension_check CHECK (dimension IN ('country', 'os', 'app_id'));
+
+ALTER TABLE targeting_rules DROP COLUMN operator;
@@ -0,0 +1,13 @@
+-- Rules carry an operator so that dimensions can be matched by prefix, range, version or pattern
+-- instead of exact equality only. Existing rules keep their meaning as 'eq'.
+ALTER TABLE targeting_rules
+    ADD COLUMN operator TEXT NOT NULL DEFAULT 'eq'
+    CHECK (operator IN ('eq', 'in', 'prefix', 'range', 'semver_gte', 'semver_lte', 'regex'));
+
+-- Dimensions such as app_version, os_version, age and locale are now targetable.
+ALTER TABLE targeting_rules DROP CONSTRAINT IF EXISTS targeting_rules_dimension_check;
+ALTER TABLE targeting_rules
+    ADD CONSTRAINT targeting_rules_dimension_check CHECK (dimension ~ '^[a-z][a-z0-9_]*$');
+
+ALTER TABLE targeting_rules DROP CONSTRAINT targeting_rules_pkey;
+ALTER TABLE targeting_rules ADD PRIMARY KEY (campaign_id, dimension, type, operator, value);
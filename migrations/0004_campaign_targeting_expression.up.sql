@@ -0,0 +1,2 @@
+-- Optional boolean targeting expression (JSON AST), evaluated after the campaign's targeting_rules.
+ALTER TABLE campaigns ADD COLUMN targeting_expression JSONB;
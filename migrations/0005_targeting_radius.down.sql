@@ -0,0 +1,6 @@
+DELETE FROM targeting_rules WHERE operator = 'radius';
+
+ALTER TABLE targeting_rules DROP CONSTRAINT targeting_rules_operator_check;
+ALTER TABLE targeting_rules
+    ADD CONSTRAINT targeting_rules_operator_check
+    CHECK (operator IN ('eq', 'in', 'prefix', 'range', 'semver_gte', 'semver_lte', 'regex'));
@@ -0,0 +1,5 @@
+-- Radius targeting: dimension 'location', operator 'radius', value 'lat,lon,radius_km'.
+ALTER TABLE targeting_rules DROP CONSTRAINT targeting_rules_operator_check;
+ALTER TABLE targeting_rules
+    ADD CONSTRAINT targeting_rules_operator_check
+    CHECK (operator IN ('eq', 'in', 'prefix', 'range', 'semver_gte', 'semver_lte', 'regex', 'radius'));
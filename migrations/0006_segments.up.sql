@@ -0,0 +1,14 @@
+CREATE TABLE segments (
+    segment_id TEXT PRIMARY KEY CHECK (segment_id ~ '^[a-z0-9][a-z0-9_-]*$'),
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    cdate TIMESTAMPTZ NOT NULL DEFAULT now(),
+    udate TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE segment_members (
+    segment_id TEXT NOT NULL REFERENCES segments(segment_id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    cdate TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (segment_id, user_id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_flight_check;
+ALTER TABLE campaigns DROP COLUMN IF EXISTS end_time;
+ALTER TABLE campaigns DROP COLUMN IF EXISTS start_time;
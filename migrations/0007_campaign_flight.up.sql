@@ -0,0 +1,5 @@
+-- Optional flight window; a campaign delivers only between start_time (inclusive) and end_time (exclusive).
+-- NULL leaves that side unbounded.
+ALTER TABLE campaigns ADD COLUMN start_time TIMESTAMPTZ;
+ALTER TABLE campaigns ADD COLUMN end_time TIMESTAMPTZ;
+ALTER TABLE campaigns ADD CONSTRAINT campaigns_flight_check CHECK (start_time IS NULL OR end_time IS NULL OR start_time < end_time);
@@ -0,0 +1,23 @@
+-- API clients and their keys. A client may hold several keys at once, so that a new key can be rolled out
+-- before the old one expires. Only the SHA-256 of a key is stored; key_id is the public part of the key
+-- used to look it up.
+CREATE TABLE api_clients (
+    client_id TEXT PRIMARY KEY CHECK (client_id ~ '^[a-z0-9][a-z0-9_-]*$'),
+    name TEXT NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    cdate TIMESTAMPTZ NOT NULL DEFAULT now(),
+    udate TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE api_client_keys (
+    key_id TEXT PRIMARY KEY,
+    client_id TEXT NOT NULL REFERENCES api_clients(client_id) ON DELETE CASCADE,
+    key_hash BYTEA NOT NULL,
+    not_before TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ,
+    cdate TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (expires_at IS NULL OR not_before < expires_at)
+);
+
+CREATE INDEX api_client_keys_client_id_idx ON api_client_keys (client_id);
@@ -0,0 +1,31 @@
+-- IDs are global again, so only the default tenant's data can be kept
+DELETE FROM campaigns WHERE tenant_id <> 'default';
+DELETE FROM segments WHERE tenant_id <> 'default';
+DELETE FROM api_clients WHERE tenant_id <> 'default';
+
+DROP INDEX idx_targeting_lookup;
+CREATE INDEX idx_targeting_lookup ON targeting_rules (dimension, type, value);
+
+ALTER TABLE segment_members DROP CONSTRAINT segment_members_segment_fkey;
+ALTER TABLE segment_members DROP CONSTRAINT segment_members_pkey;
+ALTER TABLE segments DROP CONSTRAINT segments_pkey;
+ALTER TABLE segments ADD PRIMARY KEY (segment_id);
+ALTER TABLE segment_members ADD PRIMARY KEY (segment_id, user_id);
+ALTER TABLE segment_members ADD CONSTRAINT segment_members_segment_id_fkey
+    FOREIGN KEY (segment_id) REFERENCES segments(segment_id) ON DELETE CASCADE;
+
+ALTER TABLE targeting_rules DROP CONSTRAINT targeting_rules_campaign_fkey;
+ALTER TABLE targeting_rules DROP CONSTRAINT targeting_rules_pkey;
+ALTER TABLE campaigns DROP CONSTRAINT campaigns_pkey;
+ALTER TABLE campaigns ADD PRIMARY KEY (campaign_id);
+ALTER TABLE targeting_rules ADD PRIMARY KEY (campaign_id, dimension, type, operator, value);
+ALTER TABLE targeting_rules ADD CONSTRAINT targeting_rules_campaign_id_fkey
+    FOREIGN KEY (campaign_id) REFERENCES campaigns(campaign_id) ON DELETE CASCADE;
+
+ALTER TABLE api_clients DROP COLUMN tenant_id;
+ALTER TABLE segment_members DROP COLUMN tenant_id;
+ALTER TABLE segments DROP COLUMN tenant_id;
+ALTER TABLE targeting_rules DROP COLUMN tenant_id;
+ALTER TABLE campaigns DROP COLUMN tenant_id;
+
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,38 @@
+-- Tenants are the business units sharing this service. Campaigns, their rules, segments and API clients
+-- each belong to one tenant, and IDs are only unique within a tenant. Existing rows move to the
+-- 'default' tenant. hosts lists the request hosts that select a tenant when the caller has no API key.
+CREATE TABLE tenants (
+    tenant_id TEXT PRIMARY KEY CHECK (tenant_id ~ '^[a-z0-9][a-z0-9_-]*$'),
+    name TEXT NOT NULL,
+    hosts TEXT[] NOT NULL DEFAULT '{}',
+    cdate TIMESTAMPTZ NOT NULL DEFAULT now(),
+    udate TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO tenants (tenant_id, name) VALUES ('default', 'Default');
+
+ALTER TABLE campaigns ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(tenant_id);
+ALTER TABLE targeting_rules ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE segments ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(tenant_id);
+ALTER TABLE segment_members ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE api_clients ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(tenant_id);
+
+ALTER TABLE targeting_rules DROP CONSTRAINT targeting_rules_campaign_id_fkey;
+ALTER TABLE targeting_rules DROP CONSTRAINT targeting_rules_pkey;
+ALTER TABLE campaigns DROP CONSTRAINT campaigns_pkey;
+ALTER TABLE campaigns ADD PRIMARY KEY (tenant_id, campaign_id);
+ALTER TABLE targeting_rules ADD PRIMARY KEY (tenant_id, campaign_id, dimension, type, operator, value);
+ALTER TABLE targeting_rules ADD CONSTRAINT targeting_rules_campaign_fkey
+    FOREIGN KEY (tenant_id, campaign_id) REFERENCES campaigns(tenant_id, campaign_id) ON DELETE CASCADE;
+
+ALTER TABLE segment_members DROP CONSTRAINT segment_members_segment_id_fkey;
+ALTER TABLE segment_members DROP CONSTRAINT segment_members_pkey;
+ALTER TABLE segments DROP CONSTRAINT segments_pkey;
+ALTER TABLE segments ADD PRIMARY KEY (tenant_id, segment_id);
+ALTER TABLE segment_members ADD PRIMARY KEY (tenant_id, segment_id, user_id);
+ALTER TABLE segment_members ADD CONSTRAINT segment_members_segment_fkey
+    FOREIGN KEY (tenant_id, segment_id) REFERENCES segments(tenant_id, segment_id) ON DELETE CASCADE;
+
+-- Delivery lookups now always filter by tenant first
+DROP INDEX idx_targeting_lookup;
+CREATE INDEX idx_targeting_lookup ON targeting_rules (tenant_id, dimension, type, value);
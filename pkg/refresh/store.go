@@ -0,0 +1,105 @@
+// Package refresh holds values loaded from the database, such as the targeting snapshot, and swaps in a
+// fresh value on every refresh so that readers never wait on a query.
+package refresh
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RetryInterval is the least time between loads triggered by callers while no value has loaded, so that
+// a database outage is not hit with a query for every request
+const RetryInterval = 5 * time.Second
+
+// ErrUnavailable is returned while no value has loaded and a retry is not yet allowed
+var ErrUnavailable = errors.New("not loaded yet")
+
+// Store holds the current value built by its loader. The value is loaded on first use and reloaded by
+// Refresh; a failed reload keeps the previous value.
+type Store[T any] struct {
+	// name describes the value in log messages, such as "targeting snapshot"
+	name    string
+	logger  *slog.Logger
+	loader  func() (*T, error)
+	current atomic.Pointer[T]
+	// lastLoad and lastErr are the time and error of the last load, guarded by mutex
+	lastLoad time.Time
+	lastErr  error
+	now      func() time.Time
+	mutex    sync.Mutex
+}
+
+// NewStore creates a store whose background refreshes log failures to logger
+func NewStore[T any](name string, logger *slog.Logger, loader func() (*T, error)) *Store[T] {
+	return &Store[T]{
+		name:   name,
+		logger: logger,
+		loader: loader,
+		now:    time.Now,
+	}
+}
+
+// Get returns the current value, loading it on first use. While nothing has loaded, callers retry the
+// load at most every RetryInterval and get ErrUnavailable in between.
+func (s *Store[T]) Get() (*T, error) {
+	if value := s.current.Load(); value != nil {
+		return value, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Another caller may have loaded it while we waited for the lock
+	if value := s.current.Load(); value != nil {
+		return value, nil
+	}
+	if s.now().Sub(s.lastLoad) < RetryInterval {
+		return nil, fmt.Errorf("%s %w: %w", s.name, ErrUnavailable, s.lastErr)
+	}
+
+	return s.refreshLocked()
+}
+
+// Refresh reloads the value. On failure the previous value is kept.
+func (s *Store[T]) Refresh() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err := s.refreshLocked()
+	return err
+}
+
+// Ready reports whether a value has been loaded
+func (s *Store[T]) Ready() bool {
+	return s.current.Load() != nil
+}
+
+// StartRefresh reloads the value in the background every interval
+func (s *Store[T]) StartRefresh(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := s.Refresh(); err != nil {
+				s.logger.Error("error refreshing "+s.name, "error", err)
+			}
+		}
+	}()
+}
+
+func (s *Store[T]) refreshLocked() (*T, error) {
+	s.lastLoad = s.now()
+	value, err := s.loader()
+	s.lastErr = err
+	if err != nil {
+		return nil, err
+	}
+
+	s.current.Store(value)
+	return value, nil
+}
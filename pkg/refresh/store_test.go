@@ -0,0 +1,72 @@
+package refresh
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	var loads int
+	var loadErr error
+	store := NewStore("test value", slog.Default(), func() (*int, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		loads++
+		value := loads
+		return &value, nil
+	})
+	assert.False(t, store.Ready())
+
+	// The value is loaded on first use and then served from memory
+	value, err := store.Get()
+	require.NoError(t, err)
+	assert.Equal(t, 1, *value)
+	value, err = store.Get()
+	require.NoError(t, err)
+	assert.Equal(t, 1, *value)
+	assert.True(t, store.Ready())
+
+	require.NoError(t, store.Refresh())
+	value, _ = store.Get()
+	assert.Equal(t, 2, *value)
+
+	// A failed refresh keeps the previous value
+	loadErr = errors.New("database down")
+	assert.ErrorIs(t, store.Refresh(), loadErr)
+	value, err = store.Get()
+	require.NoError(t, err)
+	assert.Equal(t, 2, *value)
+}
+
+func TestStore_FailedLoadIsThrottled(t *testing.T) {
+	var loads int
+	store := NewStore("test value", slog.Default(), func() (*int, error) {
+		loads++
+		return nil, errors.New("database down")
+	})
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	// While nothing has loaded, only one caller per retry interval reaches the loader
+	_, err := store.Get()
+	assert.EqualError(t, err, "database down")
+	_, err = store.Get()
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.ErrorContains(t, err, "database down")
+	assert.Equal(t, 1, loads)
+
+	now = now.Add(RetryInterval)
+	_, err = store.Get()
+	assert.EqualError(t, err, "database down")
+	assert.Equal(t, 2, loads)
+
+	// Explicit refreshes are not throttled
+	assert.Error(t, store.Refresh())
+	assert.Equal(t, 3, loads)
+}
@@ -0,0 +1,195 @@
+package useragent
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Info is what could be inferred about the client device. Empty fields could not be determined.
+type Info struct {
+	OS         string // canonical lower-case name, e.g. android, ios, windows
+	OSVersion  string // dotted version, e.g. 17.4
+	DeviceType string // mobile, tablet, desktop, tv or bot
+	Browser    string // lower-case name, e.g. chrome, safari, firefox
+}
+
+var (
+	androidVersion = regexp.MustCompile(`Android[ /]?([0-9]+(?:[._][0-9]+)*)`)
+	iosVersion     = regexp.MustCompile(`(?:iPhone OS|CPU OS|iPad; CPU OS) ([0-9]+(?:_[0-9]+)*)`)
+	macVersion     = regexp.MustCompile(`Mac OS X ([0-9]+(?:[._][0-9]+)*)`)
+	windowsVersion = regexp.MustCompile(`Windows NT ([0-9]+\.[0-9]+)`)
+	botPattern     = regexp.MustCompile(`(?i)bot\b|crawler|spider|slurp|facebookexternalhit|headless`)
+	tvPattern      = regexp.MustCompile(`(?i)smart-?tv|appletv|googletv|crkey|hbbtv|netcast|roku|\bTV\b`)
+	brandPattern   = regexp.MustCompile(`"([^"]+)"\s*;\s*v="[^"]*"`)
+)
+
+// windowsNTVersions maps Windows NT kernel versions to marketing versions
+var windowsNTVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "vista",
+	"5.1":  "xp",
+}
+
+// clientHintBrands maps Sec-CH-UA brand names to browser names; generic brands are ignored
+var clientHintBrands = map[string]string{
+	"Google Chrome":    "chrome",
+	"Microsoft Edge":   "edge",
+	"Opera":            "opera",
+	"Brave":            "brave",
+	"Samsung Internet": "samsung",
+	"Yandex":           "yandex",
+	"Vivaldi":          "vivaldi",
+}
+
+// Parse infers device information from the User-Agent header, then lets User-Agent Client Hints
+// (Sec-CH-UA-*) override it, since browsers with a reduced User-Agent freeze the OS version there.
+func Parse(header http.Header) Info {
+	info := ParseUserAgent(header.Get("User-Agent"))
+	applyClientHints(&info, header)
+	return info
+}
+
+// ParseUserAgent infers device information from a User-Agent string
+func ParseUserAgent(ua string) Info {
+	var info Info
+	if ua == "" {
+		return info
+	}
+
+	switch {
+	case strings.Contains(ua, "Windows Phone"):
+		info.OS = "windowsphone"
+	case strings.Contains(ua, "Android"):
+		info.OS = "android"
+		info.OSVersion = submatch(androidVersion, ua)
+	case strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPad") || strings.Contains(ua, "iPod"):
+		info.OS = "ios"
+		info.OSVersion = submatch(iosVersion, ua)
+	case strings.Contains(ua, "KAIOS") || strings.Contains(ua, "KaiOS"):
+		info.OS = "kaios"
+	case strings.Contains(ua, "Tizen"):
+		info.OS = "tizen"
+	case strings.Contains(ua, "Web0S") || strings.Contains(ua, "webOS"):
+		info.OS = "webos"
+	case strings.Contains(ua, "CrOS"):
+		info.OS = "chromeos"
+	case strings.Contains(ua, "Mac OS X") || strings.Contains(ua, "Macintosh"):
+		info.OS = "macos"
+		info.OSVersion = submatch(macVersion, ua)
+	case strings.Contains(ua, "Windows"):
+		info.OS = "windows"
+		info.OSVersion = windowsNTVersions[submatch(windowsVersion, ua)]
+	case strings.Contains(ua, "Linux"):
+		info.OS = "linux"
+	}
+
+	switch {
+	case botPattern.MatchString(ua):
+		info.DeviceType = "bot"
+	case tvPattern.MatchString(ua):
+		info.DeviceType = "tv"
+	case strings.Contains(ua, "iPad") || strings.Contains(ua, "Tablet") ||
+		(info.OS == "android" && !strings.Contains(ua, "Mobile")):
+		info.DeviceType = "tablet"
+	case strings.Contains(ua, "Mobi") || strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPod") ||
+		info.OS == "windowsphone" || info.OS == "kaios":
+		info.DeviceType = "mobile"
+	case info.OS != "":
+		info.DeviceType = "desktop"
+	}
+
+	switch {
+	case strings.Contains(ua, "Edg/") || strings.Contains(ua, "EdgA/") || strings.Contains(ua, "EdgiOS/"):
+		info.Browser = "edge"
+	case strings.Contains(ua, "OPR/") || strings.Contains(ua, "Opera"):
+		info.Browser = "opera"
+	case strings.Contains(ua, "SamsungBrowser/"):
+		info.Browser = "samsung"
+	case strings.Contains(ua, "Firefox/") || strings.Contains(ua, "FxiOS/"):
+		info.Browser = "firefox"
+	case strings.Contains(ua, "CriOS/") || strings.Contains(ua, "Chrome/"):
+		info.Browser = "chrome"
+	case strings.Contains(ua, "Safari/") && strings.Contains(ua, "Version/"):
+		info.Browser = "safari"
+	}
+
+	return info
+}
+
+func applyClientHints(info *Info, header http.Header) {
+	if platform := unquote(header.Get("Sec-CH-UA-Platform")); platform != "" {
+		switch strings.ToLower(platform) {
+		case "android":
+			info.OS = "android"
+		case "ios":
+			info.OS = "ios"
+		case "macos":
+			info.OS = "macos"
+		case "windows":
+			info.OS = "windows"
+		case "chrome os", "chromeos":
+			info.OS = "chromeos"
+		case "linux":
+			info.OS = "linux"
+		}
+
+		if version := unquote(header.Get("Sec-CH-UA-Platform-Version")); version != "" {
+			info.OSVersion = platformVersion(info.OS, version)
+		}
+	}
+
+	switch header.Get("Sec-CH-UA-Mobile") {
+	case "?1":
+		info.DeviceType = "mobile"
+	case "?0":
+		// ?0 only says "not a phone"; keep a tablet or tv inferred from the User-Agent
+		if info.DeviceType == "" || info.DeviceType == "mobile" {
+			info.DeviceType = "desktop"
+		}
+	}
+
+	for _, match := range brandPattern.FindAllStringSubmatch(header.Get("Sec-CH-UA"), -1) {
+		if browser, ok := clientHintBrands[match[1]]; ok {
+			info.Browser = browser
+			break
+		}
+	}
+}
+
+// platformVersion trims trailing ".0" segments and maps Windows platform versions (13+ is Windows 11)
+func platformVersion(os, version string) string {
+	if os == "windows" {
+		major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+		switch {
+		case err != nil:
+			return ""
+		case major >= 13:
+			return "11"
+		case major > 0:
+			return "10"
+		default:
+			return ""
+		}
+	}
+
+	for strings.HasSuffix(version, ".0") && strings.Count(version, ".") > 1 {
+		version = strings.TrimSuffix(version, ".0")
+	}
+	return version
+}
+
+func submatch(re *regexp.Regexp, s string) string {
+	if m := re.FindStringSubmatch(s); len(m) > 1 {
+		return strings.ReplaceAll(m[1], "_", ".")
+	}
+	return ""
+}
+
+func unquote(s string) string {
+	return strings.Trim(strings.TrimSpace(s), `"`)
+}
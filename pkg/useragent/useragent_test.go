@@ -0,0 +1,68 @@
+package useragent
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    Info
+	}{
+		{
+			name:    "android phone chrome",
+			headers: map[string]string{"User-Agent": "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"},
+			want:    Info{OS: "android", OSVersion: "14", DeviceType: "mobile", Browser: "chrome"},
+		},
+		{
+			name:    "iphone safari",
+			headers: map[string]string{"User-Agent": "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1"},
+			want:    Info{OS: "ios", OSVersion: "17.4.1", DeviceType: "mobile", Browser: "safari"},
+		},
+		{
+			name:    "ipad",
+			headers: map[string]string{"User-Agent": "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1"},
+			want:    Info{OS: "ios", OSVersion: "16.6", DeviceType: "tablet", Browser: "safari"},
+		},
+		{
+			name:    "windows edge",
+			headers: map[string]string{"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.67"},
+			want:    Info{OS: "windows", OSVersion: "10", DeviceType: "desktop", Browser: "edge"},
+		},
+		{
+			name: "client hints override reduced user agent",
+			headers: map[string]string{
+				"User-Agent":                 "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+				"Sec-CH-UA":                  `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`,
+				"Sec-CH-UA-Mobile":           "?0",
+				"Sec-CH-UA-Platform":         `"Windows"`,
+				"Sec-CH-UA-Platform-Version": `"15.0.0"`,
+			},
+			want: Info{OS: "windows", OSVersion: "11", DeviceType: "desktop", Browser: "chrome"},
+		},
+		{
+			name:    "crawler",
+			headers: map[string]string{"User-Agent": "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+			want:    Info{DeviceType: "bot"},
+		},
+		{
+			name:    "no headers",
+			headers: map[string]string{},
+			want:    Info{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, Parse(header))
+		})
+	}
+}
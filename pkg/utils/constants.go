@@ -1,12 +1,27 @@
 package utils
 
 const (
-	ErrMissingApp       = "missing app parameter"
-	ErrMissingOS        = "missing os parameter"
-	ErrMissingCountry   = "missing country parameter"
-	ErrMethodNotAllowed = "method is not allowed"
-	InternalServerError = "internal server error"
-	DefaultApiPageLimit = 10
+	ErrMissingApp        = "missing app parameter"
+	ErrMissingOS         = "missing os parameter"
+	ErrMissingCountry    = "missing country parameter"
+	ErrMethodNotAllowed  = "method is not allowed"
+	InternalServerError  = "internal server error"
+	ErrCampaignNotFound  = "campaign not found"
+	ErrInvalidExpression = "invalid targeting expression"
+	ErrUnknownSegment    = "targeting references unknown segments"
+	ErrInvalidStatus     = "status must be ACTIVE or INACTIVE"
+	ErrUnauthorized      = "missing or invalid api key"
+	ErrForbidden         = "api key lacks the required scope"
+	ErrRateLimited       = "rate limit exceeded"
+	ErrInvalidToken      = "missing or invalid bearer token"
+	ErrPermissionDenied  = "not permitted for your roles"
+	ErrUnknownTenant     = "tenant could not be resolved from the api key or host"
+	ErrTenantDenied      = "not permitted for this tenant"
+	ErrNotAcceptable     = "none of the accepted media types can be served"
+	DefaultApiPageLimit  = 10
+	// Discovery values are small strings, so they are served in larger pages
+	DefaultValuesPageLimit = 100
+	MaxValuesPageLimit     = 1000
 )
 
 var TargetingDimensions = []string{"app_id", "country", "os"}
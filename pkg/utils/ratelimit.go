@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"bytes"
+	"campaign/pkg/logging"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit is a token bucket: Burst requests at once, refilled at Rate requests per second
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// window is the time an empty bucket takes to fill up again
+func (l RateLimit) window() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// RateLimitResult is the outcome of taking a token
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left after this request
+	Remaining int
+	// RetryAfter is the time until the next token, set when the request is rejected
+	RetryAfter time.Duration
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// RateLimitStore holds the token buckets. MemoryRateLimitStore limits each instance on its own; a shared
+// store such as cache.RedisRateLimitStore enforces limits across instances.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// NewRateLimitResult derives the result of a request from the tokens left in its bucket, for stores that
+// only keep the token count
+func NewRateLimitResult(limit RateLimit, tokens float64, allowed bool) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:    allowed,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	}
+	return result
+}
+
+// rateLimitSweepInterval is how often idle buckets are dropped from memory
+const rateLimitSweepInterval = time.Minute
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	window  time.Duration
+}
+
+// MemoryRateLimitStore keeps token buckets in process memory. Buckets that have filled up again are
+// dropped, since a new bucket starts full, so memory follows the number of recently active keys.
+type MemoryRateLimitStore struct {
+	buckets   map[string]*tokenBucket
+	nextSweep time.Time
+	now       func() time.Time
+	mutex     sync.Mutex
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Take takes one token from the bucket of key, if there is one
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	if now.After(s.nextSweep) {
+		s.sweep(now)
+		s.nextSweep = now.Add(rateLimitSweepInterval)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), updated: now, window: limit.window()}
+		s.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*limit.Rate)
+	bucket.updated = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	return NewRateLimitResult(limit, bucket.tokens, allowed), nil
+}
+
+// Len returns the number of buckets held
+func (s *MemoryRateLimitStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.buckets)
+}
+
+// sweep drops the buckets idle long enough to have filled up again
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) >= bucket.window {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// RateLimitKeyFunc returns the identity a request is limited by
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimitByIP limits each client IP
+func RateLimitByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimitByClient limits each authenticated API client or staff user, and anonymous requests by IP
+func RateLimitByClient(c *gin.Context) string {
+	if clientID := c.GetString("client_id"); clientID != "" {
+		return "client:" + clientID
+	}
+	if user := c.GetString("user"); user != "" {
+		return "user:" + user
+	}
+	return RateLimitByIP(c)
+}
+
+// maxPeekBytes bounds how much of a request body is read to find its app_id
+const maxPeekBytes = 64 << 10
+
+// RateLimitByAppID limits each app_id, taken from the query or, for batch requests, the JSON body.
+// Authenticated requests are limited per app of their client, so that one client's apps cannot exhaust
+// another client's budget for the same app ID. Requests without an app_id are limited by client, or by IP
+// without authentication.
+func RateLimitByAppID(c *gin.Context) string {
+	appID := c.Query("app_id")
+	if appID == "" && c.Request.Method == http.MethodPost {
+		appID = bodyAppID(c)
+	}
+
+	authenticated := c.GetString("client_id") != "" || c.GetString("user") != ""
+	switch {
+	case appID == "":
+		return RateLimitByClient(c)
+	case authenticated:
+		return RateLimitByClient(c) + ":app:" + appID
+	default:
+		return "app:" + appID
+	}
+}
+
+// peekedBody replays the bytes read from a request body before the rest of it
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyAppID returns the app_id of a JSON delivery request body, top-level or among its dimensions, and
+// leaves the body to be read again by the handler
+func bodyAppID(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPeekBytes))
+	c.Request.Body = peekedBody{Reader: io.MultiReader(bytes.NewReader(data), c.Request.Body), Closer: c.Request.Body}
+	if err != nil {
+		return ""
+	}
+
+	var body struct {
+		AppID      string                     `json:"app_id"`
+		Dimensions map[string]json.RawMessage `json:"dimensions"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return ""
+	}
+	if body.AppID != "" {
+		return body.AppID
+	}
+
+	// A dimension is a single string or an array of strings
+	var single string
+	var values []string
+	if raw, ok := body.Dimensions["app_id"]; ok {
+		if json.Unmarshal(raw, &single) == nil {
+			return single
+		}
+		if json.Unmarshal(raw, &values) == nil && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// RateLimitMiddleware limits the requests of a route group to limit per key. Every response carries the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers; rejected requests get 429 with
+// Retry-After. When the store fails, requests are let through rather than failing the API.
+// It must run after authentication for RateLimitByClient to see the client.
+func RateLimitMiddleware(group string, limit RateLimit, store RateLimitStore, key RateLimitKeyFunc) gin.HandlerFunc {
+	policy := fmt.Sprintf("%d;w=%d", limit.Burst, int(math.Ceil(limit.window().Seconds())))
+
+	return func(c *gin.Context) {
+		result, err := store.Take(c.Request.Context(), group+":"+key(c), limit)
+		if err != nil {
+			RateLimitErrorsTotal.WithLabelValues(group).Inc()
+			logging.FromContext(c.Request.Context()).Warn("rate limit store unavailable, request not limited", "group", group, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			RateLimitRejectionsTotal.WithLabelValues(group).Inc()
+			c.Header("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+			ErrorJSONGin(c, http.StatusTooManyRequests, ErrRateLimited)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
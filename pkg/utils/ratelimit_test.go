@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	limit := RateLimit{Rate: 2, Burst: 3}
+	ctx := context.Background()
+
+	// A new bucket starts full
+	for remaining := 2; remaining >= 0; remaining-- {
+		result, err := store.Take(ctx, "a", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+
+	result, _ := store.Take(ctx, "a", limit)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.ResetAfter)
+
+	// Other keys have their own bucket
+	result, _ = store.Take(ctx, "b", limit)
+	assert.True(t, result.Allowed)
+
+	// Refilled at Rate tokens per second
+	now = now.Add(500 * time.Millisecond)
+	result, _ = store.Take(ctx, "a", limit)
+	assert.True(t, result.Allowed)
+	result, _ = store.Take(ctx, "a", limit)
+	assert.False(t, result.Allowed)
+
+	// Buckets that have filled up again are dropped
+	now = now.Add(time.Hour)
+	store.Take(ctx, "c", limit)
+	assert.Equal(t, 1, store.Len())
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store unavailable")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Rate: 0.5, Burst: 2}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if client := c.GetHeader("X-Client"); client != "" {
+			c.Set("client_id", client)
+		}
+	})
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/limited", RateLimitMiddleware("test", limit, store, RateLimitByClient), ok)
+	router.GET("/open", RateLimitMiddleware("test_failing", limit, failingRateLimitStore{}, RateLimitByIP), ok)
+
+	request := func(path, client string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-Client", client)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/limited", "a")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "2;w=4", w.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, http.StatusOK, request("/limited", "a").Code)
+
+	rejected := testutil.ToFloat64(RateLimitRejectionsTotal.WithLabelValues("test"))
+	w = request("/limited", "a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, rejected+1, testutil.ToFloat64(RateLimitRejectionsTotal.WithLabelValues("test")))
+
+	// Another client, and requests without one by IP, are limited separately
+	assert.Equal(t, http.StatusOK, request("/limited", "b").Code)
+	assert.Equal(t, http.StatusOK, request("/limited", "").Code)
+
+	// A failing store lets requests through
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, request("/open", "").Code)
+	}
+	assert.Equal(t, 3.0, testutil.ToFloat64(RateLimitErrorsTotal.WithLabelValues("test_failing")))
+}
+
+func TestRateLimitByAppID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := func(method, target, body, clientID string) (string, string) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest(method, target, strings.NewReader(body))
+		c.Request.RemoteAddr = "203.0.113.7:4321"
+		if clientID != "" {
+			c.Set("client_id", clientID)
+		}
+		got := RateLimitByAppID(c)
+		rest, _ := io.ReadAll(c.Request.Body)
+		return got, string(rest)
+	}
+
+	got, _ := key("GET", "/delivery?app_id=game", "", "")
+	assert.Equal(t, "app:game", got)
+
+	// Authenticated clients are limited per app, apart from other clients sending the same app_id
+	got, _ = key("GET", "/delivery?app_id=game", "", "sdk")
+	assert.Equal(t, "client:sdk:app:game", got)
+	got, _ = key("GET", "/delivery", "", "sdk")
+	assert.Equal(t, "client:sdk", got)
+
+	// Batch requests send app_id in the body, which the handler can still read afterwards
+	body := `{"dimensions": {"app_id": ["news"]}, "placements": [{"placement_id": "banner"}]}`
+	got, rest := key("POST", "/delivery", body, "")
+	assert.Equal(t, "app:news", got)
+	assert.Equal(t, body, rest)
+
+	got, _ = key("POST", "/delivery", `{"app_id": "game"}`, "")
+	assert.Equal(t, "app:game", got)
+
+	got, _ = key("POST", "/delivery", `not json`, "")
+	assert.Equal(t, "ip:203.0.113.7", got)
+}
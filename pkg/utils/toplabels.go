@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OtherLabel is the label value that values outside the top ones are folded into
+const OtherLabel = "other"
+
+// trackedPerSlot bounds the candidate values counted per top slot, so that a flood of distinct values
+// cannot grow the counts without limit
+const trackedPerSlot = 10
+
+// promoteFactor is how many times more often a value must be counted than the least frequent top value to
+// take its place, so that values of similar frequency do not keep swapping series
+const promoteFactor = 2
+
+// demotedReranks is how many re-ranks the series of a demoted value is kept for, so that a value dropping
+// out of the top for a moment keeps its counters
+const demotedReranks = 10
+
+// seriesDeleter is implemented by every Prometheus metric vector
+type seriesDeleter interface {
+	DeletePartialMatch(labels prometheus.Labels) int
+}
+
+// TopLabels bounds the cardinality of one label of some metric vectors to its k most frequent values;
+// the rest are reported as OtherLabel. The labels before it qualify the value, like the tenant of a
+// campaign ID, and are kept as they are, so they must have few values of their own. Values are re-ranked
+// every interval with their counts halved, so the ranking follows recent traffic. A demoted value is
+// folded into OtherLabel from then on; its series is kept for demotedReranks re-ranks, in case it comes
+// back, and then deleted. At most k demoted series are kept, so a vector holds at most 2k series besides
+// OtherLabel.
+type TopLabels struct {
+	labels   []string
+	vectors  []seriesDeleter
+	interval time.Duration
+
+	k          int
+	counts     map[string]uint64
+	top        map[string]bool
+	demoted    map[string]demotedValue
+	nextRerank time.Time
+	mutex      sync.Mutex
+}
+
+// demotedValue is a value whose series is kept after it left the top
+type demotedValue struct {
+	values []string
+	at     time.Time
+}
+
+// NewTopLabels limits the last of labels on vectors to the k most frequent values, re-ranked every interval
+func NewTopLabels(labels []string, k int, interval time.Duration, vectors ...seriesDeleter) *TopLabels {
+	return &TopLabels{
+		labels:   labels,
+		vectors:  vectors,
+		interval: interval,
+		k:        k,
+		counts:   make(map[string]uint64),
+		top:      make(map[string]bool),
+		demoted:  make(map[string]demotedValue),
+	}
+}
+
+// SetK changes how many values get their own series; it takes effect at the next re-rank
+func (t *TopLabels) SetK(k int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.k = k
+}
+
+// Values counts one observation of values, one per label, and returns the label values to record it under
+func (t *TopLabels) Values(values ...string) []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if now := time.Now(); now.After(t.nextRerank) {
+		t.rerank(now)
+		t.nextRerank = now.Add(t.interval)
+	}
+
+	key := strings.Join(values, "\x00")
+	if _, tracked := t.counts[key]; tracked || len(t.counts) < t.k*trackedPerSlot {
+		t.counts[key]++
+	}
+
+	if t.top[key] {
+		return values
+	}
+	// Until the next re-rank, free slots go to the first values seen
+	if len(t.top) < t.k {
+		t.top[key] = true
+		delete(t.demoted, key)
+		return values
+	}
+	other := append([]string(nil), values...)
+	other[len(other)-1] = OtherLabel
+	return other
+}
+
+// rerank updates the top from the decayed counts and decays them again. A top value only loses its place
+// to a value counted promoteFactor times as often, or when it is no longer counted at all.
+func (t *TopLabels) rerank(now time.Time) {
+	ranked := make([]string, 0, len(t.counts))
+	for key := range t.counts {
+		ranked = append(ranked, key)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if t.counts[ranked[i]] != t.counts[ranked[j]] {
+			return t.counts[ranked[i]] > t.counts[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+
+	// Incumbents that are still counted, most frequent first, and the challengers in the same order
+	var incumbents, challengers []string
+	for _, key := range ranked {
+		if t.top[key] {
+			incumbents = append(incumbents, key)
+		} else {
+			challengers = append(challengers, key)
+		}
+	}
+	if len(incumbents) > t.k {
+		incumbents = incumbents[:t.k]
+	}
+
+	for _, key := range challengers {
+		if len(incumbents) < t.k {
+			incumbents = append(incumbents, key)
+			continue
+		}
+		weakest := len(incumbents) - 1
+		if weakest < 0 || t.counts[key] < promoteFactor*t.counts[incumbents[weakest]] {
+			break
+		}
+		incumbents[weakest] = key
+		// Keep incumbents ordered so that the next challenger is compared with the new weakest
+		sort.SliceStable(incumbents, func(i, j int) bool { return t.counts[incumbents[i]] > t.counts[incumbents[j]] })
+	}
+
+	top := make(map[string]bool, len(incumbents))
+	for _, key := range incumbents {
+		top[key] = true
+		delete(t.demoted, key)
+	}
+	for key := range t.top {
+		if !top[key] {
+			t.demoted[key] = demotedValue{values: strings.Split(key, "\x00"), at: now}
+		}
+	}
+	t.top = top
+	t.expireDemoted(now)
+
+	// Drop the least frequent values beyond half the tracking capacity, so that new values can be counted
+	// in the next interval
+	tracked := 0
+	for _, key := range ranked {
+		count := t.counts[key] / 2
+		if count == 0 || (tracked >= t.k*trackedPerSlot/2 && !top[key]) {
+			delete(t.counts, key)
+			continue
+		}
+		t.counts[key] = count
+		tracked++
+	}
+}
+
+// expireDemoted deletes the series of values demoted more than demotedReranks re-ranks ago, and of the
+// longest demoted values beyond k
+func (t *TopLabels) expireDemoted(now time.Time) {
+	keys := make([]string, 0, len(t.demoted))
+	for key := range t.demoted {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !t.demoted[keys[i]].at.Equal(t.demoted[keys[j]].at) {
+			return t.demoted[keys[i]].at.After(t.demoted[keys[j]].at)
+		}
+		return keys[i] < keys[j]
+	})
+
+	for i, key := range keys {
+		if i < t.k && now.Sub(t.demoted[key].at) < demotedReranks*t.interval {
+			continue
+		}
+		labels := make(prometheus.Labels, len(t.labels))
+		for j, label := range t.labels {
+			labels[label] = t.demoted[key].values[j]
+		}
+		for _, vector := range t.vectors {
+			vector.DeletePartialMatch(labels)
+		}
+		delete(t.demoted, key)
+	}
+}
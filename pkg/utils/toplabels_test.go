@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopLabels(t *testing.T) {
+	serves := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_serves_total"}, []string{"tenant_id", "campaign_id"})
+	top := NewTopLabels([]string{"tenant_id", "campaign_id"}, 2, time.Hour, serves)
+	record := func(campaignID string, times int) {
+		for i := 0; i < times; i++ {
+			serves.WithLabelValues(top.Values("games", campaignID)...).Inc()
+		}
+	}
+	now := time.Now()
+	rerank := func() {
+		now = now.Add(time.Hour)
+		top.mutex.Lock()
+		top.rerank(now)
+		top.mutex.Unlock()
+	}
+
+	// Free slots go to the first values seen
+	record("a", 6)
+	record("b", 6)
+	record("c", 10)
+	assert.Equal(t, 3, testutil.CollectAndCount(serves))
+	assert.Equal(t, 10.0, testutil.ToFloat64(serves.WithLabelValues("games", OtherLabel)))
+
+	// c is counted more often than a, but not twice as often, so the top is kept
+	rerank()
+	assert.Equal(t, []string{"games", "a"}, top.Values("games", "a"))
+	assert.Equal(t, []string{"games", OtherLabel}, top.Values("games", "c"))
+
+	// Once c is counted twice as often as a, it takes a's place
+	record("b", 5)
+	record("c", 20)
+	rerank()
+	assert.Equal(t, []string{"games", "c"}, top.Values("games", "c"))
+	assert.Equal(t, []string{"games", "b"}, top.Values("games", "b"))
+	assert.Equal(t, []string{"games", OtherLabel}, top.Values("games", "a"))
+
+	// The series of a is kept for a while, so its counter is not reset; a is folded into other from now on
+	record("a", 1)
+	record("c", 1)
+	assert.Equal(t, 4, testutil.CollectAndCount(serves))
+	assert.Equal(t, 6.0, testutil.ToFloat64(serves.WithLabelValues("games", "a")))
+	assert.Equal(t, 31.0, testutil.ToFloat64(serves.WithLabelValues("games", OtherLabel)))
+
+	// Once a has been out of the top long enough, its series is deleted
+	for i := 0; i < demotedReranks; i++ {
+		record("b", 100)
+		record("c", 100)
+		rerank()
+	}
+	assert.Equal(t, 3, testutil.CollectAndCount(serves), "b, c and other are left")
+}
+
+func TestTopLabels_DemotedSeriesAreCapped(t *testing.T) {
+	serves := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_capped_total"}, []string{"campaign_id"})
+	top := NewTopLabels([]string{"campaign_id"}, 1, time.Hour, serves)
+	now := time.Now()
+
+	// Every re-rank, an idle top value is demoted and a new value takes its slot. Only k demoted series
+	// are kept, so the series do not grow with churn.
+	for _, value := range []string{"a", "b", "c", "d", "e"} {
+		now = now.Add(time.Minute)
+		serves.WithLabelValues(top.Values(value)...).Inc()
+		top.mutex.Lock()
+		top.rerank(now)
+		top.rerank(now)
+		top.mutex.Unlock()
+	}
+	assert.Equal(t, 1, testutil.CollectAndCount(serves))
+	assert.Equal(t, 1.0, testutil.ToFloat64(serves.WithLabelValues("e")))
+}
+
+func TestTopLabels_IdleValuesLoseTheirSlot(t *testing.T) {
+	top := NewTopLabels([]string{"campaign_id"}, 1, time.Hour)
+	top.Values("a")
+
+	// Counts halve on every re-rank, so an idle value is no longer counted and its slot is freed
+	now := time.Now()
+	top.mutex.Lock()
+	top.rerank(now)
+	top.mutex.Unlock()
+	top.Values("b")
+	top.Values("b")
+	top.mutex.Lock()
+	top.rerank(now)
+	top.mutex.Unlock()
+
+	assert.Equal(t, []string{"b"}, top.Values("b"))
+	assert.Equal(t, []string{OtherLabel}, top.Values("a"))
+}
+
+func TestRecordDelivery(t *testing.T) {
+	params := map[string][]string{"app_id": {"metrics_test_app"}, "country": {"US"}, "os": {"ios"}}
+	requests := DeliveryRequestsTotal.WithLabelValues("games", "metrics_test_app")
+	zeroFill := DeliveryZeroFillTotal.WithLabelValues("games", "metrics_test_app")
+	serves := CampaignServesTotal.WithLabelValues("games", "metrics_test_camp")
+	otherTenant := CampaignServesTotal.WithLabelValues("news", "metrics_test_camp")
+
+	RecordDelivery("games", params, []string{"metrics_test_camp"})
+	RecordDelivery("games", params, nil)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(requests))
+	assert.Equal(t, 1.0, testutil.ToFloat64(zeroFill))
+	assert.Equal(t, 1.0, testutil.ToFloat64(serves))
+	assert.Equal(t, 0.0, testutil.ToFloat64(otherTenant), "campaign IDs are counted per tenant")
+}
@@ -1,11 +1,22 @@
 package utils
 
 import (
+	"campaign/pkg/logging"
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type ErrorResponse struct {
@@ -31,6 +42,18 @@ func ErrorJSONGinWithDetails(c *gin.Context, status int, msg, details string) {
 	c.JSON(status, response)
 }
 
+// UnmatchedRoute is the path label of requests that matched no route, so that probes of arbitrary URLs
+// cannot create new series
+const UnmatchedRoute = "unmatched"
+
+// knownMethods bounds the method label; any other method is recorded as "other"
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// GinPrometheusMiddleware records requests in http_request_duration_seconds by route template, with the
+// trace ID as exemplar. It must run inside the tracing middleware.
 func GinPrometheusMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -42,14 +65,74 @@ func GinPrometheusMiddleware() gin.HandlerFunc {
 		path := c.FullPath()
 
 		if path == "" {
-			path = c.Request.URL.Path
+			path = UnmatchedRoute
 		}
 
-		HTTPRequestDuration.With(prometheus.Labels{
+		method := c.Request.Method
+		if !knownMethods[method] {
+			method = "other"
+		}
+
+		ObserveWithTrace(c.Request.Context(), HTTPRequestDuration.With(prometheus.Labels{
 			"path":        path,
-			"method":      c.Request.Method,
+			"method":      method,
 			"status_code": fmt.Sprintf("%d", c.Writer.Status()),
-		}).Observe(duration.Seconds())
+		}), duration.Seconds())
+	}
+}
+
+// GRPCPrometheusInterceptor records unary gRPC calls in grpc_request_duration_seconds
+func GRPCPrometheusInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		ObserveWithTrace(ctx, GRPCRequestDuration.With(prometheus.Labels{
+			"method": info.FullMethod,
+			"code":   status.Code(err).String(),
+		}), time.Since(start).Seconds())
+
+		return resp, err
+	}
+}
+
+// GRPCLoggingInterceptor correlates gRPC calls like AccessLogMiddleware does HTTP requests: it takes the
+// request ID from the x-request-id metadata or generates one, stores a logger carrying it in the context,
+// and logs one line per call
+func GRPCLoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+
+		var requestID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("x-request-id"); len(values) > 0 {
+				requestID = values[0]
+			}
+		}
+		if requestID == "" {
+			requestID = generateRequestID()
+		}
+
+		requestLogger := withTraceID(ctx, logger.With("request_id", requestID))
+		resp, err := handler(logging.NewContext(ctx, requestLogger), req)
+
+		code := status.Code(err)
+		level := slog.LevelInfo
+		switch code {
+		case codes.OK:
+		case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss:
+			level = slog.LevelError
+		default:
+			level = slog.LevelWarn
+		}
+		requestLogger.Log(ctx, level, "grpc request",
+			"method", info.FullMethod,
+			"code", code.String(),
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+		)
+
+		return resp, err
 	}
 }
 
@@ -62,15 +145,6 @@ func MethodGuardGin(allowedMethod string) gin.HandlerFunc {
 	}
 }
 
-// RateLimitMiddleware is a placeholder for rate limiting implementation
-func RateLimitMiddleware(requestsPerMinute int) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// TODO: Implement rate limiting logic
-		// This could use a token bucket or sliding window approach
-		c.Next()
-	}
-}
-
 // RequestIDMiddleware adds a unique request ID to each request
 func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -84,6 +158,74 @@ func RequestIDMiddleware() gin.HandlerFunc {
 	}
 }
 
+// AccessLogMiddleware replaces gin.Logger with one structured line per request. It must run after
+// RequestIDMiddleware and the tracing middleware: it stores a logger carrying the request_id and trace_id
+// in the request context, so that handler and database logs of the request share those IDs.
+func AccessLogMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestLogger := withTraceID(c.Request.Context(), logger.With("request_id", c.GetString("request_id")))
+		c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), requestLogger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
+		}
+		if clientID := c.GetString("client_id"); clientID != "" {
+			attrs = append(attrs, "client_id", clientID)
+		}
+		if user := c.GetString("user"); user != "" {
+			attrs = append(attrs, "user", user)
+		}
+		if tenantID := c.GetString("tenant_id"); tenantID != "" {
+			attrs = append(attrs, "tenant_id", tenantID)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+		requestLogger.Log(c.Request.Context(), level, "http request", attrs...)
+	}
+}
+
+// withTraceID adds the trace and span IDs of the request's span, if any, so that logs and traces can be joined
+func withTraceID(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return logger
+	}
+	return logger.With("trace_id", spanContext.TraceID().String(), "span_id", spanContext.SpanID().String())
+}
+
+// RecoveryMiddleware replaces gin.Recovery, logging panics with their stack through the request logger
+func RecoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		logging.FromContext(c.Request.Context()).Error("panic serving request",
+			"panic", fmt.Sprint(recovered),
+			"stack", string(debug.Stack()),
+		)
+		ErrorJSONGin(c, http.StatusInternalServerError, InternalServerError)
+		c.Abort()
+	})
+}
+
 func generateRequestID() string {
 	return fmt.Sprintf("req_%d", time.Now().UnixNano())
 }
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"bytes"
+	"campaign/pkg/logging"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), AccessLogMiddleware(logging.New(&buf, "info")), RecoveryMiddleware())
+	router.GET("/items/:id", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("handler line")
+		c.Status(http.StatusNotFound)
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	req, _ := http.NewRequest("GET", "/items/42", nil)
+	req.Header.Set("X-Request-ID", "req_test")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/panic", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 4)
+
+	// The handler's line and the access log share the request ID
+	assert.Equal(t, "handler line", records[0]["msg"])
+	assert.Equal(t, "req_test", records[0]["request_id"])
+	assert.Equal(t, "http request", records[1]["msg"])
+	assert.Equal(t, "req_test", records[1]["request_id"])
+	assert.Equal(t, "WARN", records[1]["level"])
+	assert.Equal(t, "/items/:id", records[1]["route"])
+	assert.Equal(t, "/items/42", records[1]["path"])
+	assert.EqualValues(t, 404, records[1]["status"])
+
+	assert.Equal(t, "panic serving request", records[2]["msg"])
+	assert.Equal(t, "ERROR", records[3]["level"])
+	assert.EqualValues(t, 500, records[3]["status"])
+	assert.Equal(t, records[2]["request_id"], records[3]["request_id"])
+}
+
+func TestGinPrometheusMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		spanContext := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+		c.Request = c.Request.WithContext(trace.ContextWithSpanContext(c.Request.Context(), spanContext))
+		c.Next()
+	}, GinPrometheusMiddleware())
+	router.GET("/metrics_test/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, target := range []struct{ method, path string }{
+		{"GET", "/metrics_test/1"},
+		{"GET", "/metrics_test/2"},
+		{"GET", "/wp-login.php"},
+		{"GET", "/.env"},
+		{"PROPFIND", "/anything"},
+	} {
+		req, _ := http.NewRequest(target.method, target.path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	families, err := Registry.Gather()
+	require.NoError(t, err)
+
+	counts := make(map[string]uint64)
+	var exemplar *dto.Exemplar
+	for _, family := range families {
+		if family.GetName() != "http_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			counts[labels["method"]+" "+labels["path"]] += metric.GetHistogram().GetSampleCount()
+			for _, bucket := range metric.GetHistogram().GetBucket() {
+				if bucket.GetExemplar() != nil && labels["path"] == "/metrics_test/:id" {
+					exemplar = bucket.GetExemplar()
+				}
+			}
+		}
+	}
+
+	// Routes are recorded by template and unmatched requests collapse into one series per known method
+	assert.Equal(t, uint64(2), counts["GET /metrics_test/:id"])
+	assert.Equal(t, uint64(2), counts["GET "+UnmatchedRoute])
+	assert.Equal(t, uint64(1), counts["other "+UnmatchedRoute])
+
+	require.NotNil(t, exemplar)
+	assert.Equal(t, "trace_id", exemplar.GetLabel()[0].GetName())
+	assert.Equal(t, traceID.String(), exemplar.GetLabel()[0].GetValue())
+}